@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/clyvecute/configra/internal/codegen"
 	"github.com/clyvecute/configra/internal/config"
 	"github.com/clyvecute/configra/internal/configs"
 	"github.com/clyvecute/configra/internal/db"
+	"github.com/clyvecute/configra/internal/featureflags"
 	"github.com/clyvecute/configra/internal/middleware"
+	"github.com/clyvecute/configra/internal/projects"
 )
 
 func main() {
@@ -37,15 +46,53 @@ func main() {
 	sentinelClient := configs.NewSentinelClient(cfg.SentinelURL)
 	configsRepo := configs.NewRepository(database)
 	configsService := configs.NewService(configsRepo, sentinelClient)
+	configsService.Start()
 	configsHandler := configs.NewHandler(configsService)
+	projectsHandler := projects.NewHandler(projects.NewRepository(database))
+	codegenHandler := codegen.NewHandler()
+	flagsService := featureflags.NewService(featureflags.NewRepository(database), configsService)
+	flagsService.Start()
+	flagsHandler := featureflags.NewHandler(flagsService)
 
 	// Initialize Middleware
 	authMiddleware := middleware.NewAuthMiddleware(database)
 
 	// Register routes
 	mux.HandleFunc("/v1/validate", configsHandler.Validate) // No auth needed for local check check
+	mux.HandleFunc("POST /v1/schemas/export", codegenHandler.Export) // Stateless schema conversion
+	mux.HandleFunc("POST /v1/schemas/import", codegenHandler.Import) // Stateless schema conversion
 	mux.HandleFunc("/v1/configs", authMiddleware.RequireAPIKey(configsHandler.Create)) // Protected
+	mux.HandleFunc("GET /v1/configs", authMiddleware.RequireAPIKey(configsHandler.Get)) // Protected, used by the SDK
+	mux.HandleFunc("PUT /v1/configs/compatibility", authMiddleware.RequireAdminKey(configsHandler.SetCompatibility)) // Admin only
+	mux.HandleFunc("PUT /v1/configs/schema", authMiddleware.RequireAdminKey(configsHandler.BindSchema)) // Admin only
+	mux.HandleFunc("POST /v1/schemas", authMiddleware.RequireAdminKey(configsHandler.RegisterSchema)) // Admin only
+	mux.HandleFunc("GET /v1/schemas", authMiddleware.RequireAPIKey(configsHandler.ListSchemas)) // Protected
+	mux.HandleFunc("GET /v1/schemas/{name}", authMiddleware.RequireAPIKey(configsHandler.GetSchema)) // Protected
+	mux.HandleFunc("GET /v1/export", authMiddleware.RequireAPIKey(configsHandler.Export)) // Protected
+	mux.HandleFunc("GET /v1/deprecations", authMiddleware.RequireAPIKey(configsHandler.DeprecatedReads)) // Protected
+	mux.HandleFunc("POST /v1/flags", authMiddleware.RequireAPIKey(flagsHandler.Save)) // Protected
+	mux.HandleFunc("GET /v1/flags", authMiddleware.RequireAPIKey(flagsHandler.List)) // Protected
+	mux.HandleFunc("GET /v1/flags/{key}", authMiddleware.RequireAPIKey(flagsHandler.Get)) // Protected
+	mux.HandleFunc("DELETE /v1/flags/{key}", authMiddleware.RequireAPIKey(flagsHandler.Delete)) // Protected
+	mux.HandleFunc("POST /v1/flags/{key}/evaluate", authMiddleware.RequireAPIKey(flagsHandler.Evaluate)) // Protected, used by the SDK
+	mux.HandleFunc("GET /v1/flags/{key}/evaluations", authMiddleware.RequireAPIKey(flagsHandler.Evaluations)) // Protected
+	mux.HandleFunc("POST /v1/flags/evaluations", authMiddleware.RequireAPIKey(flagsHandler.RecordEvaluations)) // Protected, used by the SDK
+	mux.HandleFunc("GET /v1/stale-flags", authMiddleware.RequireAPIKey(flagsHandler.StaleFlags)) // Protected
+	mux.HandleFunc("GET /v1/ruleset", authMiddleware.RequireAPIKey(flagsHandler.Ruleset)) // Protected, used by the SDK
+	mux.HandleFunc("GET /v1/ruleset/stream", authMiddleware.RequireAPIKey(flagsHandler.StreamRuleset)) // Protected, used by the SDK
+	mux.HandleFunc("POST /v1/segments", authMiddleware.RequireAPIKey(flagsHandler.SaveSegment)) // Protected
+	mux.HandleFunc("GET /v1/segments", authMiddleware.RequireAPIKey(flagsHandler.ListSegments)) // Protected
+	mux.HandleFunc("GET /v1/segments/{key}", authMiddleware.RequireAPIKey(flagsHandler.GetSegment)) // Protected
+	mux.HandleFunc("GET /v1/segments/{key}/flags", authMiddleware.RequireAPIKey(flagsHandler.SegmentUsage)) // Protected
+	mux.HandleFunc("DELETE /v1/segments/{key}", authMiddleware.RequireAPIKey(flagsHandler.DeleteSegment)) // Protected
+	mux.HandleFunc("POST /v1/experiments", authMiddleware.RequireAPIKey(flagsHandler.SaveExperiment)) // Protected
+	mux.HandleFunc("GET /v1/experiments", authMiddleware.RequireAPIKey(flagsHandler.ListExperiments)) // Protected
+	mux.HandleFunc("GET /v1/experiments/{key}", authMiddleware.RequireAPIKey(flagsHandler.GetExperiment)) // Protected
+	mux.HandleFunc("DELETE /v1/experiments/{key}", authMiddleware.RequireAPIKey(flagsHandler.DeleteExperiment)) // Protected
+	mux.HandleFunc("POST /v1/experiments/{key}/assign", authMiddleware.RequireAPIKey(flagsHandler.Assign)) // Protected, used by the SDK
+	mux.HandleFunc("POST /v1/evaluate", authMiddleware.RequireAPIKey(flagsHandler.EvaluateAll)) // Protected, used by the SDK
 	mux.HandleFunc("/v1/rollback", authMiddleware.RequireAPIKey(configsHandler.Rollback)) // Protected
+	mux.HandleFunc("GET /v1/project", authMiddleware.RequireAPIKey(projectsHandler.Current)) // Protected, used by `configra login`
 	mux.HandleFunc("/fetch", configsHandler.FetchSource) // Internal/External fetch for UI
 
 
@@ -66,14 +113,38 @@ func main() {
 			"service": "Configra API",
 			"status":  "running",
 			"docs":    "This is a JSON-only API. Use the CLI or API endpoints.",
-			"endpoints": "/health, /v1/validate, /v1/configs, /v1/rollback",
+			"endpoints": "/health, /v1/validate, /v1/configs, /v1/rollback, /v1/flags, /v1/evaluate",
 		}
 		json.NewEncoder(w).Encode(response)
 	})
 
+	// Streams end when the server shuts down, so it doesn't wait for them.
+	base, cancelBase := context.WithCancel(context.Background())
+	server := &http.Server{
+		Addr:        ":" + cfg.Port,
+		Handler:     middleware.CORS(mux), // Apply CORS middleware to everything
+		BaseContext: func(net.Listener) context.Context { return base },
+	}
+	server.RegisterOnShutdown(cancelBase)
+
 	fmt.Printf("Starting Configra API on :%s\n", cfg.Port)
-	// Apply CORS middleware to everything
-	if err := http.ListenAndServe(":"+cfg.Port, middleware.CORS(mux)); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM, finish the requests in flight, then store the
+	// buffered evaluation counts and deprecated reads.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	log.Println("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Shutdown did not finish: %v", err)
 	}
+	flagsService.Close()
+	configsService.Close()
 }
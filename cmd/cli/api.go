@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// apiError is a non-2xx response from the Configra API.
+type apiError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	Code       string   // Machine-readable reason, e.g. configs.CodeConfigNotFound
+	Violations []string // Failed constraints or compatibility checks, one per line
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("API returned %s", e.Status)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	for _, v := range e.Violations {
+		msg += "\n  - " + v
+	}
+	return msg
+}
+
+// violationLines renders the violations of an error response: compatibility
+// violations are strings, failed constraints objects naming their fields.
+func violationLines(raw []json.RawMessage) []string {
+	var lines []string
+	for _, r := range raw {
+		var s string
+		if json.Unmarshal(r, &s) == nil {
+			lines = append(lines, s)
+			continue
+		}
+		var v configs.RuleViolation
+		if json.Unmarshal(r, &v) == nil {
+			lines = append(lines, fmt.Sprintf("%s (fields: %s)", v.Message, strings.Join(v.Fields, ", ")))
+		}
+	}
+	return lines
+}
+
+// apiCall sends a request to the Configra API and decodes the JSON response
+// into out. Non-2xx responses are returned as errors carrying the server's
+// error message.
+func apiCall(method, url, apiKey string, payload, out interface{}) error {
+	respBody, err := apiRaw(method, url, apiKey, payload)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// apiRaw is like apiCall but returns the response body undecoded.
+func apiRaw(method, url, apiKey string, payload interface{}) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(b)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body struct {
+			Error      string            `json:"error"`
+			Code       string            `json:"code"`
+			Violations []json.RawMessage `json:"violations"`
+		}
+		json.Unmarshal(respBody, &body)
+		return nil, &apiError{StatusCode: resp.StatusCode, Status: resp.Status, Message: body.Error, Code: body.Code, Violations: violationLines(body.Violations)}
+	}
+	return respBody, nil
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// applyItem is one config file found in the apply tree, with its plan.
+type applyItem struct {
+	Env        string
+	Key        string
+	Path       string
+	Data       map[string]interface{}
+	Schema     map[string]interface{} // From SchemaFile, or the bound registered schema
+	SchemaFile string                 // "" if the key relies on its bound schema
+	BoundTo    string                 // Registered schema the key is bound to, if any
+	Current    int                    // Current server version, 0 if the key does not exist yet
+	Changed    bool
+}
+
+// runApply syncs a directory laid out as <dir>/<env>/<key>.json to the server.
+// Each config's schema is read from <dir>/<env>/<key>.schema.json, falling
+// back to <dir>/<key>.schema.json when it is shared by all environments.
+// Keys bound to a registered schema need no schema file; one that is present
+// must match the registered schema. YAML and TOML files are accepted wherever
+// JSON is.
+func runApply(dir string, autoApprove bool, remote *remoteFlags) {
+	items, err := collectApplyItems(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(items) == 0 {
+		fmt.Printf("No configs found under %s (expected <env>/<key>.json, .yaml or .toml).\n", dir)
+		return
+	}
+
+	// 1. Validate everything with a schema file locally before touching the
+	// server
+	failed := false
+	for _, item := range items {
+		if item.SchemaFile == "" {
+			continue
+		}
+		if err := checkApplyItem(item); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", item.Path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	// 2. Compute the plan against the server's current state, validating the
+	// keys without a schema file against their bound schema
+	registered := map[string]*configs.RegisteredSchema{}
+	for _, item := range items {
+		if err := planApplyItem(item, registered, remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning %s/%s: %v\n", item.Env, item.Key, err)
+			os.Exit(1)
+		}
+	}
+
+	changes := printApplyPlan(items)
+	if changes == 0 {
+		return
+	}
+
+	if !autoApprove && !confirm(fmt.Sprintf("Apply %d change(s)?", changes)) {
+		fmt.Println("Apply cancelled.")
+		return
+	}
+
+	// 3. Push only the changed configs
+	failed = false
+	for _, item := range items {
+		if !item.Changed {
+			continue
+		}
+		payload := map[string]interface{}{
+			"env":  item.Env,
+			"key":  item.Key,
+			"data": item.Data,
+		}
+		// Without a schema file the server uses the bound schema.
+		if item.SchemaFile != "" {
+			payload["schema"] = item.Schema
+		}
+		var cfg configs.Config
+		if err := apiCall("POST", fmt.Sprintf("%s/v1/configs", remote.Host), remote.APIKey, payload, &cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s/%s: %v\n", item.Env, item.Key, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("✅ %s/%s is now version %d\n", item.Env, item.Key, cfg.Version)
+		printWarnings(item.Env+"/"+item.Key+": ", cfg.Warnings)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func collectApplyItems(dir string) ([]*applyItem, error) {
+	envDirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*applyItem
+	for _, envDir := range envDirs {
+		if !envDir.IsDir() {
+			continue
+		}
+		env := envDir.Name()
+		files, err := os.ReadDir(filepath.Join(dir, env))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			key, ok := splitConfigExt(f.Name())
+			if f.IsDir() || !ok || strings.HasSuffix(key, ".schema") {
+				continue
+			}
+			path := filepath.Join(dir, env, f.Name())
+			data, err := readConfigFile(path)
+			if err != nil {
+				return nil, err
+			}
+			item := &applyItem{Env: env, Key: key, Path: path, Data: data}
+			if schemaFile, ok := schemaPathFor(dir, env, key); ok {
+				if item.Schema, _, err = readSchemaFile(schemaFile); err != nil {
+					return nil, fmt.Errorf("%s: %v", path, err)
+				}
+				item.SchemaFile = schemaFile
+			}
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Env != items[j].Env {
+			return items[i].Env < items[j].Env
+		}
+		return items[i].Key < items[j].Key
+	})
+	return items, nil
+}
+
+// schemaPathFor returns the schema file of a config, if there is one.
+func schemaPathFor(dir, env, key string) (string, bool) {
+	if path, ok := findSchemaFile(filepath.Join(dir, env, key)); ok {
+		return path, true
+	}
+	return findSchemaFile(filepath.Join(dir, key))
+}
+
+// checkApplyItem validates item against its schema and, if the schema strips
+// unknown fields, drops them so the plan compares what the server would store.
+func checkApplyItem(item *applyItem) error {
+	schema, err := decodeSchemaMap(item.Schema)
+	if err != nil {
+		return err
+	}
+	warnings, err := configs.Check(schema, item.Data)
+	if err != nil {
+		return err
+	}
+	printWarnings(item.Path+": ", warnings)
+	if schema.UnknownFields == configs.UnknownStrip {
+		item.Data = configs.StripUnknown(schema, item.Data)
+	}
+	return nil
+}
+
+// planApplyItem fetches the current server version of item and marks it
+// changed unless both data and schema are identical. A key bound to a
+// registered schema is compared with, and if it has no schema file validated
+// against, the latest version of that schema, which registered caches by name.
+func planApplyItem(item *applyItem, registered map[string]*configs.RegisteredSchema, remote *remoteFlags) error {
+	q := url.Values{}
+	q.Set("env", item.Env)
+	q.Set("key", item.Key)
+
+	var current configs.Config
+	err := apiCall("GET", fmt.Sprintf("%s/v1/configs?%s", remote.Host, q.Encode()), remote.APIKey, nil, &current)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && apiErr.Code == configs.CodeConfigNotFound {
+		item.Changed = true
+		if item.SchemaFile == "" {
+			// A key can be bound before its first version; only the server knows.
+			return dryRunApplyItem(item, remote)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	item.Current = current.Version
+	item.BoundTo = current.SchemaName
+
+	switch {
+	case item.BoundTo != "":
+		bound := registered[item.BoundTo]
+		if bound == nil {
+			bound = &configs.RegisteredSchema{}
+			if err := apiCall("GET", fmt.Sprintf("%s/v1/schemas/%s", remote.Host, url.PathEscape(item.BoundTo)), remote.APIKey, nil, bound); err != nil {
+				return fmt.Errorf("fetching bound schema '%s': %v", item.BoundTo, err)
+			}
+			registered[item.BoundTo] = bound
+		}
+		if item.SchemaFile != "" {
+			if same, err := sameSchema(item.Schema, bound.Definition); err != nil || !same {
+				return fmt.Errorf("%s differs from schema '%s' version %d, which the key is bound to; remove it to use the registered schema", item.SchemaFile, bound.Name, bound.Version)
+			}
+		} else {
+			item.Schema = bound.Definition
+			if err := checkApplyItem(item); err != nil {
+				return fmt.Errorf("%s: %v", item.Path, err)
+			}
+		}
+	case item.SchemaFile == "":
+		return fmt.Errorf("%s has no schema file and the key is not bound to a registered schema", item.Path)
+	}
+
+	item.Changed = !sameJSON(item.Data, current.Data) || !sameJSON(item.Schema, current.Schema)
+	return nil
+}
+
+// dryRunApplyItem has the server validate a new key that has no schema file
+// against the schema it is bound to.
+func dryRunApplyItem(item *applyItem, remote *remoteFlags) error {
+	payload := map[string]interface{}{"env": item.Env, "key": item.Key, "data": item.Data, "dry_run": true}
+	var plan configs.Plan
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/configs", remote.Host), remote.APIKey, payload, &plan); err != nil {
+		return fmt.Errorf("%s: %v", item.Path, err)
+	}
+	printWarnings(item.Path+": ", plan.Warnings)
+	item.Data = plan.Data
+	return nil
+}
+
+// sameSchema reports whether two schema documents are the same schema, the
+// way the server compares a sent schema with a bound one.
+func sameSchema(a, b map[string]interface{}) (bool, error) {
+	sa, err := decodeSchemaMap(a)
+	if err != nil {
+		return false, err
+	}
+	sb, err := decodeSchemaMap(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(sa, sb), nil
+}
+
+func decodeSchemaMap(m map[string]interface{}) (configs.Schema, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return configs.Schema{}, err
+	}
+	schema, _, err := configs.DecodeSchema(b, configs.FormatJSON)
+	return schema, err
+}
+
+// printApplyPlan prints the plan and returns the number of changes in it.
+func printApplyPlan(items []*applyItem) int {
+	created, updated, unchanged := 0, 0, 0
+	fmt.Println("Plan:")
+	for _, item := range items {
+		name := item.Env + "/" + item.Key
+		switch {
+		case !item.Changed:
+			unchanged++
+			fmt.Printf("  = %-40s unchanged (version %d)\n", name, item.Current)
+		case item.Current == 0:
+			created++
+			fmt.Printf("  + %-40s new key (version 1)\n", name)
+		default:
+			updated++
+			fmt.Printf("  ~ %-40s version %d -> %d\n", name, item.Current, item.Current+1)
+		}
+	}
+	fmt.Printf("\n%d to create, %d to update, %d unchanged.\n", created, updated, unchanged)
+	return created + updated
+}
+
+// sameJSON compares two values by their canonical JSON encoding, which
+// ignores differences such as int vs float64 for the same number.
+func sameJSON(a, b interface{}) bool {
+	ab, errA := json.Marshal(a)
+	bb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ab, bb)
+}
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
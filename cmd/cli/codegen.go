@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/clyvecute/configra/internal/codegen"
+)
+
+// runCodegen dispatches the `configra codegen <language>` group.
+func runCodegen(args []string) {
+	if len(args) < 1 {
+		printCodegenUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "go":
+		goCmd := flag.NewFlagSet("codegen go", flag.ExitOnError)
+		schemaPath := goCmd.String("schema", "schema.json", "Path to the schema file")
+		pkg := goCmd.String("package", "", "Go package name of the generated file")
+		typeName := goCmd.String("type", "Config", "Name of the generated struct")
+		out := goCmd.String("out", "", "Write to this file instead of stdout")
+		goCmd.Parse(args[1:])
+
+		_, schema, err := readSchemaFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		src, err := codegen.Go(schema, codegen.GoOptions{Package: *pkg, TypeName: *typeName})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		writeRaw(src, *out)
+	case "typescript", "ts":
+		tsCmd := flag.NewFlagSet("codegen typescript", flag.ExitOnError)
+		schemaPath := tsCmd.String("schema", "schema.json", "Path to the schema file")
+		typeName := tsCmd.String("type", "Config", "Name of the generated interface")
+		out := tsCmd.String("out", "", "Write to this file (e.g. config.d.ts) instead of stdout")
+		tsCmd.Parse(args[1:])
+
+		_, schema, err := readSchemaFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		src, err := codegen.TypeScript(schema, *typeName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		writeRaw(src, *out)
+	default:
+		printCodegenUsage()
+		os.Exit(1)
+	}
+}
+
+func printCodegenUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  codegen go -schema <path> -package <name> [-type <name>] [-out <path>]")
+	fmt.Println("                                           Generate Go structs from a schema")
+	fmt.Println("  codegen typescript -schema <path> [-type <name>] [-out <path>]")
+	fmt.Println("                                           Generate a TypeScript declaration file from a schema")
+}
+
+// writeRaw writes b to outFile, or stdout when empty.
+func writeRaw(b []byte, outFile string) {
+	if outFile == "" {
+		os.Stdout.Write(b)
+		return
+	}
+	if err := os.WriteFile(outFile, b, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", outFile, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", outFile)
+}
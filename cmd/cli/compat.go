@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+func runCompat(key, mode string, remote *remoteFlags) {
+	if key == "" || mode == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key and -mode are required")
+		os.Exit(1)
+	}
+	if _, err := configs.ParseCompatibilityMode(mode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	payload := map[string]interface{}{
+		"env":  remote.Env,
+		"key":  key,
+		"mode": mode,
+	}
+	var resp struct {
+		Compatibility string `json:"compatibility"`
+	}
+	if err := apiCall("PUT", fmt.Sprintf("%s/v1/configs/compatibility", remote.Host), remote.APIKey, payload, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set compatibility: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Schema changes to '%s' in %s must now be %s compatible.\n", key, remote.Env, resp.Compatibility)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/clyvecute/configra/internal/configs"
+	"github.com/clyvecute/configra/internal/middleware"
+)
+
+// runDeprecations lists the API keys that still read deprecated fields.
+func runDeprecations(key string, remote *remoteFlags) {
+	q := url.Values{}
+	if remote.Env != "" {
+		q.Set("env", remote.Env)
+	}
+	if key != "" {
+		q.Set("key", key)
+	}
+
+	var reads []configs.DeprecatedRead
+	if err := apiCall("GET", fmt.Sprintf("%s/v1/deprecations?%s", remote.Host, q.Encode()), remote.APIKey, nil, &reads); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list deprecated reads: %v\n", err)
+		os.Exit(1)
+	}
+	if len(reads) == 0 {
+		fmt.Println("No reads of deprecated fields recorded.")
+		return
+	}
+
+	self := middleware.KeyID(remote.APIKey)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "API KEY\tENV\tKEY\tFIELD\tREADS\tLAST READ\tUSER AGENT")
+	for _, r := range reads {
+		id := r.APIKeyID
+		if id == self {
+			id += " (this key)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%s\t%s\n", id, r.EnvID, r.Key, r.Field, r.Reads, r.LastReadAt.Format("2006-01-02 15:04"), r.UserAgent)
+	}
+	tw.Flush()
+}
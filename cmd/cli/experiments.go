@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/clyvecute/configra/internal/configs"
+	"github.com/clyvecute/configra/internal/featureflags"
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// runExperiment dispatches the `configra experiment <subcommand>` group.
+func runExperiment(args []string) {
+	if len(args) < 1 {
+		printExperimentUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		setCmd := flag.NewFlagSet("experiment set", flag.ExitOnError)
+		remote := addRemoteFlags(setCmd)
+		file := setCmd.String("file", "", "Experiment definition (JSON, YAML or TOML)")
+		setCmd.Parse(args[1:])
+		remote.resolve()
+		runExperimentSet(*file, remote)
+	case "get":
+		getCmd := flag.NewFlagSet("experiment get", flag.ExitOnError)
+		remote := addRemoteFlags(getCmd)
+		key := getCmd.String("key", "", "Experiment key")
+		out := getCmd.String("out", "", "Write the definition to this file instead of stdout")
+		getCmd.Parse(args[1:])
+		remote.resolve()
+		runExperimentGet(*key, *out, remote)
+	case "list":
+		listCmd := flag.NewFlagSet("experiment list", flag.ExitOnError)
+		remote := addRemoteFlags(listCmd)
+		listCmd.Parse(args[1:])
+		remote.resolve()
+		runExperimentList(remote)
+	case "delete":
+		deleteCmd := flag.NewFlagSet("experiment delete", flag.ExitOnError)
+		remote := addRemoteFlags(deleteCmd)
+		key := deleteCmd.String("key", "", "Experiment key")
+		deleteCmd.Parse(args[1:])
+		remote.resolve()
+		runExperimentDelete(*key, remote)
+	case "assign":
+		assignCmd := flag.NewFlagSet("experiment assign", flag.ExitOnError)
+		remote := addRemoteFlags(assignCmd)
+		key := assignCmd.String("key", "", "Experiment key")
+		contextFile := assignCmd.String("context", "", "Evaluation context file (JSON, YAML or TOML)")
+		var attrs stringList
+		assignCmd.Var(&attrs, "attr", "Context attribute as name=value, e.g. -attr key=user-42 (repeatable)")
+		assignCmd.Parse(args[1:])
+		remote.resolve()
+		runExperimentAssign(*key, *contextFile, attrs, remote)
+	default:
+		printExperimentUsage()
+		os.Exit(1)
+	}
+}
+
+func printExperimentUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  experiment set -file <path>              Create or replace an experiment from a definition file")
+	fmt.Println("  experiment get -key <key> [-out <path>]  Download an experiment definition")
+	fmt.Println("  experiment list                          List the experiments of an environment, by layer")
+	fmt.Println("  experiment delete -key <key>             Delete an experiment")
+	fmt.Println("  experiment assign -key <key> [-context <path>] [-attr name=value ...]")
+	fmt.Println("                                           Show which arm a context is assigned")
+}
+
+func runExperimentSet(file string, remote *remoteFlags) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "Error: -file is required")
+		os.Exit(1)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error reading experiment file: %v\n", err)
+		os.Exit(1)
+	}
+	var def flags.Experiment
+	if err := configs.DecodeInto(b, configs.FormatFromPath(file), &def); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error parsing experiment %s: %v\n", file, err)
+		os.Exit(1)
+	}
+
+	// Overlaps with the rest of the layer are only known to the server.
+	if errs := def.Validate(); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "❌ Experiment %s is invalid:\n", file)
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "  - %s\n", e)
+		}
+		os.Exit(1)
+	}
+
+	payload := map[string]interface{}{"env": remote.Env, "experiment": def}
+	var saved featureflags.Experiment
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/experiments", remote.Host), remote.APIKey, payload, &saved); err != nil {
+		fmt.Fprintf(os.Stderr, "Save failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Saved experiment '%s' in %s (version %d, %s).\n", saved.Key, remote.Env, saved.Version, runningState(saved.Running))
+}
+
+func runExperimentGet(key, outFile string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	var e featureflags.Experiment
+	if err := apiCall("GET", experimentURL(remote, key), remote.APIKey, nil, &e); err != nil {
+		fmt.Fprintf(os.Stderr, "Fetch failed: %v\n", err)
+		os.Exit(1)
+	}
+	// Write the bare definition, so it can be edited and sent back with set.
+	e.Experiment.Version = 0
+	writeOutput(e.Experiment, outFile)
+}
+
+func runExperimentList(remote *remoteFlags) {
+	var list []featureflags.Experiment
+	u := fmt.Sprintf("%s/v1/experiments?env=%s", remote.Host, url.QueryEscape(remote.Env))
+	if err := apiCall("GET", u, remote.APIKey, nil, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "List failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Printf("No experiments in %s.\n", remote.Env)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSTATE\tVERSION\tLAYER\tTRAFFIC\tARMS")
+	for _, e := range list {
+		layer := e.Layer
+		if layer == "" {
+			layer = "-"
+		}
+		arms := make([]string, len(e.Arms))
+		for i, arm := range e.Arms {
+			arms[i] = fmt.Sprintf("%s %v%%", arm.Name, arm.Weight)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%v-%v%%\t%s\n", e.Key, runningState(e.Running), e.Version, layer, e.Traffic.Start, e.Traffic.End, strings.Join(arms, " / "))
+	}
+	tw.Flush()
+}
+
+func runExperimentDelete(key string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	if err := apiCall("DELETE", experimentURL(remote, key), remote.APIKey, nil, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Delete failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Deleted experiment '%s' from %s.\n", key, remote.Env)
+}
+
+// runExperimentAssign shows the arm a context is assigned, without
+// recording an exposure.
+func runExperimentAssign(key, contextFile string, attrs []string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	payload := map[string]interface{}{"env": remote.Env, "context": evalContext(contextFile, attrs)}
+	var a flags.Assignment
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/experiments/%s/assign", remote.Host, url.PathEscape(key)), remote.APIKey, payload, &a); err != nil {
+		fmt.Fprintf(os.Stderr, "Assignment failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXPERIMENT\tARM\tVALUE\tUNIT\tREASON")
+	value, _ := json.Marshal(a.Value)
+	reason := a.Reason
+	if a.Error != "" {
+		reason += ": " + a.Error
+	}
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Experiment, a.Arm, value, a.Unit, reason)
+	tw.Flush()
+}
+
+func experimentURL(remote *remoteFlags, key string) string {
+	return fmt.Sprintf("%s/v1/experiments/%s?env=%s", remote.Host, url.PathEscape(key), url.QueryEscape(remote.Env))
+}
+
+func runningState(running bool) string {
+	if running {
+		return "running"
+	}
+	return "stopped"
+}
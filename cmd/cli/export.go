@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+func runExport(key, format string, version int, separator, name, namespace, outFile string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+
+	q := url.Values{}
+	q.Set("env", remote.Env)
+	q.Set("key", key)
+	q.Set("format", format)
+	if version > 0 {
+		q.Set("version", strconv.Itoa(version))
+	}
+	if separator != "" {
+		q.Set("separator", separator)
+	}
+	if name != "" {
+		q.Set("name", name)
+	}
+	if namespace != "" {
+		q.Set("namespace", namespace)
+	}
+
+	out, err := apiRaw("GET", fmt.Sprintf("%s/v1/export?%s", remote.Host, q.Encode()), remote.APIKey, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if outFile == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(outFile, out, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", outFile, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Exported '%s' as %s to %s\n", key, format, outFile)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/clyvecute/configra/internal/configs"
+	"gopkg.in/yaml.v3"
+)
+
+func runFetch(key, format, outFile string, defaults bool, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+
+	q := url.Values{}
+	q.Set("env", remote.Env)
+	q.Set("key", key)
+	if defaults {
+		q.Set("defaults", "true")
+	}
+
+	var cfg configs.Config
+	if err := apiCall("GET", fmt.Sprintf("%s/v1/configs?%s", remote.Host, q.Encode()), remote.APIKey, nil, &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Fetch failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The API key decides the project; -project only guards against using the wrong key.
+	if remote.Project != "" {
+		if pID, err := strconv.Atoi(remote.Project); err != nil || pID != cfg.ProjectID {
+			fmt.Fprintf(os.Stderr, "Error: API key belongs to project %d, not %s\n", cfg.ProjectID, remote.Project)
+			os.Exit(1)
+		}
+	}
+
+	printWarnings("", cfg.Warnings)
+
+	out, err := renderConfig(cfg.Data, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if outFile == "" {
+		fmt.Print(out)
+		return
+	}
+	if err := os.WriteFile(outFile, []byte(out), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", outFile, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote '%s' version %d to %s\n", key, cfg.Version, outFile)
+}
+
+// renderConfig serializes config data in one of the fetch output formats.
+func renderConfig(data configs.Map, format string) (string, error) {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "yaml":
+		b, err := yaml.Marshal(map[string]interface{}(data))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case "dotenv":
+		return configs.RenderDotenv(data, "_"), nil
+	case "shell":
+		return configs.RenderShell(data, "_"), nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want json, yaml, dotenv or shell)", format)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// configExts are the file extensions accepted for configs and schemas.
+var configExts = []string{".json", ".yaml", ".yml", ".toml"}
+
+// readConfigFile reads and parses a JSON, YAML or TOML config file.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	config, err := configs.DecodeMap(b, configs.FormatFromPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// readSchemaFile reads a JSON, YAML or TOML schema file, returning both the
+// raw map sent to the API and the typed Schema used for local validation.
+func readSchemaFile(path string) (map[string]interface{}, configs.Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, configs.Schema{}, fmt.Errorf("error reading schema file: %v", err)
+	}
+	schema, raw, err := configs.DecodeSchema(b, configs.FormatFromPath(path))
+	if err != nil {
+		return nil, schema, fmt.Errorf("error parsing schema %s: %v", path, err)
+	}
+	return raw, schema, nil
+}
+
+// splitConfigExt splits "app.yaml" into ("app", true). Files with other
+// extensions return false.
+func splitConfigExt(name string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range configExts {
+		if ext == e {
+			return strings.TrimSuffix(name, filepath.Ext(name)), true
+		}
+	}
+	return "", false
+}
+
+// findSchemaFile returns the first existing <base>.schema.<ext> path.
+func findSchemaFile(base string) (string, bool) {
+	for _, ext := range configExts {
+		if _, err := os.Stat(base + ".schema" + ext); err == nil {
+			return base + ".schema" + ext, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/clyvecute/configra/internal/configs"
+	"github.com/clyvecute/configra/internal/featureflags"
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// runFlag dispatches the `configra flag <subcommand>` group.
+func runFlag(args []string) {
+	if len(args) < 1 {
+		printFlagUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		setCmd := flag.NewFlagSet("flag set", flag.ExitOnError)
+		remote := addRemoteFlags(setCmd)
+		file := setCmd.String("file", "", "Flag definition (JSON, YAML or TOML)")
+		setCmd.Parse(args[1:])
+		remote.resolve()
+		runFlagSet(*file, remote)
+	case "get":
+		getCmd := flag.NewFlagSet("flag get", flag.ExitOnError)
+		remote := addRemoteFlags(getCmd)
+		key := getCmd.String("key", "", "Flag key")
+		out := getCmd.String("out", "", "Write the definition to this file instead of stdout")
+		getCmd.Parse(args[1:])
+		remote.resolve()
+		runFlagGet(*key, *out, remote)
+	case "list":
+		listCmd := flag.NewFlagSet("flag list", flag.ExitOnError)
+		remote := addRemoteFlags(listCmd)
+		listCmd.Parse(args[1:])
+		remote.resolve()
+		runFlagList(remote)
+	case "delete":
+		deleteCmd := flag.NewFlagSet("flag delete", flag.ExitOnError)
+		remote := addRemoteFlags(deleteCmd)
+		key := deleteCmd.String("key", "", "Flag key")
+		deleteCmd.Parse(args[1:])
+		remote.resolve()
+		runFlagDelete(*key, remote)
+	case "eval":
+		evalCmd := flag.NewFlagSet("flag eval", flag.ExitOnError)
+		remote := addRemoteFlags(evalCmd)
+		key := evalCmd.String("key", "", "Flag key (default: evaluate every flag)")
+		contextFile := evalCmd.String("context", "", "Evaluation context file (JSON, YAML or TOML)")
+		var attrs stringList
+		evalCmd.Var(&attrs, "attr", "Context attribute as name=value, e.g. -attr country=DE (repeatable)")
+		evalCmd.Parse(args[1:])
+		remote.resolve()
+		runFlagEval(*key, *contextFile, attrs, remote)
+	case "stale":
+		staleCmd := flag.NewFlagSet("flag stale", flag.ExitOnError)
+		remote := addRemoteFlags(staleCmd)
+		unusedDays := staleCmd.Int("unused-days", featureflags.DefaultUnusedDays, "Report flags not evaluated for this many days")
+		singleDays := staleCmd.Int("single-days", featureflags.DefaultSingleDays, "Report flags serving one variation, unchanged, for this many days")
+		staleCmd.Parse(args[1:])
+		remote.resolve()
+		runFlagStale(*unusedDays, *singleDays, remote)
+	default:
+		printFlagUsage()
+		os.Exit(1)
+	}
+}
+
+func printFlagUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  flag set -file <path>                    Create or replace a flag from a definition file")
+	fmt.Println("  flag get -key <key> [-out <path>]        Download a flag definition")
+	fmt.Println("  flag list                                List the flags of an environment")
+	fmt.Println("  flag delete -key <key>                   Delete a flag")
+	fmt.Println("  flag eval [-key <key>] [-context <path>] [-attr name=value ...]")
+	fmt.Println("                                           Show which variation a context gets, and why")
+	fmt.Println("  flag stale [-unused-days 30] [-single-days 14]")
+	fmt.Println("                                           List flags that look ready for cleanup")
+}
+
+func runFlagSet(file string, remote *remoteFlags) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "Error: -file is required")
+		os.Exit(1)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error reading flag file: %v\n", err)
+		os.Exit(1)
+	}
+	var def flags.Flag
+	if err := configs.DecodeInto(b, configs.FormatFromPath(file), &def); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error parsing flag %s: %v\n", file, err)
+		os.Exit(1)
+	}
+
+	// Check locally first, so every problem is listed before anything is sent.
+	if errs := def.Validate(); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "❌ Flag %s is invalid:\n", file)
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "  - %s\n", e)
+		}
+		os.Exit(1)
+	}
+
+	payload := map[string]interface{}{"env": remote.Env, "flag": def}
+	var saved featureflags.Flag
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/flags", remote.Host), remote.APIKey, payload, &saved); err != nil {
+		fmt.Fprintf(os.Stderr, "Save failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Saved flag '%s' in %s (version %d, %s).\n", saved.Key, remote.Env, saved.Version, onOff(saved.On))
+}
+
+func runFlagGet(key, outFile string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	var f featureflags.Flag
+	if err := apiCall("GET", flagURL(remote, key), remote.APIKey, nil, &f); err != nil {
+		fmt.Fprintf(os.Stderr, "Fetch failed: %v\n", err)
+		os.Exit(1)
+	}
+	// Write the bare definition, so it can be edited and sent back with set.
+	f.Flag.Version = 0
+	writeOutput(f.Flag, outFile)
+}
+
+func runFlagList(remote *remoteFlags) {
+	var list []featureflags.Flag
+	u := fmt.Sprintf("%s/v1/flags?env=%s", remote.Host, url.QueryEscape(remote.Env))
+	if err := apiCall("GET", u, remote.APIKey, nil, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "List failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Printf("No flags in %s.\n", remote.Env)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSTATE\tVERSION\tVARIATIONS\tRULES\tDEFAULT")
+	for _, f := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", f.Key, onOff(f.On), f.Version, len(f.Variations), len(f.Rules), describeServe(f.Default))
+	}
+	tw.Flush()
+}
+
+func runFlagDelete(key string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	if err := apiCall("DELETE", flagURL(remote, key), remote.APIKey, nil, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Delete failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Deleted flag '%s' from %s.\n", key, remote.Env)
+}
+
+// runFlagStale prints the stale flag report of an environment.
+func runFlagStale(unusedDays, singleDays int, remote *remoteFlags) {
+	var stale []featureflags.StaleFlag
+	u := fmt.Sprintf("%s/v1/stale-flags?env=%s&unused_days=%d&single_days=%d", remote.Host, url.QueryEscape(remote.Env), unusedDays, singleDays)
+	if err := apiCall("GET", u, remote.APIKey, nil, &stale); err != nil {
+		fmt.Fprintf(os.Stderr, "Report failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(stale) == 0 {
+		fmt.Printf("No stale flags in %s.\n", remote.Env)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSTATE\tWHY\tLAST EVALUATED\tLAST CHANGED")
+	for _, f := range stale {
+		why := fmt.Sprintf("not evaluated for %d days", unusedDays)
+		if f.Reason == featureflags.StaleSingleVariation {
+			why = fmt.Sprintf("served only '%s' (%d times) for %d days", f.Variation, f.Evaluations, singleDays)
+		}
+		last := "never"
+		if f.LastEvaluated != nil {
+			last = f.LastEvaluated.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Flag, onOff(f.On), why, last, f.UpdatedAt.Format("2006-01-02"))
+	}
+	tw.Flush()
+}
+
+// runFlagEval evaluates one flag, or all of them, for a context assembled
+// from a file and -attr overrides.
+func runFlagEval(key, contextFile string, attrs []string, remote *remoteFlags) {
+	payload := map[string]interface{}{"env": remote.Env, "context": evalContext(contextFile, attrs)}
+	var results []flags.Result
+	if key != "" {
+		var res flags.Result
+		if err := apiCall("POST", fmt.Sprintf("%s/v1/flags/%s/evaluate", remote.Host, url.PathEscape(key)), remote.APIKey, payload, &res); err != nil {
+			fmt.Fprintf(os.Stderr, "Evaluation failed: %v\n", err)
+			os.Exit(1)
+		}
+		results = append(results, res)
+	} else if err := apiCall("POST", fmt.Sprintf("%s/v1/evaluate", remote.Host), remote.APIKey, payload, &results); err != nil {
+		fmt.Fprintf(os.Stderr, "Evaluation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FLAG\tVARIATION\tVALUE\tREASON")
+	for _, res := range results {
+		value, _ := json.Marshal(res.Value)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Key, res.Variation, value, describeReason(res.Reason))
+	}
+	tw.Flush()
+}
+
+// evalContext assembles an evaluation context from a file and -attr
+// overrides.
+func evalContext(contextFile string, attrs []string) flags.Context {
+	ctx := flags.Context{}
+	if contextFile != "" {
+		m, err := readConfigFile(contextFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for k, v := range m {
+			ctx[k] = v
+		}
+	}
+	for _, a := range attrs {
+		name, value, ok := strings.Cut(a, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "Error: -attr %q must be name=value\n", a)
+			os.Exit(1)
+		}
+		ctx[name] = attrValue(value)
+	}
+	return ctx
+}
+
+// attrValue reads numbers, booleans and JSON values as such; anything else,
+// including versions like 2.3.1, is a string.
+func attrValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+	return s
+}
+
+func describeReason(r flags.Reason) string {
+	switch {
+	case r.RuleID != "":
+		return fmt.Sprintf("%s (%s)", r.Kind, r.RuleID)
+	case r.Kind == flags.ReasonError:
+		return fmt.Sprintf("%s: %s", r.Kind, r.Error)
+	}
+	return r.Kind
+}
+
+// describeServe summarizes what a rule or default serves, e.g.
+// "on 25% / off 75%" for a rollout.
+func describeServe(s flags.Serve) string {
+	if s.Rollout == nil {
+		return s.Variation
+	}
+	parts := make([]string, len(s.Rollout.Variations))
+	for i, wv := range s.Rollout.Variations {
+		parts[i] = fmt.Sprintf("%s %v%%", wv.Variation, wv.Weight)
+	}
+	return strings.Join(parts, " / ")
+}
+
+func flagURL(remote *remoteFlags, key string) string {
+	return fmt.Sprintf("%s/v1/flags/%s?env=%s", remote.Host, url.PathEscape(key), url.QueryEscape(remote.Env))
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runLogin verifies an API key against the server and stores it in a profile.
+func runLogin(remote *remoteFlags, makeDefault bool) {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	name := remote.Profile
+	if name == "" {
+		name = os.Getenv("CONFIGRA_PROFILE")
+	}
+	if name == "" {
+		name = "default"
+	}
+	p := cfg.Profiles[name]
+	if p == nil {
+		p = &Profile{}
+	}
+
+	if remote.Host != "" {
+		p.Host = strings.TrimRight(remote.Host, "/")
+	} else if p.Host == "" {
+		p.Host = defaultHost
+	}
+	if remote.Env != "" {
+		p.Env = remote.Env
+	}
+
+	apiKey := remote.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("CONFIGRA_API_KEY")
+	}
+	if apiKey == "" {
+		fmt.Fprintf(os.Stderr, "API key for %s: ", p.Host)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		apiKey = strings.TrimSpace(line)
+	}
+	if apiKey == "" {
+		fmt.Fprintln(os.Stderr, "Error: no API key given")
+		os.Exit(1)
+	}
+
+	var project struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := apiCall("GET", fmt.Sprintf("%s/v1/project", p.Host), apiKey, nil, &project); err != nil {
+		fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	p.APIKey = apiKey
+	p.Project = strconv.Itoa(project.ID)
+	cfg.Profiles[name] = p
+	if cfg.CurrentProfile == "" || makeDefault {
+		cfg.CurrentProfile = name
+	}
+	if err := cfg.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving CLI config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Logged in to project %d (%s) on %s as profile '%s'.\n", project.ID, project.Name, p.Host, name)
+}
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
 
 	"github.com/clyvecute/configra/internal/config"
@@ -25,20 +22,52 @@ func main() {
 	configPath := validateCmd.String("config", "config.json", "Path to the configuration file")
 
 	pushCmd := flag.NewFlagSet("push", flag.ExitOnError)
-	_ = pushCmd.String("file", "config.json", "Config file to push")
-	_ = pushCmd.String("project", "", "Project ID")
-	pushHost := pushCmd.String("host", "http://localhost:8080", "API Host URL")
+	pushRemote := addRemoteFlags(pushCmd)
+	pushFile := pushCmd.String("file", "config.json", "Config file to push")
+	pushSchema := pushCmd.String("schema", "", "Schema file to validate and push with the config (default schema.json if present; not needed for keys bound to a registered schema)")
+	pushKey := pushCmd.String("key", "", "Config Key")
+	pushDryRun := pushCmd.Bool("dry-run", false, "Validate and show the would-be version and diff without committing")
 
 	fetchCmd := flag.NewFlagSet("fetch", flag.ExitOnError)
-	_ = fetchCmd.String("project", "", "Project ID")
-	_ = fetchCmd.String("env", "prod", "Environment")
-	// fetchHost := fetchCmd.String("host", "http://localhost:8080", "API Host URL") // Uncomment when implementing fetch
+	fetchRemote := addRemoteFlags(fetchCmd)
+	fetchKey := fetchCmd.String("key", "", "Config Key")
+	fetchFormat := fetchCmd.String("format", "json", "Output format: json, yaml, dotenv or shell")
+	fetchOut := fetchCmd.String("out", "", "Write to this file instead of stdout")
+	fetchDefaults := fetchCmd.Bool("defaults", false, "Fill in schema defaults for fields that were not written")
 
 	rollbackCmd := flag.NewFlagSet("rollback", flag.ExitOnError)
-	_ = rollbackCmd.String("project", "", "Project ID")
-	_ = rollbackCmd.String("key", "", "Config Key")
-	_ = rollbackCmd.String("version", "", "Target Version to restore")
-	rollbackHost := rollbackCmd.String("host", "http://localhost:8080", "API Host URL")
+	rollbackRemote := addRemoteFlags(rollbackCmd)
+	rollbackKey := rollbackCmd.String("key", "", "Config Key")
+	rollbackVersion := rollbackCmd.Int("version", 0, "Target Version to restore")
+	rollbackDryRun := rollbackCmd.Bool("dry-run", false, "Show the would-be version and diff without committing")
+
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	exportRemote := addRemoteFlags(exportCmd)
+	exportKey := exportCmd.String("key", "", "Config Key")
+	exportFormat := exportCmd.String("format", "configmap", "Output format: configmap, dotenv or properties")
+	exportVersion := exportCmd.Int("version", 0, "Version to export (default: latest)")
+	exportSeparator := exportCmd.String("separator", "", "Separator for flattened nested keys (default \".\", \"_\" for dotenv)")
+	exportName := exportCmd.String("name", "", "Kubernetes object name (default: derived from the key)")
+	exportNamespace := exportCmd.String("namespace", "", "Kubernetes namespace")
+	exportOut := exportCmd.String("out", "", "Write to this file instead of stdout")
+
+	applyCmd := flag.NewFlagSet("apply", flag.ExitOnError)
+	applyRemote := addRemoteFlags(applyCmd)
+	applyDir := applyCmd.String("dir", "configs", "Directory laid out as <env>/<key>.json")
+	applyYes := applyCmd.Bool("yes", false, "Apply the plan without asking for confirmation")
+
+	compatCmd := flag.NewFlagSet("compat", flag.ExitOnError)
+	compatRemote := addRemoteFlags(compatCmd)
+	compatKey := compatCmd.String("key", "", "Config Key")
+	compatMode := compatCmd.String("mode", "", "Schema compatibility mode: backward, forward, full or none")
+
+	deprecationsCmd := flag.NewFlagSet("deprecations", flag.ExitOnError)
+	deprecationsRemote := addRemoteFlags(deprecationsCmd)
+	deprecationsKey := deprecationsCmd.String("key", "", "Only report this config key")
+
+	loginCmd := flag.NewFlagSet("login", flag.ExitOnError)
+	loginRemote := addRemoteFlags(loginCmd)
+	loginDefault := loginCmd.Bool("default", false, "Make this the current profile")
 
 	switch os.Args[1] {
 	case "validate":
@@ -46,24 +75,52 @@ func main() {
 		runValidate(*schemaPath, *configPath)
 	case "push":
 		pushCmd.Parse(os.Args[2:])
-		file := "config.json"
-		if f := pushCmd.Lookup("file"); f != nil {
-			file = f.Value.String()
-		}
-		proj := ""
-		if p := pushCmd.Lookup("project"); p != nil {
-			proj = p.Value.String()
-		}
-		runPush(file, proj, *pushHost)
+		pushRemote.resolve()
+		runPush(*pushFile, *pushSchema, *pushKey, *pushDryRun, pushRemote)
+	case "plan":
+		// Alias for push -dry-run, for pull-request checks
+		pushCmd.Parse(os.Args[2:])
+		pushRemote.resolve()
+		runPush(*pushFile, *pushSchema, *pushKey, true, pushRemote)
 	case "fetch":
 		fetchCmd.Parse(os.Args[2:])
-		fmt.Println("Fetch logic to be implemented. Would fetch from API.")
+		fetchRemote.resolve()
+		runFetch(*fetchKey, *fetchFormat, *fetchOut, *fetchDefaults, fetchRemote)
 	case "rollback":
 		rollbackCmd.Parse(os.Args[2:])
-		p := ""; if f := rollbackCmd.Lookup("project"); f != nil { p = f.Value.String() }
-		k := ""; if f := rollbackCmd.Lookup("key"); f != nil { k = f.Value.String() }
-		v := ""; if f := rollbackCmd.Lookup("version"); f != nil { v = f.Value.String() }
-		runRollback(p, k, v, *rollbackHost)
+		rollbackRemote.resolve()
+		runRollback(*rollbackKey, *rollbackVersion, *rollbackDryRun, rollbackRemote)
+	case "export":
+		exportCmd.Parse(os.Args[2:])
+		exportRemote.resolve()
+		runExport(*exportKey, *exportFormat, *exportVersion, *exportSeparator, *exportName, *exportNamespace, *exportOut, exportRemote)
+	case "apply":
+		applyCmd.Parse(os.Args[2:])
+		applyRemote.resolve()
+		runApply(*applyDir, *applyYes, applyRemote)
+	case "compat":
+		compatCmd.Parse(os.Args[2:])
+		compatRemote.resolve()
+		runCompat(*compatKey, *compatMode, compatRemote)
+	case "deprecations":
+		deprecationsCmd.Parse(os.Args[2:])
+		deprecationsRemote.resolve()
+		runDeprecations(*deprecationsKey, deprecationsRemote)
+	case "login":
+		loginCmd.Parse(os.Args[2:])
+		runLogin(loginRemote, *loginDefault)
+	case "profiles":
+		runProfiles()
+	case "schema":
+		runSchema(os.Args[2:])
+	case "codegen":
+		runCodegen(os.Args[2:])
+	case "flag":
+		runFlag(os.Args[2:])
+	case "segment":
+		runSegment(os.Args[2:])
+	case "experiment":
+		runExperiment(os.Args[2:])
 	case "migrate":
 		// Ensure we load config to get DB creds
 		runMigrate()
@@ -77,9 +134,36 @@ func printUsage() {
 	fmt.Println("Configra CLI")
 	fmt.Println("Usage:")
 	fmt.Println("  validate -schema <path> -config <path>   Validate a config against a schema locally")
-	fmt.Println("  push     -file <path> -project <id>      Push a config to the server")
-	fmt.Println("  fetch    -project <id> -env <name>       Fetch active config from server")
+	fmt.Println("  push     -file <path> -schema <path>     Push a config to the server")
+	fmt.Println("           -env <name> -key <key> [-dry-run]")
+	fmt.Println("  plan     (same flags as push)            Dry-run a push: show the would-be version and diff")
+	fmt.Println("  fetch    -env <name> -key <key>          Fetch active config from server")
+	fmt.Println("           [-format json|yaml|dotenv|shell] [-out <path>]")
+	fmt.Println("  rollback -env <name> -key <key> -version <n> [-dry-run]")
+	fmt.Println("                                           Restore a previous version as the latest")
+	fmt.Println("  export   -env <name> -key <key>          Render a config as a Kubernetes manifest, .env or .properties")
+	fmt.Println("           [-format configmap|dotenv|properties] [-version <n>] [-separator <s>]")
+	fmt.Println("  apply    -dir <path> [-yes]              Validate a config tree, show the plan and push changes")
+	fmt.Println("  compat   -env <name> -key <key> -mode backward|forward|full|none")
+	fmt.Println("                                           Set which schema changes a key accepts")
+	fmt.Println("  deprecations [-env <name>] [-key <key>]  List API keys still reading deprecated fields")
+	fmt.Println("  schema infer -config <path> ...          Generate a schema from sample configs")
+	fmt.Println("  schema to-jsonschema | from-jsonschema   Convert between Configra schemas and JSON Schema")
+	fmt.Println("  schema register | get | list | bind      Manage registered schemas and bind keys to them")
+	fmt.Println("  flag set | get | list | delete           Manage feature flags")
+	fmt.Println("  flag eval [-key <key>] -attr name=value  Show which variation a context gets, and why")
+	fmt.Println("  flag stale                               List flags nobody checks or that serve one variation")
+	fmt.Println("  segment set | get | list | usage | delete Manage segments that flag rules target")
+	fmt.Println("  experiment set | get | list | delete     Manage experiments, their layers and arms")
+	fmt.Println("  experiment assign -key <key> -attr name=value Show which arm a context is assigned")
+	fmt.Println("  codegen go -schema <path> -package <name> Generate Go structs from a schema")
+	fmt.Println("  codegen typescript -schema <path>        Generate a TypeScript declaration file from a schema")
+	fmt.Println("  login    -profile <name> -host <url>     Store an API key in a named profile")
+	fmt.Println("  profiles                                 List configured profiles")
 	fmt.Println("  migrate                                  Run database migrations")
+	fmt.Println("")
+	fmt.Println("Commands talking to the server accept -profile, -host, -api-key, -project and -env.")
+	fmt.Println("Unset flags fall back to $CONFIGRA_HOST / $CONFIGRA_API_KEY, then the selected profile.")
 }
 
 func runMigrate() {
@@ -106,130 +190,128 @@ func runMigrate() {
 func runValidate(schemaFile, configFile string) {
 	fmt.Printf("Validating %s against %s...\n", configFile, schemaFile)
 
-	// Read Schema
-	sBytes, err := os.ReadFile(schemaFile)
+	_, schema, err := readSchemaFile(schemaFile)
 	if err != nil {
-		fmt.Printf("Error reading schema file: %v\n", err)
-		os.Exit(1)
-	}
-
-	var schema configs.Schema
-	if err := json.Unmarshal(sBytes, &schema); err != nil {
-		fmt.Printf("Error parsing schema JSON: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Read Config
-	cBytes, err := os.ReadFile(configFile)
+	config, err := readConfigFile(configFile)
 	if err != nil {
-		fmt.Printf("Error reading config file: %v\n", err)
-		os.Exit(1)
-	}
-
-	var config map[string]interface{}
-	if err := json.Unmarshal(cBytes, &config); err != nil {
-		fmt.Printf("Error parsing config JSON: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Validate
-	if err := configs.Validate(schema, config); err != nil {
+	warnings, err := configs.Check(schema, config)
+	if err != nil {
 		fmt.Printf("\u274C Validation FAILED: %v\n", err)
 		os.Exit(1)
 	}
 
+	printWarnings("", warnings)
 	fmt.Println("\u2705 Configuration is VALID.")
 }
 
-func runPush(configFile, projectID, host string) {
-	// 1. Read the config file and assumed schema file (for now co-located or we should bundle them)
-	// For this demo, let's assume schema.json is in the same dir
-	schemaFile := "schema.json"
-	
-	cBytes, err := os.ReadFile(configFile)
-	if err != nil {
-		fmt.Printf("Error reading config: %v\n", err)
+// printWarnings reports non-fatal validation findings on stderr.
+func printWarnings(prefix string, warnings []string) {
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s%s\n", prefix, w)
+	}
+}
+
+func runPush(configFile, schemaFile, key string, dryRun bool, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
 		os.Exit(1)
 	}
-	sBytes, err := os.ReadFile(schemaFile)
+
+	// 1. Read and parse the config and its schema
+	configMap, err := readConfigFile(configFile)
 	if err != nil {
-		fmt.Printf("Error reading schema: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if schemaFile == "" {
+		if _, err := os.Stat("schema.json"); err == nil {
+			schemaFile = "schema.json"
+		}
+	}
 
-	var configMap map[string]interface{}
+	// 2. Validate locally first. Keys bound to a registered schema need no
+	// schema file; the server validates against the registered one.
 	var schemaMap map[string]interface{}
-	json.Unmarshal(cBytes, &configMap)
-	json.Unmarshal(sBytes, &schemaMap)
-	
-	// 2. Validate locally first
-	var schemaStruct configs.Schema
-	json.Unmarshal(sBytes, &schemaStruct)
-	if err := configs.Validate(schemaStruct, configMap); err != nil {
-		fmt.Printf("Validation failed locally: %v\n", err)
-		os.Exit(1)
+	if schemaFile != "" {
+		var schemaStruct configs.Schema
+		schemaMap, schemaStruct, err = readSchemaFile(schemaFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := configs.Validate(schemaStruct, configMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Validation failed locally: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// 3. Send to API
-	// Construct payload matching CreateRequest in handler
-	// We need to parse projectID to int, let's default to 1 if empty for push demo
-	pID := 1 // Default
-	eID := 1 // Default env
-	
+	// 3. Send to API. The server derives the project from the API key.
+	remote.checkProject()
 	payload := map[string]interface{}{
-		"project_id": pID,
-		"env_id":     eID,
-		"key":        "feature_flags", // Default key for now
-		"data":       configMap,
-		"schema":     schemaMap,
-	}
-	
-	body, _ := json.Marshal(payload)
-	
-	resp, err := http.Post(fmt.Sprintf("%s/v1/configs", host), "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		fmt.Printf("Failed to connect to API: %v\n", err)
-		os.Exit(1)
+		"env":     remote.Env,
+		"key":     key,
+		"data":    configMap,
+		"schema":  schemaMap,
+		"dry_run": dryRun,
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		fmt.Printf("API returned error: %s\n", resp.Status)
-		// Read body for details
+	if dryRun {
+		var plan configs.Plan
+		if err := apiCall("POST", fmt.Sprintf("%s/v1/configs", remote.Host), remote.APIKey, payload, &plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Dry run failed: %v\n", err)
+			os.Exit(1)
+		}
+		printPlan(&plan)
 		return
 	}
 
-	fmt.Println("Successfully pushed config to server!")
+	var cfg configs.Config
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/configs", remote.Host), remote.APIKey, payload, &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Push failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	printWarnings("", cfg.Warnings)
+	fmt.Printf("\u2705 Pushed '%s' to %s as version %d.\n", key, remote.Env, cfg.Version)
 }
 
-func runRollback(projectID, key, version, host string) {
-	// Simple conversions
-	pID := 1 // default
-	vID := 0
-	fmt.Sscanf(version, "%d", &vID)
+func runRollback(key string, version int, dryRun bool, remote *remoteFlags) {
+	if key == "" || version <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -key and a positive -version are required")
+		os.Exit(1)
+	}
 
+	remote.checkProject()
 	payload := map[string]interface{}{
-		"project_id":     pID,
-		"env_id":         1, // default
+		"env":            remote.Env,
 		"key":            key,
-		"target_version": vID,
+		"target_version": version,
+		"dry_run":        dryRun,
 	}
 
-	body, _ := json.Marshal(payload)
-	resp, err := http.Post(fmt.Sprintf("%s/v1/rollback", host), "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		fmt.Printf("Failed to connect to API: %v\n", err)
-		os.Exit(1)
+	if dryRun {
+		var plan configs.Plan
+		if err := apiCall("POST", fmt.Sprintf("%s/v1/rollback", remote.Host), remote.APIKey, payload, &plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Dry run failed: %v\n", err)
+			os.Exit(1)
+		}
+		printPlan(&plan)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Rollback failed: %s\n", resp.Status)
-		return
+	var cfg configs.Config
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/rollback", remote.Host), remote.APIKey, payload, &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Rollback failed: %v\n", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("\u2705 Successfully rolled back '%s' to version %s!\n", key, version)
+	fmt.Printf("\u2705 Rolled back '%s' to the content of version %d (now version %d).\n", key, version, cfg.Version)
 }
-
-// Add these imports at the top if missing: bytes, net/http
-
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// printPlan prints the result of a dry-run write.
+func printPlan(plan *configs.Plan) {
+	if plan.CurrentVersion == 0 {
+		fmt.Printf("Dry run: '%s' does not exist yet; would create version %d.\n", plan.Key, plan.Version)
+	} else {
+		fmt.Printf("Dry run: would create version %d of '%s' (current: %d).\n", plan.Version, plan.Key, plan.CurrentVersion)
+	}
+	printWarnings("", plan.Warnings)
+
+	if len(plan.Diff) == 0 {
+		fmt.Println("No changes to data.")
+		return
+	}
+	for _, c := range plan.Diff {
+		switch c.Op {
+		case configs.OpAdded:
+			fmt.Printf("  + %s: %s\n", c.Path, planValue(c.New))
+		case configs.OpRemoved:
+			fmt.Printf("  - %s: %s\n", c.Path, planValue(c.Old))
+		default:
+			fmt.Printf("  ~ %s: %s -> %s\n", c.Path, planValue(c.Old), planValue(c.New))
+		}
+	}
+}
+
+func planValue(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+const defaultHost = "http://localhost:8080"
+
+// Profile holds the connection settings for one Configra deployment.
+type Profile struct {
+	Host    string `json:"host"`
+	Project string `json:"project,omitempty"`
+	Env     string `json:"env,omitempty"`
+	APIKey  string `json:"api_key,omitempty"`
+}
+
+// CLIConfig is the on-disk CLI configuration, stored at
+// ~/.config/configra/config.json ($XDG_CONFIG_HOME/configra/config.json if
+// set, or $CONFIGRA_CONFIG).
+type CLIConfig struct {
+	CurrentProfile string              `json:"current_profile"`
+	Profiles       map[string]*Profile `json:"profiles"`
+}
+
+func cliConfigPath() (string, error) {
+	if p := os.Getenv("CONFIGRA_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "configra", "config.json"), nil
+}
+
+// loadCLIConfig reads the CLI config file. A missing file is not an error.
+func loadCLIConfig() (*CLIConfig, error) {
+	cfg := &CLIConfig{Profiles: map[string]*Profile{}}
+	path, err := cliConfigPath()
+	if err != nil {
+		return cfg, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return cfg, fmt.Errorf("invalid CLI config %s: %v", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// save writes the config file with owner-only permissions, as it holds API keys.
+func (c *CLIConfig) save() error {
+	path, err := cliConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o600)
+}
+
+// remoteFlags are the connection flags shared by every subcommand.
+// Values resolve in order: explicit flag, environment variable, profile,
+// default. An explicit -profile takes precedence over environment variables.
+type remoteFlags struct {
+	fs      *flag.FlagSet
+	Profile string
+	Host    string
+	APIKey  string
+	Project string
+	Env     string
+}
+
+func addRemoteFlags(fs *flag.FlagSet) *remoteFlags {
+	f := &remoteFlags{fs: fs}
+	fs.StringVar(&f.Profile, "profile", "", "CLI profile to use (default $CONFIGRA_PROFILE or the current profile)")
+	fs.StringVar(&f.Host, "host", "", "API Host URL (default $CONFIGRA_HOST, the profile's host or "+defaultHost+")")
+	fs.StringVar(&f.APIKey, "api-key", "", "Project API key (default $CONFIGRA_API_KEY or the profile's key)")
+	fs.StringVar(&f.Project, "project", "", "Project ID (default: the profile's project)")
+	fs.StringVar(&f.Env, "env", "", "Environment slug or ID (default: the profile's env or prod)")
+	return f
+}
+
+// resolve fills unset flags from the environment and the selected profile.
+// It must be called after the FlagSet has been parsed.
+func (f *remoteFlags) resolve() {
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if f.Profile == "" {
+		f.Profile = os.Getenv("CONFIGRA_PROFILE")
+	}
+	if f.Profile == "" {
+		f.Profile = cfg.CurrentProfile
+	}
+	p := cfg.Profiles[f.Profile]
+	if p == nil {
+		if set["profile"] || os.Getenv("CONFIGRA_PROFILE") != "" {
+			fmt.Fprintf(os.Stderr, "Error: unknown profile %q\n", f.Profile)
+			os.Exit(1)
+		}
+		p = &Profile{}
+	}
+
+	pick := func(dst *string, name, env, fromProfile, fallback string) {
+		if set[name] {
+			return
+		}
+		if v := os.Getenv(env); v != "" && !set["profile"] {
+			*dst = v
+		} else if fromProfile != "" {
+			*dst = fromProfile
+		} else {
+			*dst = fallback
+		}
+	}
+	pick(&f.Host, "host", "CONFIGRA_HOST", p.Host, defaultHost)
+	pick(&f.APIKey, "api-key", "CONFIGRA_API_KEY", p.APIKey, "")
+	pick(&f.Project, "project", "", p.Project, "")
+	pick(&f.Env, "env", "", p.Env, "prod")
+}
+
+// checkProject exits unless the API key belongs to the -project given, if
+// any. The server derives the project from the API key; -project only guards
+// against using the wrong key.
+func (f *remoteFlags) checkProject() {
+	if f.Project == "" {
+		return
+	}
+	want, err := strconv.Atoi(f.Project)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -project %q (want a project ID)\n", f.Project)
+		os.Exit(1)
+	}
+	var project struct {
+		ID int `json:"id"`
+	}
+	if err := apiCall("GET", fmt.Sprintf("%s/v1/project", f.Host), f.APIKey, nil, &project); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not look up the API key's project: %v\n", err)
+		os.Exit(1)
+	}
+	if project.ID != want {
+		fmt.Fprintf(os.Stderr, "Error: API key belongs to project %d, not %s\n", project.ID, f.Project)
+		os.Exit(1)
+	}
+}
+
+func runProfiles() {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(cfg.Profiles) == 0 {
+		fmt.Println("No profiles. Run `configra login` to create one.")
+		return
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := cfg.Profiles[name]
+		marker := " "
+		if name == cfg.CurrentProfile {
+			marker = "*"
+		}
+		env := p.Env
+		if env == "" {
+			env = "prod"
+		}
+		fmt.Printf("%s %-12s %s (project %s, env %s)\n", marker, name, p.Host, p.Project, env)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// runSchemaRegister uploads a schema file as the next version of a named
+// schema. It needs the project's admin key.
+func runSchemaRegister(name, schemaFile, compatibility string, remote *remoteFlags) {
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: -name is required")
+		os.Exit(1)
+	}
+	schemaMap, _, err := readSchemaFile(schemaFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	payload := map[string]interface{}{
+		"name":          name,
+		"schema":        schemaMap,
+		"compatibility": compatibility,
+	}
+	var registered configs.RegisteredSchema
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/schemas", remote.Host), remote.APIKey, payload, &registered); err != nil {
+		fmt.Fprintf(os.Stderr, "Register failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Registered schema '%s' version %d (%s compatibility).\n", registered.Name, registered.Version, registered.Compatibility)
+}
+
+func runSchemaGet(name string, version int, outFile string, remote *remoteFlags) {
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: -name is required")
+		os.Exit(1)
+	}
+	u := fmt.Sprintf("%s/v1/schemas/%s", remote.Host, url.PathEscape(name))
+	if version > 0 {
+		u += "?version=" + strconv.Itoa(version)
+	}
+
+	var registered configs.RegisteredSchema
+	if err := apiCall("GET", u, remote.APIKey, nil, &registered); err != nil {
+		fmt.Fprintf(os.Stderr, "Fetch failed: %v\n", err)
+		os.Exit(1)
+	}
+	writeOutput(registered.Definition, outFile)
+}
+
+func runSchemaList(remote *remoteFlags) {
+	var list []configs.RegisteredSchema
+	if err := apiCall("GET", fmt.Sprintf("%s/v1/schemas", remote.Host), remote.APIKey, nil, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "List failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No schemas registered.")
+		return
+	}
+	for _, s := range list {
+		fmt.Printf("%-30s v%-4d %s\n", s.Name, s.Version, s.Compatibility)
+	}
+}
+
+// runSchemaBind binds a config key to a registered schema, or unbinds it when
+// name is empty. It needs the project's admin key.
+func runSchemaBind(key, name string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+
+	payload := map[string]interface{}{
+		"env":    remote.Env,
+		"key":    key,
+		"schema": name,
+	}
+	if err := apiCall("PUT", fmt.Sprintf("%s/v1/configs/schema", remote.Host), remote.APIKey, payload, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Bind failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if name == "" {
+		fmt.Printf("✅ '%s' in %s is no longer bound to a schema.\n", key, remote.Env)
+		return
+	}
+	fmt.Printf("✅ '%s' in %s is now validated against schema '%s'.\n", key, remote.Env, name)
+}
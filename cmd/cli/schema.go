@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/clyvecute/configra/internal/codegen"
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// stringList is a flag that can be repeated, e.g. -config a.json -config b.json.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// runSchema dispatches the `configra schema <subcommand>` group.
+func runSchema(args []string) {
+	if len(args) < 1 {
+		printSchemaUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "infer":
+		inferCmd := flag.NewFlagSet("schema infer", flag.ExitOnError)
+		var samples stringList
+		inferCmd.Var(&samples, "config", "Sample config file (repeat for several samples)")
+		maxEnum := inferCmd.Int("max-enum", 5, "Max distinct strings to infer as an enum (0 disables)")
+		out := inferCmd.String("out", "", "Write the schema to this file instead of stdout")
+		inferCmd.Parse(args[1:])
+		runSchemaInfer(append(samples, inferCmd.Args()...), *maxEnum, *out)
+	case "to-jsonschema":
+		toCmd := flag.NewFlagSet("schema to-jsonschema", flag.ExitOnError)
+		schemaPath := toCmd.String("schema", "schema.json", "Path to the Configra schema file")
+		title := toCmd.String("title", "", "Title of the JSON Schema document")
+		out := toCmd.String("out", "", "Write to this file instead of stdout")
+		toCmd.Parse(args[1:])
+
+		_, schema, err := readSchemaFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		writeOutput(codegen.JSONSchema(schema, *title), *out)
+	case "from-jsonschema":
+		fromCmd := flag.NewFlagSet("schema from-jsonschema", flag.ExitOnError)
+		docPath := fromCmd.String("jsonschema", "", "Path to the JSON Schema document")
+		out := fromCmd.String("out", "", "Write the Configra schema to this file instead of stdout")
+		fromCmd.Parse(args[1:])
+
+		doc, err := readConfigFile(*docPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		schema, warnings, err := codegen.FromJSONSchema(doc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		writeOutput(schema, *out)
+	case "register":
+		registerCmd := flag.NewFlagSet("schema register", flag.ExitOnError)
+		remote := addRemoteFlags(registerCmd)
+		name := registerCmd.String("name", "", "Name of the registered schema")
+		schemaPath := registerCmd.String("schema", "schema.json", "Path to the schema file")
+		compatibility := registerCmd.String("compatibility", "", "Set the schema's compatibility mode: backward, forward, full or none")
+		registerCmd.Parse(args[1:])
+		remote.resolve()
+		runSchemaRegister(*name, *schemaPath, *compatibility, remote)
+	case "get":
+		getCmd := flag.NewFlagSet("schema get", flag.ExitOnError)
+		remote := addRemoteFlags(getCmd)
+		name := getCmd.String("name", "", "Name of the registered schema")
+		version := getCmd.Int("version", 0, "Version to fetch (default: latest)")
+		out := getCmd.String("out", "", "Write the schema to this file instead of stdout")
+		getCmd.Parse(args[1:])
+		remote.resolve()
+		runSchemaGet(*name, *version, *out, remote)
+	case "list":
+		listCmd := flag.NewFlagSet("schema list", flag.ExitOnError)
+		remote := addRemoteFlags(listCmd)
+		listCmd.Parse(args[1:])
+		remote.resolve()
+		runSchemaList(remote)
+	case "bind":
+		bindCmd := flag.NewFlagSet("schema bind", flag.ExitOnError)
+		remote := addRemoteFlags(bindCmd)
+		key := bindCmd.String("key", "", "Config Key")
+		name := bindCmd.String("name", "", "Registered schema to validate the key against")
+		unbind := bindCmd.Bool("unbind", false, "Remove the key's binding")
+		bindCmd.Parse(args[1:])
+		remote.resolve()
+		if *name == "" && !*unbind {
+			fmt.Fprintln(os.Stderr, "Error: -name or -unbind is required")
+			os.Exit(1)
+		}
+		if *unbind {
+			*name = ""
+		}
+		runSchemaBind(*key, *name, remote)
+	default:
+		printSchemaUsage()
+		os.Exit(1)
+	}
+}
+
+func printSchemaUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  schema infer -config <path> [-config <path> ...] [-max-enum <n>] [-out <path>]")
+	fmt.Println("                                           Generate a schema from sample configs")
+	fmt.Println("  schema to-jsonschema -schema <path> [-title <s>] [-out <path>]")
+	fmt.Println("                                           Convert a schema to JSON Schema (draft 2020-12)")
+	fmt.Println("  schema from-jsonschema -jsonschema <path> [-out <path>]")
+	fmt.Println("                                           Import a JSON Schema subset as a Configra schema")
+	fmt.Println("  schema register -name <name> -schema <path> [-compatibility <mode>]")
+	fmt.Println("                                           Register a new schema version (admin key)")
+	fmt.Println("  schema get -name <name> [-version <n>] [-out <path>]")
+	fmt.Println("                                           Download a registered schema")
+	fmt.Println("  schema list                              List registered schemas")
+	fmt.Println("  schema bind -env <name> -key <key> -name <name> | -unbind")
+	fmt.Println("                                           Validate a key against a registered schema (admin key)")
+}
+
+func runSchemaInfer(paths []string, maxEnum int, outFile string) {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: at least one -config sample is required")
+		os.Exit(1)
+	}
+
+	var samples []map[string]interface{}
+	for _, path := range paths {
+		sample, err := readConfigFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		samples = append(samples, sample)
+	}
+
+	schema, warnings := configs.InferSchema(samples, configs.InferOptions{MaxEnum: maxEnum})
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	writeOutput(schema, outFile)
+}
+
+// writeOutput writes v as indented JSON to outFile, or stdout when empty.
+func writeOutput(v interface{}, outFile string) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	writeRaw(append(b, '\n'), outFile)
+}
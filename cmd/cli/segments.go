@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/clyvecute/configra/internal/configs"
+	"github.com/clyvecute/configra/internal/featureflags"
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// runSegment dispatches the `configra segment <subcommand>` group.
+func runSegment(args []string) {
+	if len(args) < 1 {
+		printSegmentUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		setCmd := flag.NewFlagSet("segment set", flag.ExitOnError)
+		remote := addRemoteFlags(setCmd)
+		file := setCmd.String("file", "", "Segment definition (JSON, YAML or TOML)")
+		setCmd.Parse(args[1:])
+		remote.resolve()
+		runSegmentSet(*file, remote)
+	case "get":
+		getCmd := flag.NewFlagSet("segment get", flag.ExitOnError)
+		remote := addRemoteFlags(getCmd)
+		key := getCmd.String("key", "", "Segment key")
+		version := getCmd.Int("version", 0, "Version to fetch (default: latest)")
+		out := getCmd.String("out", "", "Write the definition to this file instead of stdout")
+		getCmd.Parse(args[1:])
+		remote.resolve()
+		runSegmentGet(*key, *version, *out, remote)
+	case "list":
+		listCmd := flag.NewFlagSet("segment list", flag.ExitOnError)
+		remote := addRemoteFlags(listCmd)
+		listCmd.Parse(args[1:])
+		remote.resolve()
+		runSegmentList(remote)
+	case "usage":
+		usageCmd := flag.NewFlagSet("segment usage", flag.ExitOnError)
+		remote := addRemoteFlags(usageCmd)
+		key := usageCmd.String("key", "", "Segment key")
+		usageCmd.Parse(args[1:])
+		remote.resolve()
+		runSegmentUsage(*key, remote)
+	case "delete":
+		deleteCmd := flag.NewFlagSet("segment delete", flag.ExitOnError)
+		remote := addRemoteFlags(deleteCmd)
+		key := deleteCmd.String("key", "", "Segment key")
+		deleteCmd.Parse(args[1:])
+		remote.resolve()
+		runSegmentDelete(*key, remote)
+	default:
+		printSegmentUsage()
+		os.Exit(1)
+	}
+}
+
+func printSegmentUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  segment set -file <path>                 Save a new version of a segment")
+	fmt.Println("  segment get -key <key> [-version <n>] [-out <path>]")
+	fmt.Println("                                           Download a segment definition")
+	fmt.Println("  segment list                             List the project's segments")
+	fmt.Println("  segment usage -key <key>                 List the flags that use a segment, in every environment")
+	fmt.Println("  segment delete -key <key>                Delete a segment no flag uses")
+}
+
+func runSegmentSet(file string, remote *remoteFlags) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "Error: -file is required")
+		os.Exit(1)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error reading segment file: %v\n", err)
+		os.Exit(1)
+	}
+	var def flags.Segment
+	if err := configs.DecodeInto(b, configs.FormatFromPath(file), &def); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error parsing segment %s: %v\n", file, err)
+		os.Exit(1)
+	}
+	if errs := def.Validate(); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "❌ Segment %s is invalid:\n", file)
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "  - %s\n", e)
+		}
+		os.Exit(1)
+	}
+
+	var saved featureflags.Segment
+	payload := map[string]interface{}{"segment": def}
+	if err := apiCall("POST", fmt.Sprintf("%s/v1/segments", remote.Host), remote.APIKey, payload, &saved); err != nil {
+		fmt.Fprintf(os.Stderr, "Save failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Saved segment '%s' version %d.\n", saved.Key, saved.Version)
+}
+
+func runSegmentGet(key string, version int, outFile string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	u := fmt.Sprintf("%s/v1/segments/%s", remote.Host, url.PathEscape(key))
+	if version > 0 {
+		u += "?version=" + strconv.Itoa(version)
+	}
+
+	var seg featureflags.Segment
+	if err := apiCall("GET", u, remote.APIKey, nil, &seg); err != nil {
+		fmt.Fprintf(os.Stderr, "Fetch failed: %v\n", err)
+		os.Exit(1)
+	}
+	// Write the bare definition, so it can be edited and sent back with set.
+	seg.Segment.Version = 0
+	writeOutput(seg.Segment, outFile)
+}
+
+func runSegmentList(remote *remoteFlags) {
+	var list []featureflags.Segment
+	if err := apiCall("GET", fmt.Sprintf("%s/v1/segments", remote.Host), remote.APIKey, nil, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "List failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No segments.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVERSION\tINCLUDED\tEXCLUDED\tRULES\tDESCRIPTION")
+	for _, s := range list {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", s.Key, s.Version, len(s.Included), len(s.Excluded), len(s.Rules), s.Description)
+	}
+	tw.Flush()
+}
+
+func runSegmentUsage(key string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	var usage []featureflags.SegmentUsage
+	u := fmt.Sprintf("%s/v1/segments/%s/flags", remote.Host, url.PathEscape(key))
+	if err := apiCall("GET", u, remote.APIKey, nil, &usage); err != nil {
+		fmt.Fprintf(os.Stderr, "Fetch failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(usage) == 0 {
+		fmt.Printf("No flag uses segment '%s'.\n", key)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENV\tFLAG\tSTATE\tRULES")
+	for _, u := range usage {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", u.Env, u.Flag, onOff(u.On), strings.Join(u.Rules, ", "))
+	}
+	tw.Flush()
+}
+
+func runSegmentDelete(key string, remote *remoteFlags) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Error: -key is required")
+		os.Exit(1)
+	}
+	u := fmt.Sprintf("%s/v1/segments/%s", remote.Host, url.PathEscape(key))
+	if err := apiCall("DELETE", u, remote.APIKey, nil, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Delete failed: %v\n", err)
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 409 {
+			fmt.Fprintf(os.Stderr, "Run `configra segment usage -key %s` to see which flags use it.\n", key)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Deleted segment '%s'.\n", key)
+}
@@ -2,4 +2,11 @@ module github.com/clyvecute/configra
 
 go 1.25.5
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/open-feature/go-sdk v1.18.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require go.uber.org/mock v0.6.0 // indirect
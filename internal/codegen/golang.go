@@ -0,0 +1,453 @@
+// Package codegen generates typed code from configs.Schema definitions, so
+// consumers get compile-time types that match what the server enforces.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// GoOptions controls Go code generation.
+type GoOptions struct {
+	Package  string // Package clause of the generated file
+	TypeName string // Name of the config struct; defaults to "Config"
+}
+
+// goField is a schema rule resolved to Go names and types.
+type goField struct {
+	Key      string
+	Path     string // Dotted path from the top of the config, for messages
+	Name     string // Exported Go identifier
+	Rule     configs.FieldRule
+	BaseType string   // Go type without pointer
+	EnumType string   // Named string type, set for string enums
+	Consts   []string // Constant of each allowed value, for string enums
+	Optional bool     // Generated as a pointer with omitempty
+	Nested   bool     // BaseType is the struct generated for the rule's Fields
+}
+
+// goStruct is a struct to generate: the config itself, or the object of a
+// json field with nested rules.
+type goStruct struct {
+	Name   string
+	Path   string // Path of the json field, "" for the config
+	Prefix string // Prepended to the names of its enum types and defaults
+	Fields []goField
+}
+
+// Go renders a Go source file with a struct mirroring schema, json tags, doc
+// comments from FieldRule.Description, Default* constants and a Validate
+// method checking min/max and enum constraints. A json field with nested
+// rules gets a struct of its own, checked by Validate too.
+func Go(schema configs.Schema, opts GoOptions) ([]byte, error) {
+	if opts.Package == "" {
+		return nil, fmt.Errorf("package name is required")
+	}
+	if opts.TypeName == "" {
+		opts.TypeName = "Config"
+	}
+
+	top := &goStruct{Name: opts.TypeName}
+	types := map[string]string{opts.TypeName: "the config"}
+	structs, err := goStructs(top, schema.Rules, types)
+	if err != nil {
+		return nil, err
+	}
+	// Enum constants are named last, so they give way to every type and
+	// default constant.
+	taken := map[string]bool{}
+	for name := range types {
+		taken[name] = true
+	}
+	for _, st := range structs {
+		for _, f := range st.Fields {
+			if _, ok := goLiteral(f, f.Rule.Default); ok {
+				taken["Default"+st.Prefix+f.Name] = true
+			}
+		}
+	}
+	for _, st := range structs {
+		for i := range st.Fields {
+			nameEnumConsts(&st.Fields[i], taken)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by configra codegen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", opts.Package)
+	fmt.Fprintf(&b, "import (\n\"fmt\"\n\"strings\"\n)\n\n")
+
+	for _, st := range structs {
+		writeGoEnums(&b, st.Fields)
+	}
+	for _, st := range structs {
+		writeGoDefaults(&b, st)
+	}
+	for _, st := range structs {
+		writeGoStruct(&b, schema, st)
+	}
+	for _, st := range structs {
+		writeGoValidate(&b, st)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is not valid Go syntax: %w", err)
+	}
+	return src, nil
+}
+
+// goStructs resolves the rules of st, and returns it followed by the
+// structs of its nested rules, depth first. types holds the type names taken
+// so far, and what they were generated for.
+func goStructs(st *goStruct, rules map[string]configs.FieldRule, types map[string]string) ([]*goStruct, error) {
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	structs := []*goStruct{st}
+	seen := map[string]string{}
+	for _, key := range keys {
+		rule := rules[key]
+		f := goField{Key: key, Path: st.Path + key, Name: GoName(key), Rule: rule, Optional: !rule.Required}
+		if other, dup := seen[f.Name]; dup {
+			return nil, fmt.Errorf("fields '%s' and '%s' both map to Go name %s", st.Path+other, f.Path, f.Name)
+		}
+		seen[f.Name] = key
+		claim := func(name string) error {
+			if other, dup := types[name]; dup {
+				return fmt.Errorf("field '%s' and %s both map to Go type %s", f.Path, other, name)
+			}
+			types[name] = fmt.Sprintf("field '%s'", f.Path)
+			return nil
+		}
+
+		switch rule.Type {
+		case configs.TypeString:
+			f.BaseType = "string"
+		case configs.TypeInt:
+			f.BaseType = "int"
+		case configs.TypeFloat:
+			f.BaseType = "float64"
+		case configs.TypeBool:
+			f.BaseType = "bool"
+		case configs.TypeJSON:
+			if len(rule.Fields) == 0 {
+				// Objects or arrays; nil already means absent.
+				f.BaseType = "interface{}"
+				f.Optional = false
+				break
+			}
+			nested := &goStruct{Name: st.Name + f.Name, Path: f.Path + ".", Prefix: st.Prefix + f.Name}
+			if err := claim(nested.Name); err != nil {
+				return nil, err
+			}
+			more, err := goStructs(nested, rule.Fields, types)
+			if err != nil {
+				return nil, err
+			}
+			structs = append(structs, more...)
+			f.BaseType, f.Nested = nested.Name, true
+		case configs.TypeEnum:
+			f.BaseType = enumBaseType(rule.Allowed)
+			if f.BaseType == "string" {
+				f.EnumType = st.Prefix + f.Name
+				if _, taken := types[f.EnumType]; taken && st.Prefix == "" {
+					f.EnumType += "Value"
+				}
+				if err := claim(f.EnumType); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("field '%s' has unsupported type %q", f.Path, rule.Type)
+		}
+		st.Fields = append(st.Fields, f)
+	}
+	return structs, nil
+}
+
+// nameEnumConsts names the constant of each allowed value of a string enum
+// after its type and the value. Names already taken get a number, so the
+// values "a-b" and "a_b" of Mode become ModeAB and ModeAB2.
+func nameEnumConsts(f *goField, taken map[string]bool) {
+	if f.EnumType == "" {
+		return
+	}
+	for _, v := range f.Rule.Allowed {
+		base := f.EnumType + GoName(v.(string))
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = base + strconv.Itoa(n)
+		}
+		taken[name] = true
+		f.Consts = append(f.Consts, name)
+	}
+}
+
+// enumBaseType picks the Go type able to hold every allowed value.
+func enumBaseType(allowed []interface{}) string {
+	kind := ""
+	for _, v := range allowed {
+		var k string
+		switch n := v.(type) {
+		case string:
+			k = "string"
+		case bool:
+			k = "bool"
+		case float64:
+			k = "int"
+			if n != float64(int64(n)) {
+				k = "float64"
+			}
+		default:
+			return "interface{}"
+		}
+		switch {
+		case kind == "" || kind == k:
+			kind = k
+		case (kind == "int" && k == "float64") || (kind == "float64" && k == "int"):
+			kind = "float64"
+		default:
+			return "interface{}"
+		}
+	}
+	if kind == "" {
+		return "interface{}"
+	}
+	return kind
+}
+
+func (f goField) goType() string {
+	t := f.BaseType
+	if f.EnumType != "" {
+		t = f.EnumType
+	}
+	if f.Optional {
+		return "*" + t
+	}
+	return t
+}
+
+func writeGoEnums(b *bytes.Buffer, fields []goField) {
+	for _, f := range fields {
+		if f.EnumType == "" {
+			continue
+		}
+		fmt.Fprintf(b, "// %s is one of the values allowed for %q.\n", f.EnumType, f.Path)
+		fmt.Fprintf(b, "type %s string\n\nconst (\n", f.EnumType)
+		for i, v := range f.Rule.Allowed {
+			fmt.Fprintf(b, "%s %s = %q\n", f.Consts[i], f.EnumType, v)
+		}
+		fmt.Fprintf(b, ")\n\n")
+	}
+}
+
+func writeGoDefaults(b *bytes.Buffer, st *goStruct) {
+	var lines []string
+	for _, f := range st.Fields {
+		lit, ok := goLiteral(f, f.Rule.Default)
+		if !ok {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Default%s%s %s = %s", st.Prefix, f.Name, constType(f), lit))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	what := "the schema"
+	if st.Path != "" {
+		what = fmt.Sprintf("the nested rules of %q", strings.TrimSuffix(st.Path, "."))
+	}
+	fmt.Fprintf(b, "// Defaults declared in %s.\nconst (\n%s\n)\n\n", what, strings.Join(lines, "\n"))
+}
+
+// constType is the explicit type of a default constant; numbers stay typed
+// so they can be assigned to the struct fields without conversion.
+func constType(f goField) string {
+	if f.EnumType != "" {
+		return f.EnumType
+	}
+	return f.BaseType
+}
+
+// goLiteral renders v as a Go constant of the field's type.
+func goLiteral(f goField, v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	switch f.BaseType {
+	case "string":
+		s, ok := v.(string)
+		return strconv.Quote(s), ok
+	case "bool":
+		bv, ok := v.(bool)
+		return strconv.FormatBool(bv), ok
+	case "int":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return "", false
+		}
+		return strconv.FormatInt(int64(n), 10), true
+	case "float64":
+		n, ok := v.(float64)
+		return strconv.FormatFloat(n, 'g', -1, 64), ok
+	default:
+		return "", false
+	}
+}
+
+func writeGoStruct(b *bytes.Buffer, schema configs.Schema, st *goStruct) {
+	if st.Path == "" {
+		fmt.Fprintf(b, "// %s mirrors version %d of its Configra schema.\n", st.Name, schema.Version)
+	} else {
+		fmt.Fprintf(b, "// %s mirrors the nested rules of %q. The field must hold an object to\n", st.Name, strings.TrimSuffix(st.Path, "."))
+		fmt.Fprintf(b, "// decode into it.\n")
+	}
+	fmt.Fprintf(b, "type %s struct {\n", st.Name)
+	for i, f := range st.Fields {
+		if f.Rule.Description != "" || f.Rule.Deprecated != nil {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+		}
+		if f.Rule.Description != "" {
+			for _, line := range strings.Split(strings.TrimSpace(f.Rule.Description), "\n") {
+				fmt.Fprintf(b, "// %s\n", strings.TrimSpace(line))
+			}
+		}
+		if f.Rule.Deprecated != nil {
+			if f.Rule.Description != "" {
+				b.WriteString("//\n")
+			}
+			fmt.Fprintf(b, "// Deprecated: %s\n", deprecationNote(f.Rule.Deprecated))
+		}
+		tag := f.Key
+		if !f.Rule.Required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(b, "%s %s `json:%q`\n", f.Name, f.goType(), tag)
+	}
+	fmt.Fprintf(b, "}\n\n")
+}
+
+// writeGoValidate writes the Validate method of the config, or the validate
+// method, listing problems, that it calls for a nested struct.
+func writeGoValidate(b *bytes.Buffer, st *goStruct) {
+	if st.Path == "" {
+		fmt.Fprintf(b, "// Validate checks the constraints the Configra server enforces on values\n")
+		fmt.Fprintf(b, "// that are set. Types are already enforced by the struct itself.\n")
+		fmt.Fprintf(b, "func (c *%s) Validate() error {\nvar errs []string\n", st.Name)
+	} else {
+		fmt.Fprintf(b, "func (c *%s) validate() []string {\nvar errs []string\n", st.Name)
+	}
+
+	for _, f := range st.Fields {
+		var checks bytes.Buffer
+		val := "c." + f.Name
+		if f.Optional {
+			val = "*c." + f.Name
+		}
+
+		if f.Nested {
+			fmt.Fprintf(&checks, "errs = append(errs, c.%s.validate()...)\n", f.Name)
+		}
+
+		if (f.BaseType == "int" || f.BaseType == "float64") && f.EnumType == "" {
+			if f.Rule.Min != nil {
+				fmt.Fprintf(&checks, "if float64(%s) < %s {\nerrs = append(errs, %q)\n}\n",
+					val, strconv.FormatFloat(*f.Rule.Min, 'g', -1, 64), fmt.Sprintf("field '%s' must be >= %v", f.Path, *f.Rule.Min))
+			}
+			if f.Rule.Max != nil {
+				fmt.Fprintf(&checks, "if float64(%s) > %s {\nerrs = append(errs, %q)\n}\n",
+					val, strconv.FormatFloat(*f.Rule.Max, 'g', -1, 64), fmt.Sprintf("field '%s' must be <= %v", f.Path, *f.Rule.Max))
+			}
+		}
+
+		if len(f.Rule.Allowed) > 0 && f.BaseType != "interface{}" {
+			var cases []string
+			for i, v := range f.Rule.Allowed {
+				if lit, ok := goLiteral(goField{BaseType: f.BaseType}, v); ok {
+					if f.EnumType != "" {
+						lit = f.Consts[i]
+					}
+					cases = append(cases, lit)
+				}
+			}
+			fmt.Fprintf(&checks, "switch %s {\ncase %s:\ndefault:\nerrs = append(errs, fmt.Sprintf(%q, %s))\n}\n",
+				val, strings.Join(cases, ", "), fmt.Sprintf("field '%s' has invalid value '%%v'; allowed: %v", f.Path, f.Rule.Allowed), val)
+		}
+
+		if checks.Len() == 0 {
+			continue
+		}
+		if f.Optional {
+			fmt.Fprintf(b, "if c.%s != nil {\n%s}\n", f.Name, checks.String())
+		} else {
+			b.Write(checks.Bytes())
+		}
+	}
+
+	if st.Path != "" {
+		fmt.Fprintf(b, "return errs\n}\n\n")
+		return
+	}
+	fmt.Fprintf(b, "if len(errs) > 0 {\nreturn fmt.Errorf(\"validation failed: %%s\", strings.Join(errs, \"; \"))\n}\nreturn nil\n}\n\n")
+}
+
+// commonInitialisms are upper-cased whole when they appear as a word.
+var commonInitialisms = map[string]bool{
+	"API": true, "CPU": true, "DB": true, "DNS": true, "HTTP": true, "HTTPS": true,
+	"ID": true, "IP": true, "JSON": true, "SQL": true, "TLS": true, "TTL": true,
+	"UI": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// GoName converts a config key such as "max_retries" or "api-url" into an
+// exported Go identifier ("MaxRetries", "APIURL").
+func GoName(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if commonInitialisms[strings.ToUpper(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// deprecationNote summarizes a deprecation for generated doc comments.
+func deprecationNote(d *configs.Deprecation) string {
+	var parts []string
+	if d.ReplacedBy != "" {
+		parts = append(parts, fmt.Sprintf("Use %s instead.", d.ReplacedBy))
+	}
+	if d.Sunset != "" {
+		parts = append(parts, fmt.Sprintf("Rejected from %s.", d.Sunset))
+	}
+	if d.Message != "" {
+		parts = append(parts, d.Message)
+	}
+	if len(parts) == 0 {
+		return "this field is being removed."
+	}
+	return strings.Join(parts, " ")
+}
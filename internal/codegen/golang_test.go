@@ -0,0 +1,163 @@
+package codegen
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+func TestGoCompilesAndMirrorsSchema(t *testing.T) {
+	var schema configs.Schema
+	err := json.Unmarshal([]byte(`{
+		"version": 2,
+		"rules": {
+			"app_name":    {"type": "string", "required": true, "description": "Human readable service name."},
+			"max_retries": {"type": "int", "min": 0, "max": 5, "default": 3},
+			"mode":        {"type": "enum", "allowed": ["debug", "release"], "default": "debug"},
+			"level":       {"type": "enum", "allowed": [1, 2, 3]},
+			"ratio":       {"type": "float", "required": true},
+			"api_url":     {"type": "string", "deprecated": {"replaced_by": "app_name", "sunset": "2030-01-01"}},
+			"extra":       {"type": "json"}
+		}
+	}`), &schema)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	src, err := Go(schema, GoOptions{Package: "cfg"})
+	if err != nil {
+		t.Fatalf("Go() error = %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "config_gen.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("cfg", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %v\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"// Human readable service name.\n\tAppName",
+		"// Deprecated: Use app_name instead. Rejected from 2030-01-01.\n\tAPIURL ",
+		"MaxRetries *int",
+		"Mode       *Mode",
+		"Ratio      float64     `json:\"ratio\"`",
+		"DefaultMaxRetries int  = 3",
+		"ModeRelease Mode = \"release\"",
+		"func (c *Config) Validate() error",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code missing %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGoNestedRules(t *testing.T) {
+	var schema configs.Schema
+	err := json.Unmarshal([]byte(`{
+		"version": 1,
+		"rules": {
+			"mode": {"type": "enum", "allowed": ["a", "b"]},
+			"db": {"type": "json", "required": true, "fields": {
+				"host": {"type": "string", "required": true},
+				"mode": {"type": "enum", "allowed": ["primary", "replica"], "default": "primary"},
+				"pool": {"type": "json", "fields": {"max": {"type": "int", "min": 1}}}
+			}}
+		}
+	}`), &schema)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	src := typeCheckGo(t, schema)
+	for _, want := range []string{
+		"DB   ConfigDB `json:\"db\"`",
+		"type ConfigDB struct {",
+		"Pool *ConfigDBPool `json:\"pool,omitempty\"`",
+		"type ConfigDBPool struct {",
+		"DBModePrimary DBMode = \"primary\"",
+		"ModeA Mode = \"a\"",
+		"DefaultDBMode DBMode = \"primary\"",
+		"errs = append(errs, c.DB.validate()...)",
+		"if c.Pool != nil {\n\t\terrs = append(errs, c.Pool.validate()...)",
+		"field 'db.pool.max' must be >= 1",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code missing %q:\n%s", want, src)
+		}
+	}
+}
+
+// typeCheckGo generates Go code for schema and fails unless it type-checks.
+func typeCheckGo(t *testing.T, schema configs.Schema) string {
+	t.Helper()
+	src, err := Go(schema, GoOptions{Package: "cfg"})
+	if err != nil {
+		t.Fatalf("Go() error = %v", err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "config_gen.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("cfg", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %v\n%s", err, src)
+	}
+	return string(src)
+}
+
+func TestGoEnumConstantCollisions(t *testing.T) {
+	var schema configs.Schema
+	err := json.Unmarshal([]byte(`{
+		"version": 1,
+		"rules": {
+			"mode":   {"type": "enum", "allowed": ["a-b", "a_b", "a", "fast"]},
+			"mode_a": {"type": "enum", "allowed": ["x"], "default": "x"},
+			"retries": {"type": "int", "default": 3},
+			"default": {"type": "enum", "allowed": ["retries", "other"]}
+		}
+	}`), &schema)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	src := typeCheckGo(t, schema)
+	for _, want := range []string{
+		"ModeAB   Mode = \"a-b\"",
+		"ModeAB2  Mode = \"a_b\"",
+		"ModeA2   Mode = \"a\"",
+		"ModeFast Mode = \"fast\"",
+		"ModeAX ModeA = \"x\"",
+		"DefaultRetries2 Default = \"retries\"",
+		"DefaultRetries int   = 3",
+		"case ModeAB, ModeAB2, ModeA2, ModeFast:",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code missing %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for key, want := range map[string]string{
+		"max_retries": "MaxRetries",
+		"api-url":     "APIURL",
+		"userId":      "UserId",
+		"2fa_enabled": "X2faEnabled",
+	} {
+		if got := GoName(key); got != want {
+			t.Errorf("GoName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package codegen
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/clyvecute/configra/internal/configs"
+	"github.com/clyvecute/configra/pkg/utils"
+)
+
+// Handler exposes the schema converters over HTTP. Like /v1/validate, the
+// endpoints are stateless and need no API key.
+type Handler struct{}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Export converts a Configra schema in the request body into the format given
+// by the "format" query parameter: jsonschema (default), typescript or go.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	schema, _, err := configs.DecodeSchema(body, configs.FormatFromContentType(r.Header.Get("Content-Type")))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	var out []byte
+	contentType := "text/plain; charset=utf-8"
+	switch q.Get("format") {
+	case "", "jsonschema":
+		utils.WriteJSON(w, http.StatusOK, JSONSchema(schema, q.Get("title")))
+		return
+	case "typescript":
+		out, err = TypeScript(schema, q.Get("type"))
+		contentType = "application/typescript"
+	case "go":
+		out, err = Go(schema, GoOptions{Package: q.Get("package"), TypeName: q.Get("type")})
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown format (want jsonschema, typescript or go)"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+// Import converts a JSON Schema document into a Configra schema, returning
+// warnings for keywords that could not be carried over.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	doc, err := configs.DecodeMap(body, configs.FormatFromContentType(r.Header.Get("Content-Type")))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	schema, warnings, err := FromJSONSchema(doc)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"schema":   schema,
+		"warnings": warnings,
+	})
+}
@@ -0,0 +1,289 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+// JSONSchemaDialect is the $schema URI of the generated documents.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema converts a Configra schema into a JSON Schema (draft 2020-12)
+// document. Rejecting unknown fields maps to additionalProperties: false; the
+// lenient policies allow them and are kept as x-configra-unknown-fields. Properties and
+// cross-field constraints JSON Schema has no keyword for are kept as
+// x-configra-* annotations so that FromJSONSchema can round-trip them.
+func JSONSchema(schema configs.Schema, title string) map[string]interface{} {
+	props, required := jsonSchemaProperties(schema.Rules)
+	doc := map[string]interface{}{
+		"$schema":              JSONSchemaDialect,
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+		"x-configra-version":   schema.Version,
+	}
+	if schema.UnknownFields == configs.UnknownWarn || schema.UnknownFields == configs.UnknownStrip {
+		doc["additionalProperties"] = true
+		doc["x-configra-unknown-fields"] = string(schema.UnknownFields)
+	}
+	if title != "" {
+		doc["title"] = title
+	}
+	if len(schema.Constraints) > 0 {
+		doc["x-configra-constraints"] = schema.Constraints
+	}
+	return doc
+}
+
+// jsonSchemaProperties converts rules to JSON Schema properties and the
+// sorted list of required ones. Nested rules of json fields become the
+// properties of the object; like the validator, they don't forbid other keys.
+func jsonSchemaProperties(rules map[string]configs.FieldRule) (map[string]interface{}, []string) {
+	props := map[string]interface{}{}
+	required := []string{}
+	for key, rule := range rules {
+		prop := map[string]interface{}{}
+		switch rule.Type {
+		case configs.TypeString:
+			prop["type"] = "string"
+		case configs.TypeInt:
+			prop["type"] = "integer"
+		case configs.TypeFloat:
+			prop["type"] = "number"
+		case configs.TypeBool:
+			prop["type"] = "boolean"
+		case configs.TypeJSON:
+			prop["type"] = []interface{}{"object", "array"}
+		}
+		if len(rule.Allowed) > 0 {
+			prop["enum"] = rule.Allowed
+		}
+		if rule.Min != nil {
+			prop["minimum"] = *rule.Min
+		}
+		if rule.Max != nil {
+			prop["maximum"] = *rule.Max
+		}
+		if rule.Description != "" {
+			prop["description"] = rule.Description
+		}
+		if rule.Default != nil {
+			prop["default"] = rule.Default
+		}
+		if rule.Secret {
+			prop["x-configra-secret"] = true
+		}
+		if rule.Deprecated != nil {
+			prop["deprecated"] = true
+			prop["x-configra-deprecated"] = rule.Deprecated
+		}
+		if len(rule.Fields) > 0 {
+			prop["properties"], prop["required"] = jsonSchemaProperties(rule.Fields)
+		}
+		props[key] = prop
+		if rule.Required {
+			required = append(required, key)
+		}
+	}
+	sort.Strings(required)
+	return props, required
+}
+
+// FromJSONSchema imports the subset of JSON Schema that Configra can enforce:
+// a top-level object whose properties have a primitive type, enum or const,
+// minimum/maximum, description and default, and objects whose properties
+// follow the same rules. Keywords outside that subset are
+// ignored and reported as warnings rather than silently loosening validation.
+func FromJSONSchema(doc map[string]interface{}) (configs.Schema, []string, error) {
+	schema := configs.Schema{Version: 1, Rules: map[string]configs.FieldRule{}}
+	var warnings []string
+
+	if t, ok := doc["type"]; ok && t != "object" {
+		return schema, nil, fmt.Errorf("top-level type must be \"object\", got %v", t)
+	}
+	if v, ok := doc["x-configra-version"].(float64); ok {
+		schema.Version = int(v)
+	}
+	if c, ok := doc["x-configra-constraints"]; ok {
+		b, _ := json.Marshal(c)
+		if err := json.Unmarshal(b, &schema.Constraints); err != nil {
+			return schema, nil, fmt.Errorf("invalid x-configra-constraints: %w", err)
+		}
+	}
+	if policy, ok := doc["x-configra-unknown-fields"].(string); ok {
+		schema.UnknownFields = configs.UnknownFieldPolicy(policy)
+	} else if doc["additionalProperties"] != false {
+		schema.UnknownFields = configs.UnknownWarn
+		warnings = append(warnings, "additionalProperties is not false; unknown fields will be accepted with a warning (unknown_fields: warn)")
+	}
+	warnings = append(warnings, unsupportedKeywords("", doc, topLevelKeywords)...)
+
+	props, _ := doc["properties"].(map[string]interface{})
+	if props == nil {
+		return schema, nil, fmt.Errorf("schema has no properties")
+	}
+	rules, ruleWarnings, err := rulesFromJSONSchema("", props, doc["required"])
+	if err != nil {
+		return schema, nil, err
+	}
+	schema.Rules = rules
+	return schema, append(warnings, ruleWarnings...), nil
+}
+
+// rulesFromJSONSchema imports properties, with the paths of nested ones
+// prefixed to their errors and warnings.
+func rulesFromJSONSchema(prefix string, props map[string]interface{}, requiredList interface{}) (map[string]configs.FieldRule, []string, error) {
+	rules := map[string]configs.FieldRule{}
+	var warnings []string
+
+	required := map[string]bool{}
+	if list, ok := requiredList.([]interface{}); ok {
+		for _, r := range list {
+			if s, ok := r.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := prefix + key
+		prop, ok := props[key].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("property '%s' is not an object", path)
+		}
+		rule, err := ruleFromJSONSchema(path, prop)
+		if err != nil {
+			return nil, nil, err
+		}
+		if nested, ok := prop["properties"].(map[string]interface{}); ok && rule.Type == configs.TypeJSON {
+			fields, nestedWarnings, err := rulesFromJSONSchema(path+".", nested, prop["required"])
+			if err != nil {
+				return nil, nil, err
+			}
+			rule.Fields = fields
+			warnings = append(warnings, nestedWarnings...)
+		} else if ok {
+			warnings = append(warnings, fmt.Sprintf("property '%s': keyword 'properties' only applies to objects and was ignored", path))
+		}
+		rule.Required = required[key]
+		rules[key] = rule
+		warnings = append(warnings, unsupportedKeywords(path, prop, propertyKeywords)...)
+	}
+	return rules, warnings, nil
+}
+
+func ruleFromJSONSchema(key string, prop map[string]interface{}) (configs.FieldRule, error) {
+	var rule configs.FieldRule
+
+	if enum, ok := prop["enum"].([]interface{}); ok {
+		rule.Type = configs.TypeEnum
+		rule.Allowed = enum
+	} else if c, ok := prop["const"]; ok {
+		rule.Type = configs.TypeEnum
+		rule.Allowed = []interface{}{c}
+	} else {
+		types := jsonSchemaTypes(prop["type"])
+		switch {
+		case len(types) == 1 && types[0] == "string":
+			rule.Type = configs.TypeString
+		case len(types) == 1 && types[0] == "integer":
+			rule.Type = configs.TypeInt
+		case len(types) == 1 && types[0] == "number":
+			rule.Type = configs.TypeFloat
+		case len(types) == 1 && types[0] == "boolean":
+			rule.Type = configs.TypeBool
+		case len(types) > 0 && onlyContainers(types):
+			rule.Type = configs.TypeJSON
+		default:
+			return rule, fmt.Errorf("property '%s' has unsupported type %v", key, prop["type"])
+		}
+	}
+
+	if v, ok := prop["minimum"].(float64); ok {
+		rule.Min = &v
+	}
+	if v, ok := prop["maximum"].(float64); ok {
+		rule.Max = &v
+	}
+	if v, ok := prop["description"].(string); ok {
+		rule.Description = v
+	}
+	rule.Default = prop["default"]
+	rule.Secret, _ = prop["x-configra-secret"].(bool)
+	if d, ok := prop["x-configra-deprecated"]; ok {
+		b, _ := json.Marshal(d)
+		rule.Deprecated = &configs.Deprecation{}
+		if err := json.Unmarshal(b, rule.Deprecated); err != nil {
+			return rule, fmt.Errorf("property '%s' has an invalid x-configra-deprecated: %w", key, err)
+		}
+	} else if prop["deprecated"] == true {
+		rule.Deprecated = &configs.Deprecation{}
+	}
+	return rule, nil
+}
+
+// jsonSchemaTypes normalizes "type" to a list, dropping "null".
+func jsonSchemaTypes(t interface{}) []string {
+	var raw []interface{}
+	switch v := t.(type) {
+	case string:
+		raw = []interface{}{v}
+	case []interface{}:
+		raw = v
+	}
+	var out []string
+	for _, r := range raw {
+		if s, ok := r.(string); ok && s != "null" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func onlyContainers(types []string) bool {
+	for _, t := range types {
+		if t != "object" && t != "array" {
+			return false
+		}
+	}
+	return true
+}
+
+var topLevelKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"type": true, "properties": true, "required": true, "additionalProperties": true,
+	"x-configra-version": true, "x-configra-constraints": true, "x-configra-unknown-fields": true,
+}
+
+var propertyKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "minimum": true, "maximum": true,
+	"description": true, "default": true, "title": true, "$comment": true,
+	"examples": true, "x-configra-secret": true, "deprecated": true, "x-configra-deprecated": true,
+	"properties": true, "required": true, // Nested rules of json fields
+}
+
+func unsupportedKeywords(key string, obj map[string]interface{}, supported map[string]bool) []string {
+	var out []string
+	for kw := range obj {
+		if supported[kw] {
+			continue
+		}
+		if key == "" {
+			out = append(out, fmt.Sprintf("keyword '%s' is not supported and was ignored", kw))
+		} else {
+			out = append(out, fmt.Sprintf("property '%s': keyword '%s' is not supported and was ignored", key, kw))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,147 @@
+package codegen
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+func testSchema(t *testing.T) configs.Schema {
+	t.Helper()
+	var schema configs.Schema
+	err := json.Unmarshal([]byte(`{
+		"version": 3,
+		"rules": {
+			"app_name":    {"type": "string", "required": true, "description": "Service name."},
+			"max_retries": {"type": "int", "min": 0, "max": 5, "default": 3},
+			"mode":        {"type": "enum", "allowed": ["debug", "release"]},
+			"ratio":       {"type": "float", "required": true},
+			"token":       {"type": "string", "secret": true, "deprecated": {"replaced_by": "app_name", "sunset": "2030-01-01"}},
+			"extra":       {"type": "json"},
+			"db": {"type": "json", "required": true, "fields": {
+				"host":     {"type": "string", "required": true},
+				"password": {"type": "string", "secret": true},
+				"pool":     {"type": "json", "fields": {"max": {"type": "int", "min": 1, "description": "Open connections."}}}
+			}}
+		},
+		"constraints": [
+			{"if": {"field": "mode", "op": "eq", "value": "release"}, "then": {"field": "max_retries", "op": "gte", "value": 1}}
+		]
+	}`), &schema)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	return schema
+}
+
+func TestJSONSchemaRoundTrip(t *testing.T) {
+	schema := testSchema(t)
+
+	// Go through the wire format, as the CLI and API do.
+	raw, err := json.Marshal(JSONSchema(schema, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["title"] != "app" || doc["additionalProperties"] != false {
+		t.Errorf("unexpected document header: %v", doc)
+	}
+	db := doc["properties"].(map[string]interface{})["db"].(map[string]interface{})
+	password := db["properties"].(map[string]interface{})["password"].(map[string]interface{})
+	if !reflect.DeepEqual(db["required"], []interface{}{"host"}) || password["x-configra-secret"] != true {
+		t.Errorf("nested rules of db = %v", db)
+	}
+
+	got, warnings, err := FromJSONSchema(doc)
+	if err != nil {
+		t.Fatalf("FromJSONSchema() error = %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if !reflect.DeepEqual(got, schema) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, schema)
+	}
+
+	schema.UnknownFields = configs.UnknownStrip
+	doc = JSONSchema(schema, "")
+	if doc["additionalProperties"] != true {
+		t.Errorf("additionalProperties = %v, want true for strip", doc["additionalProperties"])
+	}
+	if got, _, _ := FromJSONSchema(doc); got.UnknownFields != configs.UnknownStrip {
+		t.Errorf("UnknownFields = %q after round trip, want strip", got.UnknownFields)
+	}
+}
+
+func TestFromJSONSchemaWarnsOnUnsupportedKeywords(t *testing.T) {
+	var doc map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"host": {"type": "string", "pattern": "^[a-z]+$"},
+			"port": {"type": ["integer", "null"], "minimum": 1},
+			"env":  {"const": "prod"}
+		},
+		"required": ["host"],
+		"oneOf": []
+	}`), &doc)
+
+	schema, warnings, err := FromJSONSchema(doc)
+	if err != nil {
+		t.Fatalf("FromJSONSchema() error = %v", err)
+	}
+	want := []string{
+		"additionalProperties is not false; unknown fields will be accepted with a warning (unknown_fields: warn)",
+		"keyword 'oneOf' is not supported and was ignored",
+		"property 'host': keyword 'pattern' is not supported and was ignored",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %q, want %q", warnings, want)
+	}
+	if schema.UnknownFields != configs.UnknownWarn {
+		t.Errorf("UnknownFields = %q, want warn", schema.UnknownFields)
+	}
+	if r := schema.Rules["port"]; r.Type != configs.TypeInt || r.Required || r.Min == nil || *r.Min != 1 {
+		t.Errorf("port rule = %+v", r)
+	}
+	if r := schema.Rules["env"]; r.Type != configs.TypeEnum || !reflect.DeepEqual(r.Allowed, []interface{}{"prod"}) {
+		t.Errorf("env rule = %+v", r)
+	}
+
+	json.Unmarshal([]byte(`{"properties": {"tags": {"type": ["string", "integer"]}}}`), &doc)
+	if _, _, err := FromJSONSchema(doc); err == nil {
+		t.Error("expected an error for a union of primitive types")
+	}
+}
+
+func TestTypeScript(t *testing.T) {
+	src, err := TypeScript(testSchema(t), "AppConfig")
+	if err != nil {
+		t.Fatalf("TypeScript() error = %v", err)
+	}
+	out := string(src)
+	for _, want := range []string{
+		"export interface AppConfig {",
+		"  /** Service name. */\n  app_name: string;",
+		"   * @minimum 0\n   * @maximum 5\n   * @default 3\n   */\n  max_retries?: number;",
+		`  mode?: "debug" | "release";`,
+		"  ratio: number;",
+		"  /** @deprecated Use app_name instead. Rejected from 2030-01-01. */\n  token?: string;",
+		"  extra?: Record<string, unknown> | unknown[];",
+		"  db: {\n    host: string;\n    password?: string;\n    pool?: {\n      /**\n       * Open connections.\n       * @minimum 1\n       */\n      max?: number;\n      [key: string]: unknown;\n    } | unknown[];\n    [key: string]: unknown;\n  } | unknown[];",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := TypeScript(testSchema(t), "app-config"); err == nil {
+		t.Error("expected an error for an invalid type name")
+	}
+}
@@ -0,0 +1,125 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clyvecute/configra/internal/configs"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// TypeScript renders a declaration file (.d.ts) with an interface mirroring
+// schema. Descriptions, defaults, min/max and deprecations become JSDoc tags.
+// Nested rules of json fields become inline object types.
+func TypeScript(schema configs.Schema, typeName string) ([]byte, error) {
+	if typeName == "" {
+		typeName = "Config"
+	}
+	if !tsIdentifier.MatchString(typeName) {
+		return nil, fmt.Errorf("invalid TypeScript type name %q", typeName)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by configra codegen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "/** Mirrors version %d of its Configra schema. */\n", schema.Version)
+	fmt.Fprintf(&b, "export interface %s {\n", typeName)
+	writeTSMembers(&b, schema.Rules, "  ")
+	fmt.Fprintf(&b, "}\n")
+	return b.Bytes(), nil
+}
+
+// writeTSMembers writes a property per rule, indented by indent.
+func writeTSMembers(b *bytes.Buffer, rules map[string]configs.FieldRule, indent string) {
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		rule := rules[key]
+
+		var doc []string
+		if rule.Description != "" {
+			doc = append(doc, strings.Split(strings.TrimSpace(rule.Description), "\n")...)
+		}
+		if rule.Min != nil {
+			doc = append(doc, fmt.Sprintf("@minimum %v", *rule.Min))
+		}
+		if rule.Max != nil {
+			doc = append(doc, fmt.Sprintf("@maximum %v", *rule.Max))
+		}
+		if rule.Default != nil {
+			d, _ := json.Marshal(rule.Default)
+			doc = append(doc, "@default "+string(d))
+		}
+		if rule.Deprecated != nil {
+			doc = append(doc, "@deprecated "+deprecationNote(rule.Deprecated))
+		}
+		writeJSDoc(b, doc, indent)
+
+		name := key
+		if !tsIdentifier.MatchString(key) {
+			name = fmt.Sprintf("%q", key)
+		}
+		optional := ""
+		if !rule.Required {
+			optional = "?"
+		}
+		if rule.Type == configs.TypeJSON && len(rule.Fields) > 0 {
+			// Like the validator, nested rules don't forbid other keys, and
+			// only apply to objects.
+			fmt.Fprintf(b, "%s%s%s: {\n", indent, name, optional)
+			writeTSMembers(b, rule.Fields, indent+"  ")
+			fmt.Fprintf(b, "%s  [key: string]: unknown;\n%s} | unknown[];\n", indent, indent)
+			continue
+		}
+		fmt.Fprintf(b, "%s%s%s: %s;\n", indent, name, optional, tsType(rule))
+	}
+}
+
+func writeJSDoc(b *bytes.Buffer, lines []string, indent string) {
+	for i, l := range lines {
+		lines[i] = strings.ReplaceAll(l, "*/", `*\/`)
+	}
+	switch len(lines) {
+	case 0:
+		return
+	case 1:
+		fmt.Fprintf(b, "%s/** %s */\n", indent, strings.TrimSpace(lines[0]))
+	default:
+		fmt.Fprintf(b, "%s/**\n", indent)
+		for _, l := range lines {
+			fmt.Fprintf(b, "%s * %s\n", indent, strings.TrimSpace(l))
+		}
+		fmt.Fprintf(b, "%s */\n", indent)
+	}
+}
+
+func tsType(rule configs.FieldRule) string {
+	switch rule.Type {
+	case configs.TypeString:
+		return "string"
+	case configs.TypeInt, configs.TypeFloat:
+		return "number"
+	case configs.TypeBool:
+		return "boolean"
+	case configs.TypeEnum:
+		if len(rule.Allowed) == 0 {
+			return "string | number | boolean"
+		}
+		var parts []string
+		for _, v := range rule.Allowed {
+			lit, _ := json.Marshal(v)
+			parts = append(parts, string(lit))
+		}
+		return strings.Join(parts, " | ")
+	default:
+		return "Record<string, unknown> | unknown[]"
+	}
+}
@@ -0,0 +1,268 @@
+package configs
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CompatibilityMode decides which schema changes are allowed for a config key.
+type CompatibilityMode string
+
+const (
+	// CompatBackward: the new schema accepts every config the old one did, so
+	// existing versions stay valid (no new required fields, no narrowing). It
+	// is the default for every key.
+	CompatBackward CompatibilityMode = "backward"
+	// CompatForward: the old schema accepts every config the new one does, so
+	// consumers built against the old schema can read new versions (no removed
+	// required fields, no widening).
+	CompatForward CompatibilityMode = "forward"
+	// CompatFull requires both. Under the default unknown_fields policy it
+	// allows no field to be added or removed.
+	CompatFull CompatibilityMode = "full"
+	// CompatNone disables checking.
+	CompatNone CompatibilityMode = "none"
+)
+
+// ParseCompatibilityMode validates a mode name; "" means CompatBackward.
+func ParseCompatibilityMode(s string) (CompatibilityMode, error) {
+	switch m := CompatibilityMode(strings.ToLower(s)); m {
+	case "":
+		return CompatBackward, nil
+	case CompatBackward, CompatForward, CompatFull, CompatNone:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown compatibility mode %q (want backward, forward, full or none)", s)
+	}
+}
+
+// CompatibilityError rejects a schema change that breaks the key's mode.
+type CompatibilityError struct {
+	Mode       CompatibilityMode `json:"mode"`
+	Violations []string          `json:"violations"`
+}
+
+func (e *CompatibilityError) Error() string {
+	return fmt.Sprintf("schema change is not %s compatible: %s", e.Mode, strings.Join(e.Violations, "; "))
+}
+
+// CheckCompatibility lists why newSchema may not replace oldSchema under mode.
+// Top-level fields without a rule are rejected unless a schema's
+// unknown_fields policy is warn or strip, so under the default policy removing
+// any field breaks backward compatibility (old configs still hold it) and
+// adding any field breaks forward compatibility (new configs may hold it).
+// Nested fields without a rule are always allowed.
+func CheckCompatibility(oldSchema, newSchema Schema, mode CompatibilityMode) []string {
+	var violations []string
+	if mode == CompatBackward || mode == CompatFull {
+		violations = append(violations, backwardViolations(oldSchema, newSchema)...)
+	}
+	if mode == CompatForward || mode == CompatFull {
+		violations = append(violations, forwardViolations(oldSchema, newSchema)...)
+	}
+	return violations
+}
+
+func containsConstraint(list []Constraint, c Constraint) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func constraintName(c Constraint, i int) string {
+	if c.Description != "" {
+		return fmt.Sprintf("'%s'", c.Description)
+	}
+	return fmt.Sprintf("constraints[%d]", i)
+}
+
+// backwardViolations reports configs valid under oldSchema that newSchema rejects.
+func backwardViolations(oldSchema, newSchema Schema) []string {
+	out := backwardFields("", oldSchema.Rules, newSchema.Rules)
+	if !lenient(newSchema.UnknownFields) {
+		for _, name := range ruleKeys(oldSchema.Rules) {
+			if _, kept := newSchema.Rules[name]; !kept {
+				out = append(out, fmt.Sprintf("backward: field '%s' was removed and unknown fields are rejected", name))
+			}
+		}
+	}
+	if lenient(oldSchema.UnknownFields) && !lenient(newSchema.UnknownFields) {
+		out = append(out, fmt.Sprintf("backward: unknown fields are now rejected (was %s)", oldSchema.UnknownFields))
+	}
+	// Constraints are compared as a whole; a new one may reject old configs.
+	for i, c := range newSchema.Constraints {
+		if !containsConstraint(oldSchema.Constraints, c) {
+			out = append(out, fmt.Sprintf("backward: constraint %s was added", constraintName(c, i)))
+		}
+	}
+	return out
+}
+
+func lenient(p UnknownFieldPolicy) bool {
+	return p == UnknownWarn || p == UnknownStrip
+}
+
+// backwardFields compares two rule sets, descending into nested fields.
+func backwardFields(prefix string, oldRules, newRules map[string]FieldRule) []string {
+	var out []string
+	for _, name := range ruleKeys(newRules) {
+		key := prefix + name
+		rule := newRules[name]
+		old, existed := oldRules[name]
+		if rule.Required && rule.Default == nil && (!existed || !old.Required) {
+			if existed {
+				out = append(out, fmt.Sprintf("backward: field '%s' became required without a default", key))
+			} else {
+				out = append(out, fmt.Sprintf("backward: field '%s' was added as required without a default", key))
+			}
+		}
+		if existed {
+			out = append(out, narrowed(key, old, rule)...)
+			out = append(out, backwardFields(key+".", old.Fields, rule.Fields)...)
+		}
+	}
+	return out
+}
+
+// forwardViolations reports configs valid under newSchema that oldSchema rejects.
+func forwardViolations(oldSchema, newSchema Schema) []string {
+	out := forwardFields("", oldSchema.Rules, newSchema.Rules)
+	if !lenient(oldSchema.UnknownFields) {
+		for _, name := range ruleKeys(newSchema.Rules) {
+			if _, known := oldSchema.Rules[name]; !known {
+				out = append(out, fmt.Sprintf("forward: field '%s' was added and the old schema rejects unknown fields", name))
+			}
+		}
+	}
+	// Stripped fields are never stored, so only warn lets them reach consumers.
+	if newSchema.UnknownFields == UnknownWarn && oldSchema.UnknownFields != UnknownWarn {
+		out = append(out, "forward: unknown fields are now stored (unknown_fields is warn)")
+	}
+	// A removed constraint may let through configs old consumers reject.
+	for i, c := range oldSchema.Constraints {
+		if !containsConstraint(newSchema.Constraints, c) {
+			out = append(out, fmt.Sprintf("forward: constraint %s was removed", constraintName(c, i)))
+		}
+	}
+	return out
+}
+
+// forwardFields compares two rule sets, descending into nested fields.
+func forwardFields(prefix string, oldRules, newRules map[string]FieldRule) []string {
+	var out []string
+	for _, name := range ruleKeys(oldRules) {
+		key := prefix + name
+		old := oldRules[name]
+		rule, exists := newRules[name]
+		if old.Required && !exists {
+			out = append(out, fmt.Sprintf("forward: required field '%s' was removed", key))
+			continue
+		}
+		if !exists {
+			continue
+		}
+		// Consumers that read with defaults resolved still see the field.
+		if old.Required && !rule.Required && rule.Default == nil {
+			out = append(out, fmt.Sprintf("forward: field '%s' is no longer required and has no default", key))
+		}
+		out = append(out, widened(key, old, rule)...)
+		out = append(out, forwardFields(key+".", old.Fields, rule.Fields)...)
+	}
+	return out
+}
+
+// narrowed reports values newRule rejects that oldRule accepted.
+func narrowed(key string, oldRule, newRule FieldRule) []string {
+	var out []string
+	report := func(format string, args ...interface{}) {
+		out = append(out, fmt.Sprintf("backward: field '%s' ", key)+fmt.Sprintf(format, args...))
+	}
+
+	if oldRule.Type != newRule.Type && !(oldRule.Type == TypeInt && newRule.Type == TypeFloat) {
+		report("changed type from %s to %s", oldRule.Type, newRule.Type)
+		return out
+	}
+	if len(newRule.Allowed) > 0 {
+		if len(oldRule.Allowed) == 0 {
+			report("is now restricted to %v", newRule.Allowed)
+		} else if dropped := missingValues(oldRule.Allowed, newRule.Allowed); len(dropped) > 0 {
+			report("no longer allows %v", dropped)
+		}
+	}
+	if newRule.Min != nil && oldRule.Min == nil {
+		report("gained a minimum of %v", *newRule.Min)
+	} else if newRule.Min != nil && *newRule.Min > *oldRule.Min {
+		report("minimum raised from %v to %v", *oldRule.Min, *newRule.Min)
+	}
+	if newRule.Max != nil && oldRule.Max == nil {
+		report("gained a maximum of %v", *newRule.Max)
+	} else if newRule.Max != nil && *newRule.Max < *oldRule.Max {
+		report("maximum lowered from %v to %v", *oldRule.Max, *newRule.Max)
+	}
+	return out
+}
+
+// widened reports values newRule accepts that oldRule rejected.
+func widened(key string, oldRule, newRule FieldRule) []string {
+	var out []string
+	report := func(format string, args ...interface{}) {
+		out = append(out, fmt.Sprintf("forward: field '%s' ", key)+fmt.Sprintf(format, args...))
+	}
+
+	if oldRule.Type != newRule.Type && !(oldRule.Type == TypeFloat && newRule.Type == TypeInt) {
+		report("changed type from %s to %s", oldRule.Type, newRule.Type)
+		return out
+	}
+	if len(oldRule.Allowed) > 0 {
+		if len(newRule.Allowed) == 0 {
+			report("is no longer restricted to %v", oldRule.Allowed)
+		} else if added := missingValues(newRule.Allowed, oldRule.Allowed); len(added) > 0 {
+			report("now also allows %v", added)
+		}
+	}
+	if oldRule.Min != nil && newRule.Min == nil {
+		report("lost its minimum of %v", *oldRule.Min)
+	} else if oldRule.Min != nil && *newRule.Min < *oldRule.Min {
+		report("minimum lowered from %v to %v", *oldRule.Min, *newRule.Min)
+	}
+	if oldRule.Max != nil && newRule.Max == nil {
+		report("lost its maximum of %v", *oldRule.Max)
+	} else if oldRule.Max != nil && *newRule.Max > *oldRule.Max {
+		report("maximum raised from %v to %v", *oldRule.Max, *newRule.Max)
+	}
+	return out
+}
+
+// missingValues returns the values of list that are not in other.
+func missingValues(list, other []interface{}) []interface{} {
+	var out []interface{}
+	for _, v := range list {
+		if !containsValue(other, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleKeys(rules map[string]FieldRule) []string {
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package configs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func mustSchema(t *testing.T, src string) Schema {
+	t.Helper()
+	var s Schema
+	if err := json.Unmarshal([]byte(src), &s); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	return s
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	base := mustSchema(t, `{"version": 1, "rules": {
+		"app_name":    {"type": "string", "required": true},
+		"max_retries": {"type": "int", "min": 0, "max": 5},
+		"mode":        {"type": "enum", "allowed": ["debug", "release"]},
+		"ratio":       {"type": "int"}
+	}}`)
+
+	tests := []struct {
+		name     string
+		schema   string
+		backward []string
+		forward  []string
+	}{
+		{
+			name: "unchanged",
+			schema: `{"version": 1, "rules": {
+				"app_name":    {"type": "string", "required": true},
+				"max_retries": {"type": "int", "min": 0, "max": 5},
+				"mode":        {"type": "enum", "allowed": ["debug", "release"]},
+				"ratio":       {"type": "int"}
+			}}`,
+		},
+		{
+			name: "narrowed",
+			schema: `{"version": 2, "rules": {
+				"app_name":    {"type": "string", "required": true},
+				"max_retries": {"type": "int", "min": 1, "max": 3},
+				"mode":        {"type": "enum", "allowed": ["release"]},
+				"ratio":       {"type": "int"},
+				"region":      {"type": "string", "required": true}
+			}}`,
+			backward: []string{
+				"backward: field 'max_retries' minimum raised from 0 to 1",
+				"backward: field 'max_retries' maximum lowered from 5 to 3",
+				"backward: field 'mode' no longer allows [debug]",
+				"backward: field 'region' was added as required without a default",
+			},
+			forward: []string{
+				"forward: field 'region' was added and the old schema rejects unknown fields",
+			},
+		},
+		{
+			name: "widened",
+			schema: `{"version": 2, "rules": {
+				"max_retries": {"type": "int", "min": 0},
+				"mode":        {"type": "enum", "allowed": ["debug", "release", "canary"]},
+				"ratio":       {"type": "float"},
+				"region":      {"type": "string", "required": true, "default": "eu"}
+			}}`,
+			backward: []string{
+				"backward: field 'app_name' was removed and unknown fields are rejected",
+			},
+			forward: []string{
+				"forward: required field 'app_name' was removed",
+				"forward: field 'max_retries' lost its maximum of 5",
+				"forward: field 'mode' now also allows [canary]",
+				"forward: field 'ratio' changed type from int to float",
+				"forward: field 'region' was added and the old schema rejects unknown fields",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustSchema(t, tt.schema)
+			if got := CheckCompatibility(base, s, CompatBackward); !reflect.DeepEqual(got, tt.backward) {
+				t.Errorf("backward = %q, want %q", got, tt.backward)
+			}
+			if got := CheckCompatibility(base, s, CompatForward); !reflect.DeepEqual(got, tt.forward) {
+				t.Errorf("forward = %q, want %q", got, tt.forward)
+			}
+			if got := CheckCompatibility(base, s, CompatFull); len(got) != len(tt.backward)+len(tt.forward) {
+				t.Errorf("full = %q, want backward and forward violations", got)
+			}
+			if got := CheckCompatibility(base, s, CompatNone); got != nil {
+				t.Errorf("none = %q, want no violations", got)
+			}
+		})
+	}
+}
+
+func TestCheckCompatibilityRequired(t *testing.T) {
+	old := mustSchema(t, `{"rules": {"a": {"type": "string"}, "b": {"type": "string", "required": true}}}`)
+	s := mustSchema(t, `{"rules": {"a": {"type": "string", "required": true}, "b": {"type": "string"}}}`)
+
+	want := []string{
+		"backward: field 'a' became required without a default",
+		"forward: field 'b' is no longer required and has no default",
+	}
+	if got := CheckCompatibility(old, s, CompatFull); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckCompatibility() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckCompatibilityNested(t *testing.T) {
+	old := mustSchema(t, `{"rules": {"db": {"type": "json", "fields": {"pool": {"type": "int", "max": 10}}}}}`)
+	s := mustSchema(t, `{"rules": {"db": {"type": "json", "fields": {"pool": {"type": "int", "max": 5}, "host": {"type": "string", "required": true}}}}}`)
+
+	want := []string{
+		"backward: field 'db.host' was added as required without a default",
+		"backward: field 'db.pool' maximum lowered from 10 to 5",
+	}
+	if got := CheckCompatibility(old, s, CompatBackward); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckCompatibility() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckCompatibilityUnknownFields(t *testing.T) {
+	rules := map[string]FieldRule{"a": {Type: TypeString}}
+	strict := Schema{Rules: rules}
+	warn := Schema{Rules: rules, UnknownFields: UnknownWarn}
+	strip := Schema{Rules: rules, UnknownFields: UnknownStrip}
+
+	if got := CheckCompatibility(strict, strip, CompatFull); len(got) != 0 {
+		t.Errorf("reject -> strip: %q, want no violations", got)
+	}
+	if got, want := CheckCompatibility(strict, warn, CompatFull), []string{"forward: unknown fields are now stored (unknown_fields is warn)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reject -> warn: %q, want %q", got, want)
+	}
+	if got, want := CheckCompatibility(warn, strict, CompatFull), []string{"backward: unknown fields are now rejected (was warn)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("warn -> reject: %q, want %q", got, want)
+	}
+}
+
+func TestCheckCompatibilityAddedAndRemovedFields(t *testing.T) {
+	old := mustSchema(t, `{"rules": {"a": {"type": "string", "required": true}, "b": {"type": "string"}}}`)
+	s := mustSchema(t, `{"rules": {"c": {"type": "string"}}}`)
+
+	want := []string{
+		"backward: field 'a' was removed and unknown fields are rejected",
+		"backward: field 'b' was removed and unknown fields are rejected",
+	}
+	if got := CheckCompatibility(old, s, CompatBackward); !reflect.DeepEqual(got, want) {
+		t.Errorf("backward = %q, want %q", got, want)
+	}
+	want = []string{
+		"forward: required field 'a' was removed",
+		"forward: field 'c' was added and the old schema rejects unknown fields",
+	}
+	if got := CheckCompatibility(old, s, CompatForward); !reflect.DeepEqual(got, want) {
+		t.Errorf("forward = %q, want %q", got, want)
+	}
+
+	// Schemas that let unknown fields through only care about required ones.
+	old.UnknownFields, s.UnknownFields = UnknownStrip, UnknownStrip
+	want = []string{"forward: required field 'a' was removed"}
+	if got := CheckCompatibility(old, s, CompatFull); !reflect.DeepEqual(got, want) {
+		t.Errorf("strip: full = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultModeAllowsOptionalFields(t *testing.T) {
+	mode, _ := ParseCompatibilityMode("")
+	old := mustSchema(t, `{"rules": {"a": {"type": "string", "required": true}}}`)
+
+	added := mustSchema(t, `{"rules": {"a": {"type": "string", "required": true}, "b": {"type": "int"}}}`)
+	if got := CheckCompatibility(old, added, mode); len(got) != 0 {
+		t.Errorf("adding an optional field under the default mode: %q", got)
+	}
+	// Old configs may still hold a removed field, which is then unknown.
+	want := []string{"backward: field 'b' was removed and unknown fields are rejected"}
+	if got := CheckCompatibility(added, old, mode); !reflect.DeepEqual(got, want) {
+		t.Errorf("removing a field under the default mode = %q, want %q", got, want)
+	}
+}
+
+func TestParseCompatibilityMode(t *testing.T) {
+	if m, err := ParseCompatibilityMode(""); err != nil || m != CompatBackward {
+		t.Errorf("ParseCompatibilityMode(\"\") = %q, %v; want backward", m, err)
+	}
+	if m, err := ParseCompatibilityMode("Backward"); err != nil || m != CompatBackward {
+		t.Errorf("ParseCompatibilityMode(\"Backward\") = %q, %v", m, err)
+	}
+	if _, err := ParseCompatibilityMode("transitive"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
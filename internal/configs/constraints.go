@@ -0,0 +1,402 @@
+package configs
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/clyvecute/configra/internal/expr"
+)
+
+// Constraint is a schema-level rule relating several fields. Exactly one of
+// its kinds (If/Then, Assert, ExactlyOneOf, DependentRequired, Expr) is set.
+// Fields are addressed by path; nested json fields use dots ("db.pool").
+type Constraint struct {
+	Description string `json:"description,omitempty"` // Names the rule in errors
+
+	// If/Then: whenever If holds, Then must hold too.
+	If   *Condition `json:"if,omitempty"`
+	Then *Condition `json:"then,omitempty"`
+
+	// Assert must hold whenever all fields it names are set, e.g.
+	// {"field": "min_pool", "op": "lte", "other": "max_pool"}.
+	Assert *Condition `json:"assert,omitempty"`
+
+	// ExactlyOneOf lists fields of which exactly one must be set.
+	ExactlyOneOf []string `json:"exactly_one_of,omitempty"`
+
+	// DependentRequired maps a field to fields that must be set whenever it is.
+	DependentRequired map[string][]string `json:"dependent_required,omitempty"`
+
+	// Expr is an expression over the config that must evaluate to true, e.g.
+	// `size(allowed_origins) <= 20 && all(allowed_origins, startsWith("https://"))`.
+	// See package expr for the language.
+	Expr string `json:"expr,omitempty"`
+}
+
+// Condition compares a field with a literal Value or with an Other field.
+type Condition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"` // eq, ne, lt, lte, gt, gte, in, present, absent
+	Value interface{} `json:"value,omitempty"`
+	Other string      `json:"other,omitempty"`
+}
+
+// RuleViolation is a failed constraint with every field it involves.
+type RuleViolation struct {
+	Rule    string   `json:"rule"` // Description, or constraints[i]
+	Fields  []string `json:"fields"`
+	Message string   `json:"message"`
+}
+
+var conditionOps = map[string]string{
+	"eq": "==", "ne": "!=", "lt": "<", "lte": "<=", "gt": ">", "gte": ">=",
+	"in": "in", "present": "is set", "absent": "is not set",
+}
+
+// checkConstraints evaluates schema constraints against a config whose fields
+// already passed their own rules. programs holds the compiled expressions,
+// as returned by compileConstraints.
+func checkConstraints(constraints []Constraint, programs []*expr.Program, config map[string]interface{}) []RuleViolation {
+	var out []RuleViolation
+	for i, c := range constraints {
+		rule := c.Description
+		if rule == "" {
+			rule = fmt.Sprintf("constraints[%d]", i)
+		}
+		for _, v := range c.evaluate(programs[i], config) {
+			v.Rule = rule
+			if c.Description != "" {
+				v.Message = c.Description + ": " + v.Message
+			}
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (c Constraint) evaluate(program *expr.Program, config map[string]interface{}) []RuleViolation {
+	switch {
+	case c.If != nil:
+		if c.If.holds(config) && !c.Then.holds(config) {
+			return []RuleViolation{{
+				Fields:  appendUnique(c.If.fields(), c.Then.fields()...),
+				Message: fmt.Sprintf("%s when %s", c.Then.describe("must be"), c.If.describe("")),
+			}}
+		}
+	case c.Assert != nil:
+		for _, f := range c.Assert.fields() {
+			if _, ok := lookupPath(config, f); !ok {
+				return nil
+			}
+		}
+		if !c.Assert.holds(config) {
+			return []RuleViolation{{Fields: c.Assert.fields(), Message: c.Assert.describe("must be")}}
+		}
+	case len(c.ExactlyOneOf) > 0:
+		var set []string
+		for _, f := range c.ExactlyOneOf {
+			if _, ok := lookupPath(config, f); ok {
+				set = append(set, f)
+			}
+		}
+		if len(set) != 1 {
+			return []RuleViolation{{
+				Fields:  c.ExactlyOneOf,
+				Message: fmt.Sprintf("exactly one of fields %s must be set, got %d", quoteList(c.ExactlyOneOf), len(set)),
+			}}
+		}
+	case c.Expr != "":
+		return evaluateExpr(program, config)
+	default:
+		var out []RuleViolation
+		for _, field := range sortedKeysOf(c.DependentRequired) {
+			if _, ok := lookupPath(config, field); !ok {
+				continue
+			}
+			for _, dep := range c.DependentRequired[field] {
+				if _, ok := lookupPath(config, dep); !ok {
+					out = append(out, RuleViolation{
+						Fields:  []string{field, dep},
+						Message: fmt.Sprintf("field '%s' is required when field '%s' is set", dep, field),
+					})
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// holds reports whether the condition is true. A missing field only
+// satisfies "absent".
+func (c *Condition) holds(config map[string]interface{}) bool {
+	val, ok := lookupPath(config, c.Field)
+	switch c.Op {
+	case "present":
+		return ok
+	case "absent":
+		return !ok
+	}
+	if !ok {
+		return false
+	}
+
+	want := c.Value
+	if c.Other != "" {
+		if want, ok = lookupPath(config, c.Other); !ok {
+			return false
+		}
+	}
+
+	switch c.Op {
+	case "eq":
+		return valuesEqual(val, want)
+	case "ne":
+		return !valuesEqual(val, want)
+	case "in":
+		list, _ := want.([]interface{})
+		for _, item := range list {
+			if valuesEqual(val, item) {
+				return true
+			}
+		}
+		return false
+	case "lt", "lte", "gt", "gte":
+		cmp, ok := compareValues(val, want)
+		if !ok {
+			return false
+		}
+		switch c.Op {
+		case "lt":
+			return cmp < 0
+		case "lte":
+			return cmp <= 0
+		case "gt":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}
+	return false
+}
+
+func (c *Condition) fields() []string {
+	if c.Other != "" {
+		return []string{c.Field, c.Other}
+	}
+	return []string{c.Field}
+}
+
+// describe renders the condition for error messages, e.g. "field 'a' must be
+// <= field 'b'" with verb "must be", or "field 'mode' == release" without.
+func (c *Condition) describe(verb string) string {
+	subject := fmt.Sprintf("field '%s'", c.Field)
+	if c.Op == "present" || c.Op == "absent" {
+		switch {
+		case verb == "":
+			return fmt.Sprintf("%s %s", subject, conditionOps[c.Op])
+		case c.Op == "present":
+			return subject + " must be set"
+		default:
+			return subject + " must not be set"
+		}
+	}
+	target := fmt.Sprintf("%v", c.Value)
+	if c.Other != "" {
+		target = fmt.Sprintf("field '%s'", c.Other)
+	}
+	if verb != "" {
+		subject += " " + verb
+	}
+	return fmt.Sprintf("%s %s %s", subject, conditionOps[c.Op], target)
+}
+
+// constraintErrors reports constraints that cannot be evaluated: unknown
+// operators, malformed rules and references to fields not in the schema.
+func constraintErrors(schema Schema) []string {
+	_, errs := compileConstraints(schema)
+	return errs
+}
+
+// compileConstraints checks the constraints like constraintErrors, and
+// compiles their expressions against the schema's fields. The programs are
+// indexed like the constraints; those without an expression are nil.
+func compileConstraints(schema Schema) ([]*expr.Program, []string) {
+	var errs []string
+	programs := make([]*expr.Program, len(schema.Constraints))
+	known := func(i int, path string) {
+		if _, ok := schema.Rules[strings.SplitN(path, ".", 2)[0]]; !ok {
+			errs = append(errs, fmt.Sprintf("constraints[%d]: field '%s' is not in the schema", i, path))
+		}
+	}
+	checkCond := func(i int, name string, c *Condition) {
+		if c == nil {
+			errs = append(errs, fmt.Sprintf("constraints[%d]: '%s' is required", i, name))
+			return
+		}
+		if _, ok := conditionOps[c.Op]; !ok {
+			errs = append(errs, fmt.Sprintf("constraints[%d]: unknown operator %q", i, c.Op))
+		}
+		if _, ok := c.Value.([]interface{}); c.Op == "in" && !ok {
+			errs = append(errs, fmt.Sprintf("constraints[%d]: operator \"in\" needs a list value", i))
+		}
+		known(i, c.Field)
+		if c.Other != "" {
+			known(i, c.Other)
+		}
+	}
+
+	for i, c := range schema.Constraints {
+		kinds := 0
+		for _, set := range []bool{c.If != nil || c.Then != nil, c.Assert != nil, len(c.ExactlyOneOf) > 0, len(c.DependentRequired) > 0, c.Expr != ""} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			errs = append(errs, fmt.Sprintf("constraints[%d]: exactly one of if/then, assert, exactly_one_of, dependent_required or expr must be set", i))
+			continue
+		}
+		switch {
+		case c.If != nil || c.Then != nil:
+			checkCond(i, "if", c.If)
+			checkCond(i, "then", c.Then)
+		case c.Assert != nil:
+			checkCond(i, "assert", c.Assert)
+		case len(c.ExactlyOneOf) > 0:
+			for _, f := range c.ExactlyOneOf {
+				known(i, f)
+			}
+		case c.Expr != "":
+			program, err := expr.Compile(c.Expr, exprVars(schema))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("constraints[%d]: invalid expr: %v", i, err))
+			}
+			programs[i] = program
+		default:
+			for _, field := range sortedKeysOf(c.DependentRequired) {
+				known(i, field)
+				for _, dep := range c.DependentRequired[field] {
+					known(i, dep)
+				}
+			}
+		}
+	}
+	return programs, errs
+}
+
+// exprVars declares every schema field to the expression compiler.
+func exprVars(schema Schema) map[string]expr.Type {
+	vars := map[string]expr.Type{}
+	for key, rule := range schema.Rules {
+		switch rule.Type {
+		case TypeString:
+			vars[key] = expr.String
+		case TypeInt, TypeFloat:
+			vars[key] = expr.Number
+		case TypeBool:
+			vars[key] = expr.Bool
+		default:
+			vars[key] = expr.Dyn
+		}
+	}
+	return vars
+}
+
+// evaluateExpr runs an expression constraint compiled by compileConstraints.
+// The only failures left are a false result and evaluation errors such as a
+// missing optional field, or an exhausted budget.
+func evaluateExpr(program *expr.Program, config map[string]interface{}) []RuleViolation {
+	src := program.Source()
+	ok, err := program.Eval(config)
+	switch {
+	case err != nil:
+		return []RuleViolation{{Fields: program.Fields(), Message: fmt.Sprintf("expression `%s` could not be evaluated: %v", src, err)}}
+	case !ok:
+		return []RuleViolation{{Fields: program.Fields(), Message: fmt.Sprintf("expression `%s` is false", src)}}
+	}
+	return nil
+}
+
+// lookupPath resolves a dotted path through nested objects.
+func lookupPath(config map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = config
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok || cur == nil {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+func appendUnique(list []string, more ...string) []string {
+	for _, s := range more {
+		if !containsString(list, s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteList(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = "'" + f + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func sortedKeysOf(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+package configs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConstraints(t *testing.T) {
+	schema := mustSchema(t, `{
+		"version": 1,
+		"rules": {
+			"mode":        {"type": "enum", "allowed": ["debug", "release"]},
+			"max_retries": {"type": "int", "default": 0},
+			"min_pool":    {"type": "int"},
+			"max_pool":    {"type": "int"},
+			"token":       {"type": "string"},
+			"token_file":  {"type": "string"},
+			"tls_cert":    {"type": "string"},
+			"tls_key":     {"type": "string"},
+			"db":          {"type": "json"},
+			"origins":     {"type": "json"}
+		},
+		"constraints": [
+			{"if": {"field": "mode", "op": "eq", "value": "release"}, "then": {"field": "max_retries", "op": "gte", "value": 1}},
+			{"exactly_one_of": ["token", "token_file"]},
+			{"description": "pool bounds", "assert": {"field": "min_pool", "op": "lte", "other": "max_pool"}},
+			{"dependent_required": {"tls_cert": ["tls_key"]}},
+			{"assert": {"field": "db.replicas", "op": "lt", "value": 10}},
+			{"description": "origins", "expr": "!has(origins) || all(origins, startsWith('https://'))"},
+			{"expr": "!has(db.pool) || db.pool <= max_pool"}
+		]
+	}`)
+
+	tests := []struct {
+		name   string
+		config string
+		want   []RuleViolation
+	}{
+		{
+			name:   "valid",
+			config: `{"mode": "release", "max_retries": 2, "token": "t", "min_pool": 1, "max_pool": 5}`,
+		},
+		{
+			name:   "assert skipped when a field is missing",
+			config: `{"mode": "debug", "token": "t", "min_pool": 10}`,
+		},
+		{
+			name:   "if/then sees defaults",
+			config: `{"mode": "release", "token": "t"}`,
+			want: []RuleViolation{{
+				Rule:    "constraints[0]",
+				Fields:  []string{"mode", "max_retries"},
+				Message: "field 'max_retries' must be >= 1 when field 'mode' == release",
+			}},
+		},
+		{
+			name:   "exactly one of, pool bounds, dependent and nested",
+			config: `{"token": "t", "token_file": "/t", "min_pool": 10, "max_pool": 5, "tls_cert": "c", "db": {"replicas": 12}}`,
+			want: []RuleViolation{
+				{Rule: "constraints[1]", Fields: []string{"token", "token_file"}, Message: "exactly one of fields 'token', 'token_file' must be set, got 2"},
+				{Rule: "pool bounds", Fields: []string{"min_pool", "max_pool"}, Message: "pool bounds: field 'min_pool' must be <= field 'max_pool'"},
+				{Rule: "constraints[3]", Fields: []string{"tls_cert", "tls_key"}, Message: "field 'tls_key' is required when field 'tls_cert' is set"},
+				{Rule: "constraints[4]", Fields: []string{"db.replicas"}, Message: "field 'db.replicas' must be < 10"},
+			},
+		},
+		{
+			name:   "expressions",
+			config: `{"token": "t", "origins": ["https://a", "http://b"], "db": {"pool": 3}}`,
+			want: []RuleViolation{
+				{Rule: "origins", Fields: []string{"origins"}, Message: "origins: expression `!has(origins) || all(origins, startsWith('https://'))` is false"},
+				{Rule: "constraints[6]", Fields: []string{"db", "max_pool"}, Message: "expression `!has(db.pool) || db.pool <= max_pool` could not be evaluated: column 29: no such field 'max_pool' (use has() for optional fields)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.config), &config); err != nil {
+				t.Fatal(err)
+			}
+			err := Validate(schema, config)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Validate() = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(verr.Violations, tt.want) {
+				t.Errorf("violations = %+v\nwant %+v", verr.Violations, tt.want)
+			}
+			if len(verr.Errors) != len(tt.want) {
+				t.Errorf("errors = %q, want one per violation", verr.Errors)
+			}
+		})
+	}
+}
+
+func TestConstraintErrors(t *testing.T) {
+	schema := mustSchema(t, `{
+		"rules": {"a": {"type": "int"}},
+		"constraints": [
+			{"assert": {"field": "a", "op": "like", "value": 1}},
+			{"if": {"field": "a", "op": "present"}},
+			{"exactly_one_of": ["a", "b"]},
+			{"assert": {"field": "a", "op": "in", "value": 1}},
+			{},
+			{"expr": "b > 1"},
+			{"expr": "a > 'x'"}
+		]
+	}`)
+	want := []string{
+		`constraints[0]: unknown operator "like"`,
+		"constraints[1]: 'then' is required",
+		"constraints[2]: field 'b' is not in the schema",
+		`constraints[3]: operator "in" needs a list value`,
+		"constraints[4]: exactly one of if/then, assert, exactly_one_of, dependent_required or expr must be set",
+		"constraints[5]: invalid expr: column 1: undeclared reference to 'b'",
+		"constraints[6]: invalid expr: column 3: operator '>' cannot be applied to number and string",
+	}
+	if got := constraintErrors(schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("constraintErrors() = %q\nwant %q", got, want)
+	}
+	if err := Validate(schema, map[string]interface{}{"a": float64(1)}); err == nil {
+		t.Error("Validate() accepted a schema with invalid constraints")
+	}
+}
+
+func TestCheckCompatibilityConstraints(t *testing.T) {
+	old := mustSchema(t, `{"rules": {"a": {"type": "int"}, "b": {"type": "int"}},
+		"constraints": [{"description": "a below b", "assert": {"field": "a", "op": "lt", "other": "b"}}]}`)
+	s := mustSchema(t, `{"rules": {"a": {"type": "int"}, "b": {"type": "int"}},
+		"constraints": [{"exactly_one_of": ["a", "b"]}]}`)
+
+	want := []string{
+		"backward: constraint constraints[0] was added",
+		"forward: constraint 'a below b' was removed",
+	}
+	if got := CheckCompatibility(old, s, CompatFull); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckCompatibility() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a serialization format accepted for configs and schemas.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// FormatFromPath picks a format from a file extension, defaulting to JSON.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// FormatFromContentType picks a format from a Content-Type header, defaulting to JSON.
+func FormatFromContentType(contentType string) Format {
+	ct := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case strings.HasSuffix(ct, "yaml"), strings.HasSuffix(ct, "yml"):
+		return FormatYAML
+	case strings.HasSuffix(ct, "toml"):
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// DecodeMap parses a document in the given format into a map whose values
+// look exactly like encoding/json output: numbers are float64, objects are
+// map[string]interface{} and arrays are []interface{}. This keeps type checks
+// such as TypeInt behaving the same whatever format the input came in.
+func DecodeMap(b []byte, format Format) (map[string]interface{}, error) {
+	var raw interface{}
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	case FormatTOML:
+		var m map[string]interface{}
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("invalid TOML: %w", err)
+		}
+		raw = m
+	default:
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return m, nil
+	}
+
+	if raw == nil {
+		return map[string]interface{}{}, nil
+	}
+	norm, err := normalize(raw)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := norm.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a top-level object, got %T", raw)
+	}
+	return m, nil
+}
+
+// DecodeSchema parses a schema document in the given format.
+func DecodeSchema(b []byte, format Format) (Schema, Map, error) {
+	var schema Schema
+	raw, err := DecodeMap(b, format)
+	if err != nil {
+		return schema, nil, err
+	}
+	if err := convert(raw, &schema); err != nil {
+		return schema, nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return schema, raw, nil
+}
+
+// DecodeInto decodes a document in the given format into a struct by way of
+// its JSON tags, so request types only need to be declared once.
+func DecodeInto(b []byte, format Format, out interface{}) error {
+	if format == FormatJSON {
+		return json.NewDecoder(bytes.NewReader(b)).Decode(out)
+	}
+	m, err := DecodeMap(b, format)
+	if err != nil {
+		return err
+	}
+	return convert(m, out)
+}
+
+func convert(in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func normalize(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = n
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(k)] = n
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	case []map[string]interface{}: // TOML arrays of tables
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case uint64:
+		return float64(val), nil
+	case float64:
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			return nil, fmt.Errorf("non-finite number %v is not supported", val)
+		}
+		return val, nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	default:
+		return val, nil
+	}
+}
@@ -0,0 +1,51 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMapNormalizesFormats(t *testing.T) {
+	docs := map[Format]string{
+		FormatJSON: `{"app_name": "svc", "max_retries": 3, "ratio": 0.5, "db": {"hosts": ["a", "b"]}}`,
+		FormatYAML: "app_name: svc\nmax_retries: 3\nratio: 0.5\ndb:\n  hosts: [a, b]\n",
+		FormatTOML: "app_name = \"svc\"\nmax_retries = 3\nratio = 0.5\n[db]\nhosts = [\"a\", \"b\"]\n",
+	}
+
+	want, err := DecodeMap([]byte(docs[FormatJSON]), FormatJSON)
+	if err != nil {
+		t.Fatalf("DecodeMap(json) error = %v", err)
+	}
+
+	schema := Schema{Rules: map[string]FieldRule{
+		"app_name":    {Type: TypeString, Required: true},
+		"max_retries": {Type: TypeInt},
+		"ratio":       {Type: TypeFloat},
+		"db":          {Type: TypeJSON},
+	}}
+
+	for format, doc := range docs {
+		got, err := DecodeMap([]byte(doc), format)
+		if err != nil {
+			t.Fatalf("DecodeMap(%s) error = %v", format, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("DecodeMap(%s) = %#v, want %#v", format, got, want)
+		}
+		if err := Validate(schema, got); err != nil {
+			t.Errorf("Validate(%s input) error = %v", format, err)
+		}
+	}
+}
+
+func TestFormatDetection(t *testing.T) {
+	if got := FormatFromPath("configs/prod/app.YML"); got != FormatYAML {
+		t.Errorf("FormatFromPath(.YML) = %s, want yaml", got)
+	}
+	if got := FormatFromContentType("application/toml; charset=utf-8"); got != FormatTOML {
+		t.Errorf("FormatFromContentType(toml) = %s, want toml", got)
+	}
+	if got := FormatFromContentType("text/plain"); got != FormatJSON {
+		t.Errorf("FormatFromContentType(text/plain) = %s, want json", got)
+	}
+}
@@ -0,0 +1,75 @@
+package configs
+
+import "fmt"
+
+// ApplyDefaults returns a copy of config with the schema's defaults filled in
+// for missing fields. It descends into json objects with nested rules; a
+// missing object is created when its nested defaults would give it content.
+// Written values are kept as they are, and config is not modified.
+func ApplyDefaults(schema Schema, config map[string]interface{}) map[string]interface{} {
+	return applyDefaults(schema.Rules, config)
+}
+
+func applyDefaults(rules map[string]FieldRule, config map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(config)+len(rules))
+	for k, v := range config {
+		out[k] = v
+	}
+
+	for name, rule := range rules {
+		val, exists := out[name]
+		if !exists && rule.Default != nil {
+			val, exists = copyValue(rule.Default), true
+		}
+		if len(rule.Fields) == 0 {
+			if exists {
+				out[name] = val
+			}
+			continue
+		}
+
+		nested, isObject := val.(map[string]interface{})
+		switch {
+		case isObject:
+			out[name] = applyDefaults(rule.Fields, nested)
+		case !exists:
+			if filled := applyDefaults(rule.Fields, nil); len(filled) > 0 {
+				out[name] = filled
+			}
+		}
+	}
+	return out
+}
+
+// copyValue deep-copies decoded JSON so that defaults handed out to callers
+// never alias the schema.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = copyValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// WithDefaults returns a copy of cfg whose data has the defaults of its stored
+// schema filled in. Versions only store what was written, so reads ask for
+// this explicitly.
+func WithDefaults(cfg *Config) (*Config, error) {
+	var schema Schema
+	if err := convert(cfg.Schema, &schema); err != nil {
+		return nil, fmt.Errorf("invalid stored schema: %w", err)
+	}
+	out := *cfg
+	out.Data = ApplyDefaults(schema, cfg.Data)
+	return &out, nil
+}
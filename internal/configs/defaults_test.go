@@ -0,0 +1,107 @@
+package configs
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var nestedSchema = `{
+	"version": 1,
+	"rules": {
+		"mode":    {"type": "string", "default": "debug"},
+		"origins": {"type": "json", "default": ["https://a"]},
+		"db": {"type": "json", "fields": {
+			"host": {"type": "string", "required": true},
+			"pool": {"type": "json", "fields": {
+				"min": {"type": "int", "default": 1, "min": 0},
+				"max": {"type": "int", "default": 10}
+			}}
+		}},
+		"cache": {"type": "json", "fields": {"ttl": {"type": "int", "default": 60}}}
+	}
+}`
+
+func decodeMap(t *testing.T, src string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(src), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestApplyDefaults(t *testing.T) {
+	schema := mustSchema(t, nestedSchema)
+	config := decodeMap(t, `{"mode": "release", "db": {"host": "h", "pool": {"max": 4}}}`)
+	written := decodeMap(t, `{"mode": "release", "db": {"host": "h", "pool": {"max": 4}}}`)
+
+	got := ApplyDefaults(schema, config)
+	want := decodeMap(t, `{
+		"mode": "release",
+		"origins": ["https://a"],
+		"db": {"host": "h", "pool": {"min": 1, "max": 4}},
+		"cache": {"ttl": 60}
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyDefaults() = %v\nwant %v", got, want)
+	}
+	if !reflect.DeepEqual(config, written) {
+		t.Errorf("ApplyDefaults() modified its input: %v", config)
+	}
+
+	// Defaults are copies, not shared with the schema.
+	got["origins"].([]interface{})[0] = "changed"
+	if schema.Rules["origins"].Default.([]interface{})[0] != "https://a" {
+		t.Error("ApplyDefaults() returned a default that aliases the schema")
+	}
+}
+
+func TestValidateIsPure(t *testing.T) {
+	schema := mustSchema(t, nestedSchema)
+	config := decodeMap(t, `{"db": {"host": "h"}}`)
+	if err := Validate(schema, config); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if want := decodeMap(t, `{"db": {"host": "h"}}`); !reflect.DeepEqual(config, want) {
+		t.Errorf("Validate() modified config: %v", config)
+	}
+}
+
+func TestValidateNestedFields(t *testing.T) {
+	schema := mustSchema(t, nestedSchema)
+	err := Validate(schema, decodeMap(t, `{"db": {"pool": {"min": -1, "max": "x", "extra": true}}}`))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate() = %v, want a *ValidationError", err)
+	}
+	got := append([]string{}, verr.Errors...)
+	sort.Strings(got)
+	want := []string{
+		"field 'db.host' is required",
+		"field 'db.pool.max' expected type int, got string",
+		"field 'db.pool.min' must be >= 0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %q\nwant %q", got, want)
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	cfg := &Config{
+		Key:    "app",
+		Data:   Map{"mode": "release"},
+		Schema: Map{"rules": map[string]interface{}{"mode": map[string]interface{}{"type": "string", "default": "debug"}, "level": map[string]interface{}{"type": "string", "default": "info"}}},
+	}
+	got, err := WithDefaults(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Map{"mode": "release", "level": "info"}); !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("WithDefaults().Data = %v, want %v", got.Data, want)
+	}
+	if len(cfg.Data) != 1 {
+		t.Errorf("WithDefaults() modified the stored data: %v", cfg.Data)
+	}
+}
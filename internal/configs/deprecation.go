@@ -0,0 +1,223 @@
+package configs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SunsetLayout is the date format of Deprecation.Sunset.
+const SunsetLayout = "2006-01-02"
+
+// DeprecatedReadFlushInterval is how often tracked reads of deprecated fields
+// are written to the database.
+const DeprecatedReadFlushInterval = 10 * time.Second
+
+// Deprecation marks a field as on its way out. Setting it produces a warning
+// until the sunset date, and a validation error from that date on.
+type Deprecation struct {
+	ReplacedBy string `json:"replaced_by,omitempty"` // Key to use instead, at the same level
+	Sunset     string `json:"sunset,omitempty"`      // YYYY-MM-DD (UTC); no date means warn forever
+	Message    string `json:"message,omitempty"`
+}
+
+// Expired reports whether the sunset date has been reached at t.
+func (d *Deprecation) Expired(t time.Time) bool {
+	sunset, err := time.Parse(SunsetLayout, d.Sunset)
+	return err == nil && !t.UTC().Before(sunset)
+}
+
+// describe renders the deprecation of key for warnings and errors.
+func (d *Deprecation) describe(key string, expired bool) string {
+	msg := fmt.Sprintf("field '%s' is deprecated", key)
+	switch {
+	case expired:
+		msg = fmt.Sprintf("field '%s' was removed on %s", key, d.Sunset)
+	case d.Sunset != "":
+		msg += fmt.Sprintf(" and will be rejected from %s", d.Sunset)
+	}
+	if d.ReplacedBy != "" {
+		msg += fmt.Sprintf("; use '%s' instead", d.ReplacedBy)
+	}
+	if d.Message != "" {
+		msg += " (" + d.Message + ")"
+	}
+	return msg
+}
+
+// deprecationErrors reports unparsable sunset dates, required fields with a
+// sunset (which every config would fail from that date) and replacements that
+// are not in the schema.
+func deprecationErrors(prefix string, rules map[string]FieldRule) []string {
+	var errs []string
+	for _, name := range ruleKeys(rules) {
+		key, rule := prefix+name, rules[name]
+		if d := rule.Deprecated; d != nil {
+			if _, err := time.Parse(SunsetLayout, d.Sunset); d.Sunset != "" && err != nil {
+				errs = append(errs, fmt.Sprintf("field '%s' has an invalid sunset date %q (want YYYY-MM-DD)", key, d.Sunset))
+			}
+			if rule.Required && d.Sunset != "" {
+				errs = append(errs, fmt.Sprintf("field '%s' is required but has a sunset date; make it optional first", key))
+			}
+			if _, ok := rules[d.ReplacedBy]; d.ReplacedBy != "" && !ok {
+				errs = append(errs, fmt.Sprintf("field '%s' is replaced by '%s', which is not in the schema", key, d.ReplacedBy))
+			}
+		}
+		errs = append(errs, deprecationErrors(key+".", rule.Fields)...)
+	}
+	return errs
+}
+
+// DeprecatedFields returns the paths of deprecated fields set in config,
+// sorted.
+func DeprecatedFields(schema Schema, config map[string]interface{}) []string {
+	found := deprecatedIn("", schema.Rules, config)
+	out := make([]string, 0, len(found))
+	for path := range found {
+		out = append(out, path)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ReadWarnings describes the deprecated fields set in config, for readers.
+func ReadWarnings(schema Schema, config map[string]interface{}, now time.Time) []string {
+	found := deprecatedIn("", schema.Rules, config)
+	var out []string
+	for _, path := range DeprecatedFields(schema, config) {
+		out = append(out, found[path].describe(path, found[path].Expired(now)))
+	}
+	return out
+}
+
+func deprecatedIn(prefix string, rules map[string]FieldRule, config map[string]interface{}) map[string]*Deprecation {
+	out := map[string]*Deprecation{}
+	for name, rule := range rules {
+		val, ok := config[name]
+		if !ok {
+			continue
+		}
+		if rule.Deprecated != nil {
+			out[prefix+name] = rule.Deprecated
+		}
+		if nested, isObject := val.(map[string]interface{}); isObject {
+			for path, d := range deprecatedIn(prefix+name+".", rule.Fields, nested) {
+				out[path] = d
+			}
+		}
+	}
+	return out
+}
+
+// DeprecatedRead records that an API key read a config containing a
+// deprecated field.
+type DeprecatedRead struct {
+	EnvID       int       `json:"env_id"`
+	Key         string    `json:"key"`
+	Field       string    `json:"field"`
+	APIKeyID    string    `json:"api_key_id"` // Fingerprint of the API key
+	UserAgent   string    `json:"user_agent,omitempty"`
+	Reads       int64     `json:"reads"`
+	FirstReadAt time.Time `json:"first_read_at"`
+	LastReadAt  time.Time `json:"last_read_at"`
+}
+
+// RecordDeprecatedReads adds counted reads of deprecated fields of a project.
+// Each read's UserAgent and LastReadAt replace the stored ones.
+func (r *Repository) RecordDeprecatedReads(projectID int, reads []DeprecatedRead) error {
+	if r.db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range reads {
+		_, err := tx.Exec(`
+			INSERT INTO deprecated_field_reads (project_id, environment_id, key, field, api_key_id, user_agent, reads, first_read_at, last_read_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (project_id, environment_id, key, field, api_key_id) DO UPDATE
+				SET reads = deprecated_field_reads.reads + EXCLUDED.reads, user_agent = EXCLUDED.user_agent,
+					last_read_at = GREATEST(deprecated_field_reads.last_read_at, EXCLUDED.last_read_at)`,
+			projectID, d.EnvID, d.Key, d.Field, d.APIKeyID, d.UserAgent, d.Reads, d.FirstReadAt, d.LastReadAt)
+		if err != nil {
+			return fmt.Errorf("failed to record deprecated read: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// readBuffer counts reads of deprecated fields between flushes, so tracking
+// them never makes a read wait on the database.
+type readBuffer struct {
+	mu    sync.Mutex
+	reads map[readKey]*DeprecatedRead
+}
+
+type readKey struct {
+	projectID, envID     int
+	key, field, apiKeyID string
+}
+
+// add counts one read of each field, made at t.
+func (b *readBuffer) add(projectID, envID int, key string, fields []string, apiKeyID, userAgent string, t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.reads == nil {
+		b.reads = map[readKey]*DeprecatedRead{}
+	}
+	for _, field := range fields {
+		k := readKey{projectID, envID, key, field, apiKeyID}
+		d := b.reads[k]
+		if d == nil {
+			d = &DeprecatedRead{EnvID: envID, Key: key, Field: field, APIKeyID: apiKeyID, FirstReadAt: t}
+			b.reads[k] = d
+		}
+		d.Reads++
+		d.UserAgent, d.LastReadAt = userAgent, t
+	}
+}
+
+// drain returns the reads counted so far by project, and empties the buffer.
+func (b *readBuffer) drain() map[int][]DeprecatedRead {
+	b.mu.Lock()
+	reads := b.reads
+	b.reads = nil
+	b.mu.Unlock()
+
+	byProject := map[int][]DeprecatedRead{}
+	for k, d := range reads {
+		byProject[k.projectID] = append(byProject[k.projectID], *d)
+	}
+	return byProject
+}
+
+// ListDeprecatedReads returns the recorded reads of a project, most recent
+// first. envID 0 and an empty key match everything.
+func (r *Repository) ListDeprecatedReads(projectID, envID int, key string) ([]DeprecatedRead, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	rows, err := r.db.Query(`
+		SELECT environment_id, key, field, api_key_id, user_agent, reads, first_read_at, last_read_at
+		FROM deprecated_field_reads
+		WHERE project_id = $1 AND ($2 = 0 OR environment_id = $2) AND ($3 = '' OR key = $3)
+		ORDER BY last_read_at DESC`, projectID, envID, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []DeprecatedRead{}
+	for rows.Next() {
+		var d DeprecatedRead
+		if err := rows.Scan(&d.EnvID, &d.Key, &d.Field, &d.APIKeyID, &d.UserAgent, &d.Reads, &d.FirstReadAt, &d.LastReadAt); err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
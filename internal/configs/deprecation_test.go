@@ -0,0 +1,107 @@
+package configs
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCheckDeprecatedFields(t *testing.T) {
+	schema := mustSchema(t, `{"rules": {
+		"timeout":    {"type": "int", "deprecated": {"replaced_by": "timeout_ms", "sunset": "2999-01-01"}},
+		"timeout_ms": {"type": "int"},
+		"legacy":     {"type": "bool", "deprecated": {"sunset": "2000-01-01", "message": "no longer read"}},
+		"db": {"type": "json", "fields": {"url": {"type": "string", "deprecated": {"replaced_by": "dsn"}}, "dsn": {"type": "string"}}}
+	}}`)
+
+	warnings, err := Check(schema, decodeMap(t, `{"timeout": 5, "db": {"url": "x"}}`))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	want := []string{
+		"field 'db.url' is deprecated; use 'dsn' instead",
+		"field 'timeout' is deprecated and will be rejected from 2999-01-01; use 'timeout_ms' instead",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %q\nwant %q", warnings, want)
+	}
+
+	err = Validate(schema, decodeMap(t, `{"legacy": true}`))
+	verr, ok := err.(*ValidationError)
+	if !ok || !reflect.DeepEqual(verr.Errors, []string{"field 'legacy' was removed on 2000-01-01 (no longer read)"}) {
+		t.Errorf("Validate() = %v, want the field rejected after its sunset", err)
+	}
+}
+
+func TestDeprecationExpired(t *testing.T) {
+	d := &Deprecation{Sunset: "2026-03-01"}
+	if d.Expired(time.Date(2026, 2, 28, 23, 59, 0, 0, time.UTC)) {
+		t.Error("expired before the sunset date")
+	}
+	if !d.Expired(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("not expired on the sunset date")
+	}
+	if (&Deprecation{}).Expired(time.Now()) {
+		t.Error("a deprecation without a sunset expired")
+	}
+}
+
+func TestDeprecationErrors(t *testing.T) {
+	err := checkRules(mustSchema(t, `{"rules": {
+		"a": {"type": "int", "deprecated": {"sunset": "March 1st"}},
+		"b": {"type": "int", "deprecated": {"replaced_by": "c"}},
+		"d": {"type": "int", "required": true, "deprecated": {"sunset": "2030-01-01"}},
+		"e": {"type": "int", "required": true, "deprecated": {"message": "no sunset yet"}}
+	}}`))
+	want := []string{
+		`field 'a' has an invalid sunset date "March 1st" (want YYYY-MM-DD)`,
+		"field 'b' is replaced by 'c', which is not in the schema",
+		"field 'd' is required but has a sunset date; make it optional first",
+	}
+	if verr, ok := err.(*ValidationError); !ok || !reflect.DeepEqual(verr.Errors, want) {
+		t.Errorf("checkRules() = %v, want %q", err, want)
+	}
+}
+
+func TestReadWarnings(t *testing.T) {
+	schema := mustSchema(t, `{"rules": {
+		"a": {"type": "int", "deprecated": {"replaced_by": "b"}},
+		"b": {"type": "int"},
+		"c": {"type": "json", "fields": {"d": {"type": "int", "deprecated": {"sunset": "2000-01-01"}}}}
+	}}`)
+	config := decodeMap(t, `{"a": 1, "b": 2, "c": {"d": 3}}`)
+
+	if got := DeprecatedFields(schema, config); !reflect.DeepEqual(got, []string{"a", "c.d"}) {
+		t.Errorf("DeprecatedFields() = %q", got)
+	}
+	want := []string{"field 'a' is deprecated; use 'b' instead", "field 'c.d' was removed on 2000-01-01"}
+	if got := ReadWarnings(schema, config, time.Now()); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadWarnings() = %q, want %q", got, want)
+	}
+}
+
+func TestReadBuffer(t *testing.T) {
+	var b readBuffer
+	first, last := time.Unix(100, 0), time.Unix(200, 0)
+	b.add(1, 2, "app", []string{"a", "c.d"}, "key1", "sdk/1.0", first)
+	b.add(1, 2, "app", []string{"a"}, "key1", "sdk/1.1", last)
+	b.add(3, 4, "app", []string{"a"}, "key2", "curl", last)
+
+	byProject := b.drain()
+	if len(byProject) != 2 || len(byProject[3]) != 1 {
+		t.Fatalf("drain() = %+v, want reads of projects 1 and 3", byProject)
+	}
+	reads := byProject[1]
+	sort.Slice(reads, func(i, j int) bool { return reads[i].Field < reads[j].Field })
+	want := []DeprecatedRead{
+		{EnvID: 2, Key: "app", Field: "a", APIKeyID: "key1", UserAgent: "sdk/1.1", Reads: 2, FirstReadAt: first, LastReadAt: last},
+		{EnvID: 2, Key: "app", Field: "c.d", APIKeyID: "key1", UserAgent: "sdk/1.0", Reads: 1, FirstReadAt: first, LastReadAt: first},
+	}
+	if !reflect.DeepEqual(reads, want) {
+		t.Errorf("project 1 reads = %+v, want %+v", reads, want)
+	}
+	if again := b.drain(); len(again) != 0 {
+		t.Errorf("second drain() = %+v, want nothing", again)
+	}
+}
@@ -0,0 +1,72 @@
+package configs
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ChangeOp is the kind of a single difference between two config versions.
+type ChangeOp string
+
+const (
+	OpAdded   ChangeOp = "added"
+	OpRemoved ChangeOp = "removed"
+	OpChanged ChangeOp = "changed"
+)
+
+// Change describes one differing field. Nested fields use dotted paths.
+type Change struct {
+	Path string      `json:"path"`
+	Op   ChangeOp    `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Diff lists the changes needed to turn oldData into newData, sorted by path.
+// Nested objects are compared field by field; all other values as a whole.
+func Diff(oldData, newData map[string]interface{}) []Change {
+	changes := []Change{}
+	diffInto(&changes, "", oldData, newData)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func diffInto(changes *[]Change, prefix string, oldData, newData map[string]interface{}) {
+	for k, oldVal := range oldData {
+		path := joinPath(prefix, k)
+		newVal, exists := newData[k]
+		if !exists {
+			*changes = append(*changes, Change{Path: path, Op: OpRemoved, Old: oldVal})
+			continue
+		}
+		oldMap, oldIsMap := oldVal.(map[string]interface{})
+		newMap, newIsMap := newVal.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			diffInto(changes, path, oldMap, newMap)
+			continue
+		}
+		if !reflect.DeepEqual(normalizeNumber(oldVal), normalizeNumber(newVal)) {
+			*changes = append(*changes, Change{Path: path, Op: OpChanged, Old: oldVal, New: newVal})
+		}
+	}
+	for k, newVal := range newData {
+		if _, exists := oldData[k]; !exists {
+			*changes = append(*changes, Change{Path: joinPath(prefix, k), Op: OpAdded, New: newVal})
+		}
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// normalizeNumber makes ints and float64s of the same value compare equal.
+func normalizeNumber(v interface{}) interface{} {
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	return v
+}
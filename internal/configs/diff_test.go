@@ -0,0 +1,34 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldData := map[string]interface{}{
+		"app_name":    "svc",
+		"max_retries": float64(3),
+		"mode":        "debug",
+		"db":          map[string]interface{}{"pool": float64(5), "host": "a"},
+	}
+	newData := map[string]interface{}{
+		"app_name":    "svc",
+		"max_retries": 3, // int from code, same value
+		"timeout":     float64(30),
+		"db":          map[string]interface{}{"pool": float64(10), "host": "a"},
+	}
+
+	want := []Change{
+		{Path: "db.pool", Op: OpChanged, Old: float64(5), New: float64(10)},
+		{Path: "mode", Op: OpRemoved, Old: "debug"},
+		{Path: "timeout", Op: OpAdded, New: float64(30)},
+	}
+	if got := Diff(oldData, newData); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+
+	if got := Diff(nil, map[string]interface{}{"a": true}); len(got) != 1 || got[0].Op != OpAdded {
+		t.Errorf("Diff(nil, ...) = %+v, want one addition", got)
+	}
+}
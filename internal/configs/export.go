@@ -0,0 +1,177 @@
+package configs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Export formats supported by Export.
+const (
+	ExportConfigMap  = "configmap"  // Kubernetes ConfigMap, plus a Secret for secret fields
+	ExportDotenv     = "dotenv"     // KEY="value" lines
+	ExportProperties = "properties" // Java .properties
+)
+
+// ExportOptions controls how a config version is rendered by Export.
+type ExportOptions struct {
+	Format    string
+	Separator string // Joins nested keys; defaults to "." ("_" for dotenv)
+	Name      string // Kubernetes object name; defaults to the config key
+	Namespace string // Kubernetes namespace; omitted when empty
+}
+
+// Export renders a config version in a format deploy tooling can consume
+// without the SDK.
+func Export(cfg *Config, opts ExportOptions) (string, error) {
+	if opts.Separator == "" {
+		opts.Separator = "."
+		if opts.Format == ExportDotenv {
+			opts.Separator = "_"
+		}
+	}
+
+	switch opts.Format {
+	case ExportDotenv:
+		return RenderDotenv(cfg.Data, opts.Separator), nil
+	case ExportProperties:
+		return RenderProperties(cfg.Data, opts.Separator), nil
+	case ExportConfigMap:
+		return renderKubernetes(cfg, opts)
+	default:
+		return "", fmt.Errorf("unknown export format %q (want %s, %s or %s)", opts.Format, ExportConfigMap, ExportDotenv, ExportProperties)
+	}
+}
+
+type k8sObject struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// renderKubernetes emits a ConfigMap with the plain fields and, if the schema
+// marks any field as secret, at any depth, a Secret holding those fields.
+func renderKubernetes(cfg *Config, opts ExportOptions) (string, error) {
+	var schema Schema
+	if err := convert(cfg.Schema, &schema); err != nil {
+		return "", fmt.Errorf("invalid stored schema: %w", err)
+	}
+
+	plain, secret := splitSecrets(schema.Rules, cfg.Data)
+
+	name := opts.Name
+	if name == "" {
+		name = k8sName(cfg.Key)
+	}
+	meta := func(name string) k8sMetadata {
+		return k8sMetadata{
+			Name:      name,
+			Namespace: opts.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "configra"},
+			Annotations: map[string]string{
+				"configra.io/key":     cfg.Key,
+				"configra.io/version": strconv.Itoa(cfg.Version),
+			},
+		}
+	}
+
+	docs := []k8sObject{{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   meta(name),
+		Data:       k8sData(plain, opts.Separator, false),
+	}}
+	if len(secret) > 0 {
+		docs = append(docs, k8sObject{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   meta(name + "-secret"),
+			Type:       "Opaque",
+			Data:       k8sData(secret, opts.Separator, true),
+		})
+	}
+
+	var b strings.Builder
+	for i, doc := range docs {
+		if i > 0 {
+			b.WriteString("---\n")
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return "", err
+		}
+		b.Write(out)
+	}
+	return b.String(), nil
+}
+
+// splitSecrets separates the fields rules mark as secret from the others,
+// following nested rules into json objects: {"db": {"host", "password"}}
+// with a secret db.password gives {"db": {"host"}} and {"db": {"password"}}.
+func splitSecrets(rules map[string]FieldRule, data map[string]interface{}) (plain, secret Map) {
+	plain, secret = Map{}, Map{}
+	for k, v := range data {
+		rule := rules[k]
+		nested, isMap := v.(map[string]interface{})
+		switch {
+		case rule.Secret:
+			secret[k] = v
+		case isMap && len(rule.Fields) > 0:
+			p, s := splitSecrets(rule.Fields, nested)
+			if len(p) > 0 {
+				plain[k] = map[string]interface{}(p)
+			}
+			if len(s) > 0 {
+				secret[k] = map[string]interface{}(s)
+			}
+		default:
+			plain[k] = v
+		}
+	}
+	return plain, secret
+}
+
+func k8sData(data Map, sep string, encode bool) map[string]string {
+	out := map[string]string{}
+	for k, v := range Flatten(data, sep) {
+		s := ScalarString(v)
+		if encode {
+			s = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+		out[k8sKey(k)] = s
+	}
+	return out
+}
+
+// k8sKey replaces characters not allowed in ConfigMap keys ([-._a-zA-Z0-9]).
+func k8sKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// k8sName turns a config key into a DNS-1123 object name, e.g. "feature_flags" -> "feature-flags".
+func k8sName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(key))
+	return strings.Trim(name, "-.")
+}
@@ -0,0 +1,64 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	cfg := &Config{
+		Key:     "payment_service",
+		Version: 7,
+		Data: Map{
+			"mode":    "release",
+			"db":      map[string]interface{}{"pool": float64(10), "password": "hunter2"},
+			"api_key": "s3cret",
+		},
+		Schema: Map{"rules": map[string]interface{}{
+			"mode": map[string]interface{}{"type": "string"},
+			"db": map[string]interface{}{"type": "json", "fields": map[string]interface{}{
+				"pool":     map[string]interface{}{"type": "int"},
+				"password": map[string]interface{}{"type": "string", "secret": true},
+			}},
+			"api_key": map[string]interface{}{"type": "string", "secret": true},
+		}},
+	}
+
+	got, err := Export(cfg, ExportOptions{Format: ExportConfigMap, Namespace: "payments"})
+	if err != nil {
+		t.Fatalf("Export(configmap) error = %v", err)
+	}
+	for _, want := range []string{
+		"kind: ConfigMap",
+		"name: payment-service\n",
+		"namespace: payments",
+		"configra.io/version: \"7\"",
+		"db.pool: \"10\"",
+		"mode: release",
+		"---\n",
+		"kind: Secret",
+		"name: payment-service-secret",
+		"api_key: czNjcmV0",         // base64("s3cret")
+		"db.password: aHVudGVyMg==", // base64("hunter2")
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Export(configmap) missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "s3cret") || strings.Contains(got, "hunter2") {
+		t.Errorf("Export(configmap) leaked a secret value in plain text:\n%s", got)
+	}
+
+	got, err = Export(cfg, ExportOptions{Format: ExportProperties})
+	if err != nil {
+		t.Fatalf("Export(properties) error = %v", err)
+	}
+	want := "api_key=s3cret\ndb.password=hunter2\ndb.pool=10\nmode=release\n"
+	if got != want {
+		t.Errorf("Export(properties) =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Export(cfg, ExportOptions{Format: "xml"}); err == nil {
+		t.Errorf("Export(xml) succeeded, want error")
+	}
+}
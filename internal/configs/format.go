@@ -0,0 +1,148 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Flatten collapses nested objects into a single level, joining keys with sep.
+// Arrays and other non-object values are kept as leaves.
+func Flatten(data map[string]interface{}, sep string) map[string]interface{} {
+	out := make(map[string]interface{})
+	flattenInto(out, "", data, sep)
+	return out
+}
+
+func flattenInto(out map[string]interface{}, prefix string, data map[string]interface{}, sep string) {
+	for k, v := range data {
+		key := k
+		if prefix != "" {
+			key = prefix + sep + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(out, key, nested, sep)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// EnvName turns a flattened config key into an environment variable name,
+// e.g. "db.max_pool" -> "DB_MAX_POOL".
+func EnvName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// ScalarString renders a leaf value as it should appear in a flat file.
+// Strings are returned as-is; everything else is JSON encoded.
+func ScalarString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+// RenderDotenv renders data as a .env file with one KEY="value" line per leaf.
+// Nested keys are joined with sep before being upper-cased.
+func RenderDotenv(data map[string]interface{}, sep string) string {
+	flat := Flatten(data, sep)
+	var b strings.Builder
+	for _, k := range sortedKeys(flat) {
+		fmt.Fprintf(&b, "%s=%s\n", EnvName(k), dotenvQuote(ScalarString(flat[k])))
+	}
+	return b.String()
+}
+
+// RenderShell renders data as POSIX shell `export KEY='value'` lines,
+// suitable for `eval "$(configra fetch -format shell ...)"`.
+func RenderShell(data map[string]interface{}, sep string) string {
+	flat := Flatten(data, sep)
+	var b strings.Builder
+	for _, k := range sortedKeys(flat) {
+		fmt.Fprintf(&b, "export %s=%s\n", EnvName(k), shellQuote(ScalarString(flat[k])))
+	}
+	return b.String()
+}
+
+// RenderProperties renders data as a Java .properties file, joining nested
+// keys with sep (usually ".").
+func RenderProperties(data map[string]interface{}, sep string) string {
+	flat := Flatten(data, sep)
+	var b strings.Builder
+	for _, k := range sortedKeys(flat) {
+		fmt.Fprintf(&b, "%s=%s\n", propertiesEscape(k, true), propertiesEscape(ScalarString(flat[k]), false))
+	}
+	return b.String()
+}
+
+// propertiesEscape escapes s as java.util.Properties expects. Keys also need
+// separators and spaces escaped; values only a leading space.
+func propertiesEscape(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case isKey && (r == '=' || r == ':' || (i == 0 && (r == '#' || r == '!'))):
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				for _, c := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&b, `\u%04x`, c)
+				}
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func dotenvQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,27 @@
+package configs
+
+import "testing"
+
+func TestRenderFlatFormats(t *testing.T) {
+	data := map[string]interface{}{
+		"app_name": "it's \"mine\"",
+		"db":       map[string]interface{}{"max_pool": float64(10)},
+		"origins":  []interface{}{"https://a"},
+	}
+
+	wantDotenv := `APP_NAME="it's \"mine\""
+DB_MAX_POOL="10"
+ORIGINS="[\"https://a\"]"
+`
+	if got := RenderDotenv(data, "_"); got != wantDotenv {
+		t.Errorf("RenderDotenv() =\n%s\nwant\n%s", got, wantDotenv)
+	}
+
+	wantShell := `export APP_NAME='it'\''s "mine"'
+export DB_MAX_POOL='10'
+export ORIGINS='["https://a"]'
+`
+	if got := RenderShell(data, "_"); got != wantShell {
+		t.Errorf("RenderShell() =\n%s\nwant\n%s", got, wantShell)
+	}
+}
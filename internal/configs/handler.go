@@ -1,8 +1,10 @@
 ﻿package configs
 
 import (
-	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/clyvecute/configra/internal/middleware"
 	"github.com/clyvecute/configra/pkg/utils"
@@ -13,6 +15,16 @@ type ValidateRequest struct {
 	Config map[string]interface{} `json:"config"`
 }
 
+// decodeRequest decodes a request body into out. Bodies sent as YAML or TOML
+// (by Content-Type) are accepted alongside JSON.
+func decodeRequest(r *http.Request, out interface{}) error {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return DecodeInto(b, FormatFromContentType(r.Header.Get("Content-Type")), out)
+}
+
 type Handler struct {
 	service *Service
 }
@@ -23,38 +35,53 @@ func NewHandler(service *Service) *Handler {
 
 func (h *Handler) Validate(w http.ResponseWriter, r *http.Request) {
 	var req ValidateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
 
-	if err := Validate(req.Schema, req.Config); err != nil {
-		// If validation fails, return 400 with the error details
-		// Ideally we cast err to ValidationError to get structured data, but string is fine for now
+	warnings, err := Check(req.Schema, req.Config)
+	if err != nil {
+		// If validation fails, return 400 with the error details. Failed
+		// cross-field constraints also list the fields involved.
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && len(validationErr.Violations) > 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":      err.Error(),
+				"violations": validationErr.Violations,
+			})
+			return
+		}
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
+	if len(warnings) > 0 {
+		utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "valid", "warnings": warnings})
+		return
+	}
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "valid"})
 }
 
 type CreateRequest struct {
 	ProjectID int                    `json:"project_id"`
 	EnvID     int                    `json:"env_id"`
+	Env       string                 `json:"env,omitempty"` // Slug alternative to env_id
 	Key       string                 `json:"key"`
 	Data      map[string]interface{} `json:"data"`
 	Schema    map[string]interface{} `json:"schema"`
+	DryRun    bool                   `json:"dry_run,omitempty"` // Validate and plan without committing
 }
 
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	var req CreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
 
 	// Basic validation
-	if req.EnvID == 0 || req.Key == "" {
+	if (req.EnvID == 0 && req.Env == "") || req.Key == "" {
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
 		return
 	}
@@ -67,31 +94,446 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.EnvID == 0 {
+		if req.EnvID = h.resolveEnv(w, projectID, req.Env); req.EnvID == 0 {
+			return
+		}
+	}
+
+	if req.DryRun {
+		plan, err := h.service.PlanConfig(projectID, req.EnvID, req.Key, req.Data, req.Schema)
+		if err != nil {
+			writeWriteError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, plan)
+		return
+	}
+
 	// Call Service
 	cfg, err := h.service.CreateConfig(projectID, req.EnvID, req.Key, req.Data, req.Schema, 1)
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeWriteError(w, err)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusCreated, cfg)
 }
 
+// Get returns the latest version of a config key. The environment is given
+// either as env_id or as env (an ID or a slug such as "prod"). With
+// defaults=true, schema defaults are filled in for fields that were not written.
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	env := q.Get("env_id")
+	if env == "" {
+		env = q.Get("env")
+	}
+	key := q.Get("key")
+	if env == "" || key == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	envID := h.resolveEnv(w, projectID, env)
+	if envID == 0 {
+		return
+	}
+
+	cfg, err := h.service.GetConfig(projectID, envID, key)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if cfg == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "config not found", "code": CodeConfigNotFound})
+		return
+	}
+	if cfg, err = resolveDefaults(cfg, q.Get("defaults")); err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	cfg.Warnings = h.trackDeprecatedReads(r, projectID, cfg)
+
+	utils.WriteJSON(w, http.StatusOK, cfg)
+}
+
+// Export renders a config version as a Kubernetes ConfigMap/Secret manifest,
+// a .env file or a .properties file. Query parameters: env (or env_id), key,
+// format, and optionally version, separator, name, namespace and defaults.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	env := q.Get("env_id")
+	if env == "" {
+		env = q.Get("env")
+	}
+	key := q.Get("key")
+	if env == "" || key == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+	version, err := strconv.Atoi(q.Get("version"))
+	if q.Get("version") != "" && (err != nil || version <= 0) {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid version"})
+		return
+	}
+
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	envID := h.resolveEnv(w, projectID, env)
+	if envID == 0 {
+		return
+	}
+
+	var cfg *Config
+	if version > 0 {
+		cfg, err = h.service.GetConfigVersion(projectID, envID, key, version)
+	} else {
+		cfg, err = h.service.GetConfig(projectID, envID, key)
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if cfg == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "config not found", "code": CodeConfigNotFound})
+		return
+	}
+	if cfg, err = resolveDefaults(cfg, q.Get("defaults")); err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	h.trackDeprecatedReads(r, projectID, cfg)
+
+	format := q.Get("format")
+	if format == "" {
+		format = ExportConfigMap
+	}
+	out, err := Export(cfg, ExportOptions{
+		Format:    format,
+		Separator: q.Get("separator"),
+		Name:      q.Get("name"),
+		Namespace: q.Get("namespace"),
+	})
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if format == ExportConfigMap {
+		contentType = "application/yaml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(out))
+}
+
+// resolveDefaults fills in schema defaults when the defaults query parameter
+// is true; otherwise cfg is returned as stored.
+func resolveDefaults(cfg *Config, param string) (*Config, error) {
+	if on, _ := strconv.ParseBool(param); !on {
+		return cfg, nil
+	}
+	return WithDefaults(cfg)
+}
+
+// trackDeprecatedReads records the deprecated fields served to the calling
+// API key and returns warnings about them.
+func (h *Handler) trackDeprecatedReads(r *http.Request, projectID int, cfg *Config) []string {
+	apiKeyID, _ := r.Context().Value(middleware.APIKeyIDKey).(string)
+	return h.service.TrackDeprecatedReads(projectID, cfg, apiKeyID, r.UserAgent())
+}
+
+// DeprecatedReads reports which API keys still read configs containing
+// deprecated fields. Query parameters env (or env_id) and key narrow it down.
+func (h *Handler) DeprecatedReads(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	q := r.URL.Query()
+	env := q.Get("env_id")
+	if env == "" {
+		env = q.Get("env")
+	}
+	envID := 0
+	if env != "" {
+		if envID = h.resolveEnv(w, projectID, env); envID == 0 {
+			return
+		}
+	}
+
+	reads, err := h.service.ListDeprecatedReads(projectID, envID, q.Get("key"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reads)
+}
+
+// writeWriteError reports a failed config or schema write. Incompatible
+// schema changes are a 409 listing each violation, invalid data or schemas a
+// 400 (listing failed cross-field constraints like Validate), a schema sent
+// for a bound key is a 403 and a missing schema a 400; anything else is a 500.
+func writeWriteError(w http.ResponseWriter, err error) {
+	var compatErr *CompatibilityError
+	if errors.As(err, &compatErr) {
+		utils.WriteJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":      err.Error(),
+			"mode":       compatErr.Mode,
+			"violations": compatErr.Violations,
+		})
+		return
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		body := map[string]interface{}{"error": err.Error()}
+		if len(validationErr.Violations) > 0 {
+			body["violations"] = validationErr.Violations
+		}
+		utils.WriteJSON(w, http.StatusBadRequest, body)
+		return
+	}
+	var bindingErr *SchemaBindingError
+	switch {
+	case errors.As(err, &bindingErr):
+		utils.WriteJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrSchemaRequired):
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+type RegisterSchemaRequest struct {
+	Name          string `json:"name"`
+	Schema        Map    `json:"schema"`
+	Compatibility string `json:"compatibility,omitempty"` // Replaces the schema's mode when set
+}
+
+// RegisterSchema stores a new version of a named schema. It is mounted
+// behind the admin key: changing a schema is a privileged operation.
+func (h *Handler) RegisterSchema(w http.ResponseWriter, r *http.Request) {
+	var req RegisterSchemaRequest
+	if err := decodeRequest(r, &req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Name == "" || len(req.Schema) == 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+	if !ValidSchemaName(req.Name) {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid schema name"})
+		return
+	}
+	if _, err := ParseCompatibilityMode(req.Compatibility); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	registered, err := h.service.RegisterSchema(projectID, req.Name, req.Schema, req.Compatibility)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeWriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, registered)
+}
+
+// ListSchemas returns the latest version of every schema in the project.
+func (h *Handler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	list, err := h.service.ListSchemas(projectID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetSchema returns a registered schema: the latest version, or the one
+// given by the version query parameter.
+func (h *Handler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if r.URL.Query().Get("version") != "" && (err != nil || version <= 0) {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid version"})
+		return
+	}
+
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	registered, err := h.service.GetSchema(projectID, r.PathValue("name"), version)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if registered == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": ErrSchemaNotFound.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, registered)
+}
+
+type BindSchemaRequest struct {
+	EnvID  int    `json:"env_id"`
+	Env    string `json:"env,omitempty"` // Slug alternative to env_id
+	Key    string `json:"key"`
+	Schema string `json:"schema"` // Registered schema name; empty unbinds the key
+}
+
+// BindSchema binds a config key to a registered schema. Like registering a
+// schema, it requires the admin key.
+func (h *Handler) BindSchema(w http.ResponseWriter, r *http.Request) {
+	var req BindSchemaRequest
+	if err := decodeRequest(r, &req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if (req.EnvID == 0 && req.Env == "") || req.Key == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	if req.EnvID == 0 {
+		if req.EnvID = h.resolveEnv(w, projectID, req.Env); req.EnvID == 0 {
+			return
+		}
+	}
+
+	if err := h.service.BindSchema(projectID, req.EnvID, req.Key, req.Schema); err != nil {
+		if errors.Is(err, ErrSchemaNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"key": req.Key, "schema": req.Schema})
+}
+
+type CompatibilityRequest struct {
+	EnvID int    `json:"env_id"`
+	Env   string `json:"env,omitempty"` // Slug alternative to env_id
+	Key   string `json:"key"`
+	Mode  string `json:"mode"` // backward, forward, full or none
+}
+
+// SetCompatibility sets the schema compatibility mode enforced on later
+// writes to a key.
+func (h *Handler) SetCompatibility(w http.ResponseWriter, r *http.Request) {
+	var req CompatibilityRequest
+	if err := decodeRequest(r, &req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if (req.EnvID == 0 && req.Env == "") || req.Key == "" || req.Mode == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+	mode, err := ParseCompatibilityMode(req.Mode)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	if req.EnvID == 0 {
+		if req.EnvID = h.resolveEnv(w, projectID, req.Env); req.EnvID == 0 {
+			return
+		}
+	}
+
+	if err := h.service.SetCompatibility(projectID, req.EnvID, req.Key, mode); err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"key": req.Key, "compatibility": string(mode)})
+}
+
+// resolveEnv maps an environment ID or slug to its ID. On failure it writes
+// the error response and returns 0.
+func (h *Handler) resolveEnv(w http.ResponseWriter, projectID int, env string) int {
+	envID, err := h.service.ResolveEnvironment(projectID, env)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return 0
+	}
+	if envID == 0 {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "environment not found", "code": CodeEnvironmentNotFound})
+		return 0
+	}
+	return envID
+}
+
+// Codes of 404 responses, which clients can tell apart by the "code" field
+// without matching the error message.
+const (
+	CodeConfigNotFound      = "config_not_found"
+	CodeEnvironmentNotFound = "environment_not_found"
+)
+
 type RollbackRequest struct {
 	ProjectID     int    `json:"project_id"`
 	EnvID         int    `json:"env_id"`
+	Env           string `json:"env,omitempty"` // Slug alternative to env_id
 	Key           string `json:"key"`
 	TargetVersion int    `json:"target_version"`
+	DryRun        bool   `json:"dry_run,omitempty"` // Plan without committing
 }
 
 func (h *Handler) Rollback(w http.ResponseWriter, r *http.Request) {
 	var req RollbackRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
 
-	if req.EnvID == 0 || req.Key == "" || req.TargetVersion == 0 {
+	if (req.EnvID == 0 && req.Env == "") || req.Key == "" || req.TargetVersion == 0 {
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
 		return
 	}
@@ -103,9 +545,25 @@ func (h *Handler) Rollback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.EnvID == 0 {
+		if req.EnvID = h.resolveEnv(w, projectID, req.Env); req.EnvID == 0 {
+			return
+		}
+	}
+
+	if req.DryRun {
+		plan, err := h.service.PlanRollback(projectID, req.EnvID, req.Key, req.TargetVersion)
+		if err != nil {
+			writeWriteError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, plan)
+		return
+	}
+
 	cfg, err := h.service.RollbackConfig(projectID, req.EnvID, req.Key, req.TargetVersion, 1) // default admin ID
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeWriteError(w, err)
 		return
 	}
 
@@ -118,7 +576,7 @@ type FetchRequest struct {
 
 func (h *Handler) FetchSource(w http.ResponseWriter, r *http.Request) {
 	var req FetchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
@@ -0,0 +1,142 @@
+package configs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// InferOptions tunes InferSchema.
+type InferOptions struct {
+	// MaxEnum is the largest number of distinct string values that is still
+	// turned into an enum. A string field only becomes an enum if at least one
+	// value repeats across samples; 0 disables enum inference.
+	MaxEnum int
+}
+
+// fieldStats accumulates what was seen for one key across samples.
+type fieldStats struct {
+	seen     int
+	nulls    int // samples where the key is null
+	types    map[DataType]bool
+	nonInt   bool
+	strings  map[string]bool
+	ordering []string // distinct strings in first-seen order
+}
+
+// InferSchema derives a Schema from one or more sample configs. Keys present
+// in every sample are required; numbers are int unless any sample has a
+// fraction; strings with few, repeating values across samples become enums.
+// Keys whose samples disagree on type are reported as warnings and typed by
+// the first value seen. A null value counts as missing, so keys that are null
+// in some sample are optional and reported as warnings; keys that are null in
+// every sample become optional json fields.
+func InferSchema(samples []map[string]interface{}, opts InferOptions) (Schema, []string) {
+	stats := map[string]*fieldStats{}
+	var order []string
+	firstType := map[string]DataType{}
+
+	for _, sample := range samples {
+		for key, val := range sample {
+			st, ok := stats[key]
+			if !ok {
+				st = &fieldStats{types: map[DataType]bool{}, strings: map[string]bool{}}
+				stats[key] = st
+				order = append(order, key)
+			}
+			if val == nil {
+				st.nulls++
+				continue
+			}
+			st.seen++
+
+			t := inferType(val)
+			if _, ok := firstType[key]; !ok {
+				firstType[key] = t
+			}
+			st.types[t] = true
+			if t == TypeFloat {
+				st.nonInt = true
+			}
+			if s, ok := val.(string); ok && !st.strings[s] {
+				st.strings[s] = true
+				st.ordering = append(st.ordering, s)
+			}
+		}
+	}
+
+	sort.Strings(order)
+	schema := Schema{Version: 1, Rules: map[string]FieldRule{}}
+	var warnings []string
+
+	for _, key := range order {
+		st := stats[key]
+		rule := FieldRule{Required: st.seen == len(samples)}
+
+		if st.nulls > 0 && st.seen == 0 {
+			rule.Type = TypeJSON
+			schema.Rules[key] = rule
+			warnings = append(warnings, fmt.Sprintf("field '%s' is null in every sample; using an optional %s field", key, rule.Type))
+			continue
+		}
+		if st.nulls > 0 {
+			warnings = append(warnings, fmt.Sprintf("field '%s' is null in %d of %d samples; making it optional", key, st.nulls, len(samples)))
+		}
+
+		// int and float mix into float; anything else conflicting is a warning.
+		numeric := len(st.types) > 0
+		for t := range st.types {
+			if t != TypeInt && t != TypeFloat {
+				numeric = false
+			}
+		}
+		switch {
+		case numeric && st.nonInt:
+			rule.Type = TypeFloat
+		case numeric:
+			rule.Type = TypeInt
+		case len(st.types) > 1:
+			rule.Type = firstType[key]
+			warnings = append(warnings, fmt.Sprintf("field '%s' has mixed types %s across samples; using %s", key, typeList(st.types), rule.Type))
+		default:
+			rule.Type = firstType[key]
+		}
+
+		if rule.Type == TypeString && len(st.types) == 1 && len(st.strings) <= opts.MaxEnum && len(st.strings) < st.seen {
+			rule.Type = TypeEnum
+			for _, s := range st.ordering {
+				rule.Allowed = append(rule.Allowed, s)
+			}
+		}
+
+		schema.Rules[key] = rule
+	}
+
+	return schema, warnings
+}
+
+func inferType(val interface{}) DataType {
+	switch v := val.(type) {
+	case bool:
+		return TypeBool
+	case string:
+		return TypeString
+	case float64:
+		if v == float64(int64(v)) {
+			return TypeInt
+		}
+		return TypeFloat
+	case int, int64:
+		return TypeInt
+	default:
+		return TypeJSON
+	}
+}
+
+func typeList(types map[DataType]bool) []string {
+	var out []string
+	for t := range types {
+		out = append(out, string(t))
+	}
+	sort.Strings(out)
+	return out
+}
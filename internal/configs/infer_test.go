@@ -0,0 +1,72 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	samples := []map[string]interface{}{
+		{"app_name": "a", "max_retries": float64(3), "ratio": float64(1), "mode": "debug", "db": map[string]interface{}{}},
+		{"app_name": "b", "max_retries": float64(2), "ratio": 0.5, "mode": "release", "verbose": true},
+		{"app_name": "c", "max_retries": float64(0), "ratio": float64(2), "mode": "debug", "port": "8080"},
+		{"app_name": "d", "max_retries": float64(1), "ratio": float64(1), "mode": "debug", "port": float64(8080)},
+	}
+
+	schema, warnings := InferSchema(samples, InferOptions{MaxEnum: 5})
+
+	want := map[string]FieldRule{
+		"app_name":    {Type: TypeString, Required: true},
+		"max_retries": {Type: TypeInt, Required: true},
+		"ratio":       {Type: TypeFloat, Required: true},
+		"mode":        {Type: TypeEnum, Required: true, Allowed: []interface{}{"debug", "release"}},
+		"db":          {Type: TypeJSON},
+		"verbose":     {Type: TypeBool},
+		"port":        {Type: TypeString},
+	}
+	if !reflect.DeepEqual(schema.Rules, want) {
+		t.Errorf("InferSchema() rules = %+v, want %+v", schema.Rules, want)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("InferSchema() warnings = %v, want one for 'port'", warnings)
+	}
+
+	// Every sample must validate against the inferred schema, except the one
+	// whose 'port' disagrees with the chosen type.
+	for i, sample := range samples[:3] {
+		if err := Validate(schema, sample); err != nil {
+			t.Errorf("sample %d does not validate against inferred schema: %v", i, err)
+		}
+	}
+}
+
+func TestInferSchemaNulls(t *testing.T) {
+	samples := []map[string]interface{}{
+		{"name": "a", "proxy": nil, "region": nil},
+		{"name": "b", "proxy": nil, "region": "eu"},
+	}
+
+	schema, warnings := InferSchema(samples, InferOptions{})
+
+	want := map[string]FieldRule{
+		"name":   {Type: TypeString, Required: true},
+		"proxy":  {Type: TypeJSON},
+		"region": {Type: TypeString},
+	}
+	if !reflect.DeepEqual(schema.Rules, want) {
+		t.Errorf("InferSchema() rules = %+v, want %+v", schema.Rules, want)
+	}
+	wantWarnings := []string{
+		"field 'proxy' is null in every sample; using an optional json field",
+		"field 'region' is null in 1 of 2 samples; making it optional",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("InferSchema() warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	for i, sample := range samples {
+		if err := Validate(schema, sample); err != nil {
+			t.Errorf("sample %d does not validate against inferred schema: %v", i, err)
+		}
+	}
+}
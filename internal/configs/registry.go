@@ -0,0 +1,201 @@
+package configs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// RegisteredSchema is one version of a named schema in a project's registry.
+type RegisteredSchema struct {
+	Name          string    `json:"name"`
+	Version       int       `json:"version"`
+	Compatibility string    `json:"compatibility"` // Mode checked when the next version is registered
+	Definition    Map       `json:"schema"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ErrSchemaNotFound is returned when a named schema is not registered.
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// ErrSchemaRequired is returned for writes to an unbound key without a schema.
+var ErrSchemaRequired = errors.New("schema is required for keys not bound to a registered schema")
+
+// SchemaBindingError rejects a write that sends its own schema for a key bound
+// to a registered schema. Only admins may change a bound key's schema, by
+// registering a new version.
+type SchemaBindingError struct {
+	Key     string
+	Schema  string
+	Version int
+}
+
+func (e *SchemaBindingError) Error() string {
+	return fmt.Sprintf("key '%s' is bound to schema '%s' (version %d); the schema sent with the write differs", e.Key, e.Schema, e.Version)
+}
+
+var schemaNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidSchemaName reports whether name can be used for a registered schema.
+func ValidSchemaName(name string) bool {
+	return len(name) <= 255 && schemaNamePattern.MatchString(name)
+}
+
+// CreateSchemaVersion appends a version to a named schema, registering the
+// name on first use. A non-empty mode replaces the schema's compatibility mode.
+// A non-nil check is given the previous version, nil on first use, with the
+// mode already replaced; it runs while the schema is locked and can veto the
+// new version.
+func (r *Repository) CreateSchemaVersion(projectID int, name string, definition Map, mode CompatibilityMode, check func(previous *RegisteredSchema) error) (*RegisteredSchema, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s := &RegisteredSchema{Name: name, Definition: definition}
+	var schemaID int
+	err = tx.QueryRow(`
+		INSERT INTO schemas (project_id, name, compatibility)
+		VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'backward'))
+		ON CONFLICT (project_id, name) DO UPDATE
+			SET compatibility = COALESCE(NULLIF($3, ''), schemas.compatibility)
+		RETURNING id, compatibility`, projectID, name, string(mode)).Scan(&schemaID, &s.Compatibility)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert schema: %v", err)
+	}
+
+	previous := &RegisteredSchema{Name: name, Compatibility: s.Compatibility}
+	var previousJSON []byte
+	err = tx.QueryRow(`
+		SELECT version, definition, created_at FROM schema_versions
+		WHERE schema_id = $1
+		ORDER BY version DESC
+		LIMIT 1`, schemaID).Scan(&previous.Version, &previousJSON, &previous.CreatedAt)
+	if err == sql.ErrNoRows {
+		previous = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get latest schema version: %v", err)
+	} else if err := json.Unmarshal(previousJSON, &previous.Definition); err != nil {
+		return nil, fmt.Errorf("corrupt definition of schema: %v", err)
+	}
+	if check != nil {
+		if err := check(previous); err != nil {
+			return nil, err
+		}
+	}
+	s.Version = 1
+	if previous != nil {
+		s.Version = previous.Version + 1
+	}
+
+	definitionJSON, _ := json.Marshal(definition)
+	err = tx.QueryRow(`
+		INSERT INTO schema_versions (schema_id, version, definition)
+		VALUES ($1, $2, $3)
+		RETURNING created_at`, schemaID, s.Version, definitionJSON).Scan(&s.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert schema version: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSchema returns a version of a named schema, the latest one if version is
+// 0, or nil if it does not exist.
+func (r *Repository) GetSchema(projectID int, name string, version int) (*RegisteredSchema, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	query := `
+		SELECT s.name, s.compatibility, v.version, v.definition, v.created_at
+		FROM schemas s
+		JOIN schema_versions v ON s.id = v.schema_id
+		WHERE s.project_id = $1 AND s.name = $2 AND ($3 = 0 OR v.version = $3)
+		ORDER BY v.version DESC
+		LIMIT 1`
+
+	var s RegisteredSchema
+	var definitionBytes []byte
+	err := r.db.QueryRow(query, projectID, name, version).Scan(&s.Name, &s.Compatibility, &s.Version, &definitionBytes, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(definitionBytes, &s.Definition)
+	return &s, nil
+}
+
+// ListSchemas returns the latest version of every schema in a project.
+func (r *Repository) ListSchemas(projectID int) ([]RegisteredSchema, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	rows, err := r.db.Query(`
+		SELECT DISTINCT ON (s.name) s.name, s.compatibility, v.version, v.definition, v.created_at
+		FROM schemas s
+		JOIN schema_versions v ON s.id = v.schema_id
+		WHERE s.project_id = $1
+		ORDER BY s.name, v.version DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []RegisteredSchema{}
+	for rows.Next() {
+		var s RegisteredSchema
+		var definitionBytes []byte
+		if err := rows.Scan(&s.Name, &s.Compatibility, &s.Version, &definitionBytes, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(definitionBytes, &s.Definition)
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// SchemaBinding returns the name of the schema a key is bound to, or "".
+func (r *Repository) SchemaBinding(projectID, envID int, key string) (string, error) {
+	if r.db == nil {
+		return "", fmt.Errorf("database connection unavailable")
+	}
+	var name string
+	err := r.db.QueryRow(`
+		SELECT COALESCE(schema_name, '') FROM configs
+		WHERE project_id = $1 AND environment_id = $2 AND key = $3`,
+		projectID, envID, key).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+// BindSchema binds a key to a registered schema, creating the key if it has
+// no versions yet. An empty name unbinds it.
+func (r *Repository) BindSchema(projectID, envID int, key, name string) error {
+	if r.db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO configs (project_id, environment_id, key, schema_name)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (project_id, environment_id, key) DO UPDATE
+			SET schema_name = EXCLUDED.schema_name, updated_at = NOW()`,
+		projectID, envID, key, name)
+	if err != nil {
+		return fmt.Errorf("failed to bind schema: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidSchemaName(t *testing.T) {
+	for _, name := range []string{"feature_flags", "billing.v2", "a-b"} {
+		if !ValidSchemaName(name) {
+			t.Errorf("ValidSchemaName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"", "-flags", "a/b", "flags?v=1", "with space"} {
+		if ValidSchemaName(name) {
+			t.Errorf("ValidSchemaName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	if err := checkRules(mustSchema(t, `{"rules": {"a": {"type": "int"}, "b": {"type": "json"}}}`)); err != nil {
+		t.Errorf("checkRules() error = %v", err)
+	}
+	if err := checkRules(mustSchema(t, `{"rules": {}}`)); err == nil {
+		t.Error("expected an error for a schema without rules")
+	}
+	err := checkRules(mustSchema(t, `{"rules": {"a": {"type": "integer"}, "b": {}}}`))
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 2 {
+		t.Fatalf("checkRules() = %v, want two errors", err)
+	}
+	if verr.Errors[0] != `field 'a' has unknown type "integer"` {
+		t.Errorf("unexpected error %q", verr.Errors[0])
+	}
+	err = checkRules(mustSchema(t, `{"rules": {"a": {"type": "string", "fields": {"x": {"type": "int"}}}, "b": {"type": "json", "fields": {"y": {"type": "map"}}}}}`))
+	want := []string{`field 'a' has nested fields but is not of type json`, `field 'b.y' has unknown type "map"`}
+	if verr, ok := err.(*ValidationError); !ok || !reflect.DeepEqual(verr.Errors, want) {
+		t.Errorf("checkRules() = %v, want %q", err, want)
+	}
+}
@@ -9,15 +9,18 @@ import (
 )
 
 type Config struct {
-	ID        int       `json:"id"`
-	ProjectID int       `json:"project_id"`
-	EnvID     int       `json:"env_id"`
-	Key       string    `json:"key"`
-	Version   int       `json:"version"` // Current version
-	Data      Map       `json:"data"`    // Hydrated from version
-	Schema    Map       `json:"schema"`  // Hydrated from version
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int       `json:"id"`
+	ProjectID     int       `json:"project_id"`
+	EnvID         int       `json:"env_id"`
+	Key           string    `json:"key"`
+	Version       int       `json:"version"`                 // Current version
+	Data          Map       `json:"data"`                    // Hydrated from version
+	Schema        Map       `json:"schema"`                  // Hydrated from version
+	Compatibility string    `json:"compatibility,omitempty"` // Schema compatibility mode of the key
+	SchemaName    string    `json:"schema_name,omitempty"`   // Registered schema the key is bound to
+	Warnings      []string  `json:"warnings,omitempty"`      // Non-fatal findings of a write, or deprecated fields on a read
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 type Map map[string]interface{}
@@ -44,9 +47,19 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// WriteCheck vets a write against the current version of its key, nil for a
+// key without versions. It runs inside the write's transaction while the key
+// is locked, so no other write can slip in between the check and the write.
+type WriteCheck func(current *Config) error
+
+// RollbackCheck vets a rollback from current to target, both of which it may
+// read but only target's Data and Schema it may replace: they are what the
+// rollback stores. Like a WriteCheck, it runs while the key is locked.
+type RollbackCheck func(current, target *Config) error
+
 // CreateOrUpdate handles the logic of creating a config key if it doesn't exist,
-// and then appending a new version to it.
-func (r *Repository) CreateOrUpdate(projectID, envID int, key string, data, schema Map, userID int) (*Config, error) {
+// and then appending a new version to it. A non-nil check can veto the write.
+func (r *Repository) CreateOrUpdate(projectID, envID int, key string, data, schema Map, userID int, check WriteCheck) (*Config, error) {
 	if r.db == nil {
 		return nil, fmt.Errorf("database connection unavailable")
 	}
@@ -56,7 +69,7 @@ func (r *Repository) CreateOrUpdate(projectID, envID int, key string, data, sche
 	}
 	defer tx.Rollback()
 
-	// 1. Get or Create Config Parent
+	// 1. Get or Create Config Parent, locking it until the commit
 	var configID int
 	err = tx.QueryRow(`
 		INSERT INTO configs (project_id, environment_id, key)
@@ -69,14 +82,21 @@ func (r *Repository) CreateOrUpdate(projectID, envID int, key string, data, sche
 		return nil, fmt.Errorf("failed to upsert config parent: %v", err)
 	}
 
-	// 2. Get latest version number
-	var currentVersion int
-	err = tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM config_versions WHERE config_id = $1`, configID).Scan(&currentVersion)
+	// 2. Get and check the latest version
+	current, err := latestLocked(tx, configID, projectID, envID, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get max version: %v", err)
+		return nil, err
+	}
+	if check != nil {
+		if err := check(current); err != nil {
+			return nil, err
+		}
 	}
 
-	newVersion := currentVersion + 1
+	newVersion := 1
+	if current != nil {
+		newVersion = current.Version + 1
+	}
 
 	// 3. Insert new version
 	schemaJSON, _ := json.Marshal(schema)
@@ -106,13 +126,68 @@ func (r *Repository) CreateOrUpdate(projectID, envID int, key string, data, sche
 	}, nil
 }
 
+// latestLocked returns the latest version of the config row configID, which
+// tx has locked, or nil if it has no versions yet.
+func latestLocked(tx *sql.Tx, configID, projectID, envID int, key string) (*Config, error) {
+	c := Config{ID: configID, ProjectID: projectID, EnvID: envID, Key: key}
+	var dataBytes, schemaBytes []byte
+	err := tx.QueryRow(`
+		SELECT c.updated_at, c.compatibility, COALESCE(c.schema_name, ''), v.version, v.data, v.schema
+		FROM configs c
+		JOIN config_versions v ON c.id = v.config_id
+		WHERE c.id = $1
+		ORDER BY v.version DESC
+		LIMIT 1`, configID).Scan(&c.UpdatedAt, &c.Compatibility, &c.SchemaName, &c.Version, &dataBytes, &schemaBytes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest version: %v", err)
+	}
+	json.Unmarshal(dataBytes, &c.Data)
+	json.Unmarshal(schemaBytes, &c.Schema)
+	return &c, nil
+}
+
+// SetCompatibility sets the schema compatibility mode of a config key,
+// creating the key if it has no versions yet.
+func (r *Repository) SetCompatibility(projectID, envID int, key string, mode CompatibilityMode) error {
+	if r.db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO configs (project_id, environment_id, key, compatibility)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, environment_id, key) DO UPDATE
+			SET compatibility = EXCLUDED.compatibility, updated_at = NOW()`,
+		projectID, envID, key, string(mode))
+	if err != nil {
+		return fmt.Errorf("failed to set compatibility: %v", err)
+	}
+	return nil
+}
+
+// ResolveEnvironment maps an environment slug (e.g. "prod") to its ID within
+// a project. It returns 0 if the project has no such environment.
+func (r *Repository) ResolveEnvironment(projectID int, slug string) (int, error) {
+	if r.db == nil {
+		return 0, fmt.Errorf("database connection unavailable")
+	}
+	var envID int
+	err := r.db.QueryRow(`SELECT id FROM environments WHERE project_id = $1 AND slug = $2`, projectID, slug).Scan(&envID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return envID, err
+}
+
 func (r *Repository) GetLatest(projectID, envID int, key string) (*Config, error) {
 	if r.db == nil {
 		return nil, fmt.Errorf("database connection unavailable")
 	}
 	// Join configs and config_versions to get the latest data
 	query := `
-		SELECT c.id, c.updated_at, v.version, v.data, v.schema
+		SELECT c.id, c.updated_at, c.compatibility, COALESCE(c.schema_name, ''), v.version, v.data, v.schema
 		FROM configs c
 		JOIN config_versions v ON c.id = v.config_id
 		WHERE c.project_id = $1 AND c.environment_id = $2 AND c.key = $3
@@ -128,7 +203,7 @@ func (r *Repository) GetLatest(projectID, envID int, key string) (*Config, error
 	
 	var dataBytes, schemaBytes []byte
 
-	if err := row.Scan(&c.ID, &c.UpdatedAt, &c.Version, &dataBytes, &schemaBytes); err != nil {
+	if err := row.Scan(&c.ID, &c.UpdatedAt, &c.Compatibility, &c.SchemaName, &c.Version, &dataBytes, &schemaBytes); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found
 		}
@@ -140,9 +215,36 @@ func (r *Repository) GetLatest(projectID, envID int, key string) (*Config, error
 
 	return &c, nil
 }
+// GetVersion returns a specific version of a config, or nil if it does not exist.
+func (r *Repository) GetVersion(projectID, envID int, key string, version int) (*Config, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	query := `
+		SELECT c.id, v.created_at, c.compatibility, COALESCE(c.schema_name, ''), v.version, v.data, v.schema
+		FROM configs c
+		JOIN config_versions v ON c.id = v.config_id
+		WHERE c.project_id = $1 AND c.environment_id = $2 AND c.key = $3 AND v.version = $4`
+
+	c := Config{ProjectID: projectID, EnvID: envID, Key: key}
+	var dataBytes, schemaBytes []byte
+	err := r.db.QueryRow(query, projectID, envID, key, version).Scan(&c.ID, &c.UpdatedAt, &c.Compatibility, &c.SchemaName, &c.Version, &dataBytes, &schemaBytes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(dataBytes, &c.Data)
+	json.Unmarshal(schemaBytes, &c.Schema)
+	return &c, nil
+}
+
 // Rollback finds a specific version of a config and creates a NEW version (latest + 1)
-// with that old content. This preserves history (immutable).
-func (r *Repository) Rollback(projectID, envID int, key string, targetVersion int, userID int) (*Config, error) {
+// with that old content. This preserves history (immutable). A non-nil check
+// can veto the rollback or change the content restored.
+func (r *Repository) Rollback(projectID, envID int, key string, targetVersion int, userID int, check RollbackCheck) (*Config, error) {
 	if r.db == nil {
 		return nil, fmt.Errorf("database connection unavailable")
 	}
@@ -152,17 +254,19 @@ func (r *Repository) Rollback(projectID, envID int, key string, targetVersion in
 	}
 	defer tx.Rollback()
 
-	// 1. Find the Config ID
+	// 1. Find and lock the Config ID
 	var configID int
 	err = tx.QueryRow(`
 		SELECT id FROM configs 
-		WHERE project_id = $1 AND environment_id = $2 AND key = $3`,
+		WHERE project_id = $1 AND environment_id = $2 AND key = $3
+		FOR UPDATE`,
 		projectID, envID, key).Scan(&configID)
 	if err != nil {
 		return nil, fmt.Errorf("config not found: %v", err)
 	}
 
 	// 2. Fetch the data from the TARGET version
+	target := Config{ID: configID, ProjectID: projectID, EnvID: envID, Key: key, Version: targetVersion}
 	var oldData, oldSchema []byte
 	err = tx.QueryRow(`
 		SELECT data, schema FROM config_versions 
@@ -171,15 +275,26 @@ func (r *Repository) Rollback(projectID, envID int, key string, targetVersion in
 	if err != nil {
 		return nil, fmt.Errorf("target version %d not found: %v", targetVersion, err)
 	}
+	json.Unmarshal(oldData, &target.Data)
+	json.Unmarshal(oldSchema, &target.Schema)
 
-	// 3. Get current max version
-	var currentVersion int
-	err = tx.QueryRow(`SELECT MAX(version) FROM config_versions WHERE config_id = $1`, configID).Scan(&currentVersion)
+	// 3. Get and check the current version
+	current, err := latestLocked(tx, configID, projectID, envID, key)
 	if err != nil {
 		return nil, err
 	}
-	
-	newVersion := currentVersion + 1
+	if current == nil {
+		return nil, fmt.Errorf("config not found")
+	}
+	if check != nil {
+		if err := check(current, &target); err != nil {
+			return nil, err
+		}
+		oldData, _ = json.Marshal(target.Data)
+		oldSchema, _ = json.Marshal(target.Schema)
+	}
+
+	newVersion := current.Version + 1
 
 	// 4. Insert new version as a copy of the old one
 	_, err = tx.Exec(`
@@ -196,14 +311,6 @@ func (r *Repository) Rollback(projectID, envID int, key string, targetVersion in
 	}
 
 	// Return the new config state
-	var c Config
-	c.ID = configID
-	c.ProjectID = projectID
-	c.EnvID = envID
-	c.Key = key
-	c.Version = newVersion
-	json.Unmarshal(oldData, &c.Data)
-	json.Unmarshal(oldSchema, &c.Schema)
-
-	return &c, nil
+	target.Version = newVersion
+	return &target, nil
 }
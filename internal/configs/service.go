@@ -3,30 +3,150 @@
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Service struct {
 	repo     *Repository
 	sentinel *SentinelClient
+	reads    *readBuffer // Reads of deprecated fields not stored yet
+	stop     chan struct{}
+	done     chan struct{}
 }
 
 func NewService(repo *Repository, sentinel *SentinelClient) *Service {
-	return &Service{repo: repo, sentinel: sentinel}
+	return &Service{repo: repo, sentinel: sentinel, reads: &readBuffer{}}
+}
+
+// Start stores the reads of deprecated fields the service tracks every
+// DeprecatedReadFlushInterval, until Close.
+func (s *Service) Start() {
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go s.flushDeprecatedReads(DeprecatedReadFlushInterval)
+}
+
+// Close stops the flushing started by Start and stores the reads tracked
+// since the last flush.
+func (s *Service) Close() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	s.storeDeprecatedReads()
 }
 
 func (s *Service) CreateConfig(projectID, envID int, key string, data, schema Map, userID int) (*Config, error) {
+	schema, err := s.resolveSchema(projectID, envID, key, schema)
+	if err != nil {
+		return nil, err
+	}
+	data, warnings, err := s.check(data, schema)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.repo.CreateOrUpdate(projectID, envID, key, data, schema, userID, func(current *Config) error {
+		return checkCompatibility(current, schema)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg.Warnings = warnings
+	return cfg, nil
+}
+
+// Plan is the outcome of a dry-run write: what would be stored, and how it
+// differs from the current version.
+type Plan struct {
+	DryRun         bool     `json:"dry_run"`
+	Key            string   `json:"key"`
+	CurrentVersion int      `json:"current_version"` // 0 if the key does not exist yet
+	Version        int      `json:"version"`         // Version the write would create
+	Data           Map      `json:"data"`
+	Diff           []Change `json:"diff"`
+	Warnings       []string `json:"warnings,omitempty"` // Non-fatal validation findings
+}
+
+// PlanConfig runs the same checks as CreateConfig without writing anything.
+func (s *Service) PlanConfig(projectID, envID int, key string, data, schema Map) (*Plan, error) {
+	schema, err := s.resolveSchema(projectID, envID, key, schema)
+	if err != nil {
+		return nil, err
+	}
+	data, warnings, err := s.check(data, schema)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.repo.GetLatest(projectID, envID, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCompatibility(current, schema); err != nil {
+		return nil, err
+	}
+	plan := newPlan(key, current, data)
+	plan.Warnings = warnings
+	return plan, nil
+}
+
+// resolveSchema returns the schema a write to key is validated against: the
+// latest version of the registered schema the key is bound to or, for unbound
+// keys, the schema sent with the write. Writes to a bound key may only send a
+// schema identical to the registered one.
+func (s *Service) resolveSchema(projectID, envID int, key string, inline Map) (Map, error) {
+	name, err := s.repo.SchemaBinding(projectID, envID, key)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		if len(inline) == 0 {
+			return nil, ErrSchemaRequired
+		}
+		return inline, nil
+	}
+
+	registered, err := s.repo.GetSchema(projectID, name, 0)
+	if err != nil {
+		return nil, err
+	}
+	if registered == nil {
+		return nil, fmt.Errorf("bound schema '%s' not found", name)
+	}
+	if len(inline) > 0 {
+		var sent, bound Schema
+		if err := convert(inline, &sent); err != nil {
+			return nil, fmt.Errorf("invalid schema format: %w", err)
+		}
+		convert(registered.Definition, &bound)
+		if !reflect.DeepEqual(sent, bound) {
+			return nil, &SchemaBindingError{Key: key, Schema: name, Version: registered.Version}
+		}
+	}
+	return registered.Definition, nil
+}
+
+// check parses the schema, validates data against it and runs Sentinel linting.
+// It returns the data to store, without unknown fields if the schema strips
+// them, and any warnings.
+func (s *Service) check(data, schema Map) (Map, []string, error) {
 	// 1. Convert Map to Schema struct for internal validation
 	schemaBytes, _ := json.Marshal(schema)
 	var schemaStruct Schema
 	if err := json.Unmarshal(schemaBytes, &schemaStruct); err != nil {
-		return nil, fmt.Errorf("invalid schema format: %w", err)
+		return nil, nil, fmt.Errorf("invalid schema format: %w", err)
 	}
 
 	// 2. Perform internal validation
-	if err := Validate(schemaStruct, data); err != nil {
-		return nil, fmt.Errorf("local validation failed: %w", err)
+	warnings, err := Check(schemaStruct, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("local validation failed: %w", err)
+	}
+	if schemaStruct.UnknownFields == UnknownStrip {
+		data = StripUnknown(schemaStruct, data)
 	}
 
 	// 3. Optional: Deep Linting with Sentinel
@@ -38,19 +158,262 @@ func (s *Service) CreateConfig(projectID, envID int, key string, data, schema Ma
 			// For "premium" feel, we might want to block or at least flag it.
 			fmt.Printf("Sentinel linting error (skipped): %v\n", err)
 		} else if !valid {
-			return nil, &ValidationError{Errors: append([]string{"Sentinel deep linting failed"}, errs...)}
+			return nil, nil, &ValidationError{Errors: append([]string{"Sentinel deep linting failed"}, errs...)}
 		}
 	}
 
-	return s.repo.CreateOrUpdate(projectID, envID, key, data, schema, userID)
+	return data, warnings, nil
+}
+
+// checkCompatibility rejects a schema that may not replace the current
+// version's schema under the key's compatibility mode. The first version of a
+// key is not checked.
+func checkCompatibility(current *Config, schema Map) error {
+	if current == nil {
+		return nil
+	}
+	mode, err := ParseCompatibilityMode(current.Compatibility)
+	if err != nil {
+		return err
+	}
+	var oldSchema, newSchema Schema
+	if err := convert(current.Schema, &oldSchema); err != nil {
+		return fmt.Errorf("invalid stored schema: %w", err)
+	}
+	if err := convert(schema, &newSchema); err != nil {
+		return fmt.Errorf("invalid schema format: %w", err)
+	}
+	if violations := CheckCompatibility(oldSchema, newSchema, mode); len(violations) > 0 {
+		return &CompatibilityError{Mode: mode, Violations: violations}
+	}
+	return nil
+}
+
+// RegisterSchema stores definition as the next version of a named schema. The
+// change must be compatible with the previous version under the schema's mode;
+// a non-empty mode replaces that mode first.
+func (s *Service) RegisterSchema(projectID int, name string, definition Map, mode string) (*RegisteredSchema, error) {
+	var schema Schema
+	if err := convert(definition, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema format: %w", err)
+	}
+	if err := checkRules(schema); err != nil {
+		return nil, err
+	}
+
+	var explicit CompatibilityMode
+	if mode != "" {
+		var err error
+		if explicit, err = ParseCompatibilityMode(mode); err != nil {
+			return nil, err
+		}
+	}
+
+	// The repository has already applied an explicit mode to latest.
+	return s.repo.CreateSchemaVersion(projectID, name, definition, explicit, func(latest *RegisteredSchema) error {
+		if latest == nil {
+			return nil
+		}
+		effective, _ := ParseCompatibilityMode(latest.Compatibility)
+		var previous Schema
+		if err := convert(latest.Definition, &previous); err != nil {
+			return fmt.Errorf("invalid stored schema: %w", err)
+		}
+		if violations := CheckCompatibility(previous, schema, effective); len(violations) > 0 {
+			return &CompatibilityError{Mode: effective, Violations: violations}
+		}
+		return nil
+	})
+}
+
+// checkRules rejects schemas the validator cannot enforce.
+func checkRules(schema Schema) error {
+	if len(schema.Rules) == 0 {
+		return &ValidationError{Errors: []string{"schema has no rules"}}
+	}
+	errs := append(ruleErrors("", schema.Rules), deprecationErrors("", schema.Rules)...)
+	errs = append(errs, constraintErrors(schema)...)
+	if !schema.UnknownFields.valid() {
+		errs = append(errs, fmt.Sprintf("unknown_fields must be reject, warn or strip, got %q", schema.UnknownFields))
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func ruleErrors(prefix string, rules map[string]FieldRule) []string {
+	var errs []string
+	for _, name := range ruleKeys(rules) {
+		key, rule := prefix+name, rules[name]
+		switch rule.Type {
+		case TypeString, TypeInt, TypeFloat, TypeBool, TypeEnum, TypeJSON:
+		default:
+			errs = append(errs, fmt.Sprintf("field '%s' has unknown type %q", key, rule.Type))
+		}
+		if len(rule.Fields) > 0 && rule.Type != TypeJSON {
+			errs = append(errs, fmt.Sprintf("field '%s' has nested fields but is not of type json", key))
+		}
+		errs = append(errs, ruleErrors(key+".", rule.Fields)...)
+	}
+	return errs
+}
+
+// GetSchema returns a registered schema version (0 for the latest), or nil.
+func (s *Service) GetSchema(projectID int, name string, version int) (*RegisteredSchema, error) {
+	return s.repo.GetSchema(projectID, name, version)
+}
+
+// ListSchemas returns the latest version of every registered schema.
+func (s *Service) ListSchemas(projectID int) ([]RegisteredSchema, error) {
+	return s.repo.ListSchemas(projectID)
+}
+
+// BindSchema binds a key to a registered schema; later writes are validated
+// against its latest version. An empty name unbinds the key.
+func (s *Service) BindSchema(projectID, envID int, key, name string) error {
+	if name != "" {
+		registered, err := s.repo.GetSchema(projectID, name, 0)
+		if err != nil {
+			return err
+		}
+		if registered == nil {
+			return ErrSchemaNotFound
+		}
+	}
+	return s.repo.BindSchema(projectID, envID, key, name)
+}
+
+// SetCompatibility changes the schema compatibility mode of a key.
+func (s *Service) SetCompatibility(projectID, envID int, key string, mode CompatibilityMode) error {
+	return s.repo.SetCompatibility(projectID, envID, key, mode)
+}
+
+func newPlan(key string, current *Config, data Map) *Plan {
+	plan := &Plan{DryRun: true, Key: key, Version: 1, Data: data}
+	var currentData Map
+	if current != nil {
+		plan.CurrentVersion = current.Version
+		plan.Version = current.Version + 1
+		currentData = current.Data
+	}
+	plan.Diff = Diff(currentData, data)
+	return plan
 }
 
 func (s *Service) GetConfig(projectID, envID int, key string) (*Config, error) {
 	return s.repo.GetLatest(projectID, envID, key)
 }
 
+// GetConfigVersion returns a specific version, or nil if it does not exist.
+func (s *Service) GetConfigVersion(projectID, envID int, key string, version int) (*Config, error) {
+	return s.repo.GetVersion(projectID, envID, key, version)
+}
+
+// TrackDeprecatedReads records that an API key read the deprecated fields set
+// in cfg and returns warnings describing them. The reads are counted in
+// memory and stored by flushDeprecatedReads, or by Close.
+func (s *Service) TrackDeprecatedReads(projectID int, cfg *Config, apiKeyID, userAgent string) []string {
+	var schema Schema
+	if err := convert(cfg.Schema, &schema); err != nil {
+		return nil
+	}
+	fields := DeprecatedFields(schema, cfg.Data)
+	if len(fields) == 0 {
+		return nil
+	}
+	now := time.Now()
+	s.reads.add(projectID, cfg.EnvID, cfg.Key, fields, apiKeyID, userAgent, now)
+	return ReadWarnings(schema, cfg.Data, now)
+}
+
+// flushDeprecatedReads stores the tracked reads every interval until stop is
+// closed. Recording is best effort; reads that could not be stored are
+// logged and dropped.
+func (s *Service) flushDeprecatedReads(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.storeDeprecatedReads()
+		}
+	}
+}
+
+func (s *Service) storeDeprecatedReads() {
+	for projectID, reads := range s.reads.drain() {
+		if err := s.repo.RecordDeprecatedReads(projectID, reads); err != nil {
+			fmt.Printf("Deprecated read tracking failed (skipped): %v\n", err)
+		}
+	}
+}
+
+// ListDeprecatedReads reports which API keys read deprecated fields.
+func (s *Service) ListDeprecatedReads(projectID, envID int, key string) ([]DeprecatedRead, error) {
+	return s.repo.ListDeprecatedReads(projectID, envID, key)
+}
+
+// ResolveEnvironment accepts either a numeric environment ID or a slug.
+func (s *Service) ResolveEnvironment(projectID int, env string) (int, error) {
+	if id, err := strconv.Atoi(env); err == nil {
+		return id, nil
+	}
+	return s.repo.ResolveEnvironment(projectID, env)
+}
+
+// PlanRollback reports what RollbackConfig would do without writing anything.
+func (s *Service) PlanRollback(projectID, envID int, key string, targetVersion int) (*Plan, error) {
+	current, err := s.repo.GetLatest(projectID, envID, key)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("config not found")
+	}
+	target, err := s.repo.GetVersion(projectID, envID, key, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, fmt.Errorf("target version %d not found", targetVersion)
+	}
+	if err := s.checkRollback(projectID, current, target); err != nil {
+		return nil, err
+	}
+	return newPlan(key, current, target.Data), nil
+}
+
 func (s *Service) RollbackConfig(projectID, envID int, key string, targetVersion int, userID int) (*Config, error) {
-	return s.repo.Rollback(projectID, envID, key, targetVersion, userID)
+	return s.repo.Rollback(projectID, envID, key, targetVersion, userID, func(current, target *Config) error {
+		return s.checkRollback(projectID, current, target)
+	})
+}
+
+// checkRollback vets restoring target over current. Restoring an old version
+// also restores its schema, which must be compatible with the current one like
+// any other write. A key bound to a registered schema keeps it instead: the
+// old data is validated against the latest registered version and stored with
+// it, so a rollback can't bring back a looser schema from before the binding.
+func (s *Service) checkRollback(projectID int, current, target *Config) error {
+	if current.SchemaName != "" {
+		registered, err := s.repo.GetSchema(projectID, current.SchemaName, 0)
+		if err != nil {
+			return err
+		}
+		if registered == nil {
+			return fmt.Errorf("bound schema '%s' not found", current.SchemaName)
+		}
+		data, _, err := s.check(target.Data, registered.Definition)
+		if err != nil {
+			return err
+		}
+		target.Data, target.Schema = data, registered.Definition
+	}
+	return checkCompatibility(current, target.Schema)
 }
 
 func (s *Service) FetchExternal(url string) (map[string]interface{}, error) {
@@ -69,9 +432,21 @@ func (s *Service) FetchExternal(url string) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to fetch from source: %s", resp.Status)
 	}
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode source data (expected JSON): %w", err)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the declared content type; raw hosts (e.g. Gists) serve text/plain,
+	// so fall back to the URL's extension.
+	format := FormatFromContentType(resp.Header.Get("Content-Type"))
+	if format == FormatJSON {
+		format = FormatFromPath(resp.Request.URL.Path)
+	}
+
+	data, err := DecodeMap(body, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode source data (expected JSON, YAML or TOML): %w", err)
 	}
 
 	return data, nil
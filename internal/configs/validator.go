@@ -3,7 +3,9 @@
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 // DataType defines the supported types for configuration values.
@@ -27,40 +29,147 @@ type FieldRule struct {
 	Min         *float64      `json:"min,omitempty"`  // For int/float
 	Max         *float64      `json:"max,omitempty"`  // For int/float
 	Allowed     []interface{} `json:"allowed,omitempty"` // For enum
+	Secret      bool          `json:"secret,omitempty"`  // Exported to Kubernetes as a Secret, not a ConfigMap
+	Fields      map[string]FieldRule `json:"fields,omitempty"` // For json objects: rules for nested keys
+	Deprecated  *Deprecation         `json:"deprecated,omitempty"`
+}
+
+// UnknownFieldPolicy decides what happens to top-level fields without a rule.
+type UnknownFieldPolicy string
+
+const (
+	UnknownReject UnknownFieldPolicy = "reject" // Fail validation (the default)
+	UnknownWarn   UnknownFieldPolicy = "warn"   // Accept and store them, with a warning
+	UnknownStrip  UnknownFieldPolicy = "strip"  // Drop them before storing, with a warning
+)
+
+func (p UnknownFieldPolicy) valid() bool {
+	switch p {
+	case "", UnknownReject, UnknownWarn, UnknownStrip:
+		return true
+	}
+	return false
 }
 
 // Schema defines the contract that a configuration must adhere to.
 type Schema struct {
-	Version int                  `json:"version"`
-	Rules   map[string]FieldRule `json:"rules"`
+	Version       int                  `json:"version"`
+	Rules         map[string]FieldRule `json:"rules"`
+	Constraints   []Constraint         `json:"constraints,omitempty"`    // Cross-field rules, checked after the fields
+	UnknownFields UnknownFieldPolicy   `json:"unknown_fields,omitempty"` // reject (default), warn or strip
 }
 
 // ValidationError represents a collection of validation failures.
 type ValidationError struct {
 	Errors []string
+	// Violations holds the failed cross-field constraints with the paths of
+	// every field involved. Their messages are also in Errors.
+	Violations []RuleViolation
 }
 
 func (e *ValidationError) Error() string {
 	return fmt.Sprintf("validation failed: %s", strings.Join(e.Errors, "; "))
 }
 
-// Validate checks a raw configuration map against the provided Schema.
+// Validate checks a raw configuration map against the provided Schema. It does
+// not modify config; defaults are only visible to cross-field constraints,
+// which run against a copy from ApplyDefaults.
 func Validate(schema Schema, config map[string]interface{}) error {
-	var errs []string
+	_, err := Check(schema, config)
+	return err
+}
+
+// Check validates config like Validate and also returns warnings about
+// deprecated fields before their sunset, and about unknown fields that the
+// schema's UnknownFields policy lets through.
+func Check(schema Schema, config map[string]interface{}) ([]string, error) {
+	errs, warnings := validateFields("", schema.Rules, config, time.Now())
+
+	// 4. Check for unknown fields (Strict mode unless the schema relaxes it)
+	if !schema.UnknownFields.valid() {
+		errs = append(errs, fmt.Sprintf("unknown_fields must be reject, warn or strip, got %q", schema.UnknownFields))
+	}
+	for _, key := range UnknownFields(schema, config) {
+		switch schema.UnknownFields {
+		case UnknownWarn:
+			warnings = append(warnings, fmt.Sprintf("unknown field '%s' is not in the schema", key))
+		case UnknownStrip:
+			warnings = append(warnings, fmt.Sprintf("unknown field '%s' is not in the schema and will be dropped", key))
+		default:
+			errs = append(errs, fmt.Sprintf("unknown field '%s' is not allowed by schema", key))
+		}
+	}
+
+	if len(errs) > 0 {
+		return warnings, &ValidationError{Errors: errs}
+	}
+
+	// 5. Cross-field constraints, once every field is well-typed
+	programs, cerrs := compileConstraints(schema)
+	if len(cerrs) > 0 {
+		return warnings, &ValidationError{Errors: cerrs}
+	}
+	if violations := checkConstraints(schema.Constraints, programs, ApplyDefaults(schema, config)); len(violations) > 0 {
+		verr := &ValidationError{Violations: violations}
+		for _, v := range violations {
+			verr.Errors = append(verr.Errors, v.Message)
+		}
+		return warnings, verr
+	}
+
+	return warnings, nil
+}
+
+// UnknownFields returns the top-level fields of config without a rule, sorted.
+func UnknownFields(schema Schema, config map[string]interface{}) []string {
+	var out []string
+	for key := range config {
+		if _, known := schema.Rules[key]; !known {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// StripUnknown returns a copy of config without the top-level fields the
+// schema has no rule for.
+func StripUnknown(schema Schema, config map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(config))
+	for key, val := range config {
+		if _, known := schema.Rules[key]; known {
+			out[key] = val
+		}
+	}
+	return out
+}
+
+// validateFields checks config against rules, descending into json objects
+// that have nested rules. Keys are reported by path, e.g. "db.pool". Nested
+// keys without a rule are allowed, as json fields are free-form. A null value
+// counts as missing. Deprecated fields are warnings until their sunset and
+// errors from then on.
+func validateFields(prefix string, rules map[string]FieldRule, config map[string]interface{}, now time.Time) (errs, warnings []string) {
+	for name, rule := range rules {
+		key := prefix + name
 
-	for key, rule := range schema.Rules {
 		// 1. Check for missing required fields
-		val, exists := config[key]
-		if !exists {
+		val, exists := config[name]
+		if !exists || val == nil {
 			if rule.Required {
 				errs = append(errs, fmt.Sprintf("field '%s' is required", key))
-			} else if rule.Default != nil {
-				// Apply default if missing (handled by caller usually, but good to know)
-				config[key] = rule.Default
 			}
 			continue
 		}
 
+		if d := rule.Deprecated; d != nil {
+			if d.Expired(now) {
+				errs = append(errs, d.describe(key, true))
+				continue
+			}
+			warnings = append(warnings, d.describe(key, false))
+		}
+
 		// 2. Type validation
 		if !isValidType(val, rule.Type) {
 			errs = append(errs, fmt.Sprintf("field '%s' expected type %s, got %T", key, rule.Type, val))
@@ -71,20 +180,16 @@ func Validate(schema Schema, config map[string]interface{}) error {
 		if err := validateConstraints(key, val, rule); err != nil {
 			errs = append(errs, err.Error())
 		}
-	}
 
-	// 4. Check for unknown fields (Strict mode)
-	for key := range config {
-		if _, known := schema.Rules[key]; !known {
-			errs = append(errs, fmt.Sprintf("unknown field '%s' is not allowed by schema", key))
+		if nested, ok := val.(map[string]interface{}); ok && len(rule.Fields) > 0 {
+			nestedErrs, nestedWarnings := validateFields(key+".", rule.Fields, nested, now)
+			errs = append(errs, nestedErrs...)
+			warnings = append(warnings, nestedWarnings...)
 		}
 	}
 
-	if len(errs) > 0 {
-		return &ValidationError{Errors: errs}
-	}
-
-	return nil
+	sort.Strings(warnings)
+	return errs, warnings
 }
 
 // isValidType checks if the value matches the expected DataType using reflection.
@@ -119,6 +224,9 @@ func isValidType(val interface{}, expected DataType) bool {
 		return false
 	case TypeJSON:
 		// Map or slice
+		if val == nil {
+			return false
+		}
 		kind := reflect.TypeOf(val).Kind()
 		return kind == reflect.Map || kind == reflect.Slice
 	default:
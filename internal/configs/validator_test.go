@@ -2,6 +2,7 @@ package configs
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -67,6 +68,21 @@ func TestValidate(t *testing.T) {
 			}`,
 			wantErr: true,
 		},
+		{
+			name: "Null Optional Field",
+			config: `{
+				"feature_enabled": true,
+				"max_users": null
+			}`,
+			wantErr: false,
+		},
+		{
+			name: "Null Required Field",
+			config: `{
+				"feature_enabled": null
+			}`,
+			wantErr: true,
+		},
 		{
 			name: "Unknown Field",
 			config: `{
@@ -91,3 +107,44 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckUnknownFields(t *testing.T) {
+	config := map[string]interface{}{"name": "a", "beta": true, "alpha": 1.0}
+
+	tests := []struct {
+		policy   UnknownFieldPolicy
+		wantErr  bool
+		warnings []string
+	}{
+		{policy: "", wantErr: true},
+		{policy: UnknownReject, wantErr: true},
+		{policy: UnknownWarn, warnings: []string{
+			"unknown field 'alpha' is not in the schema",
+			"unknown field 'beta' is not in the schema",
+		}},
+		{policy: UnknownStrip, warnings: []string{
+			"unknown field 'alpha' is not in the schema and will be dropped",
+			"unknown field 'beta' is not in the schema and will be dropped",
+		}},
+		{policy: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		schema := Schema{Rules: map[string]FieldRule{"name": {Type: TypeString}}, UnknownFields: tt.policy}
+		warnings, err := Check(schema, config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: Check() error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%q: warnings = %q, want %q", tt.policy, warnings, tt.warnings)
+		}
+	}
+
+	schema := Schema{Rules: map[string]FieldRule{"name": {Type: TypeString}}}
+	if got := StripUnknown(schema, config); !reflect.DeepEqual(got, map[string]interface{}{"name": "a"}) {
+		t.Errorf("StripUnknown() = %v", got)
+	}
+	if len(config) != 3 {
+		t.Errorf("StripUnknown() modified its input: %v", config)
+	}
+}
@@ -0,0 +1,9 @@
+-- Up
+-- Schema compatibility mode per config key: backward, forward, full or none.
+-- Keys that already exist get none, so their next write is not suddenly
+-- checked; keys created from now on default to backward.
+ALTER TABLE configs ADD COLUMN IF NOT EXISTS compatibility VARCHAR(16) NOT NULL DEFAULT 'none';
+ALTER TABLE configs ALTER COLUMN compatibility SET DEFAULT 'backward';
+
+-- Down
+ALTER TABLE configs DROP COLUMN compatibility;
@@ -0,0 +1,33 @@
+-- Up
+-- Admin keys authorize privileged operations such as registering schemas.
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS admin_key VARCHAR(64) UNIQUE;
+UPDATE projects
+SET admin_key = replace(gen_random_uuid()::text, '-', '') || replace(gen_random_uuid()::text, '-', '')
+WHERE admin_key IS NULL;
+
+CREATE TABLE IF NOT EXISTS schemas (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    compatibility VARCHAR(16) NOT NULL DEFAULT 'backward', -- Checked when a new version is registered
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(project_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS schema_versions (
+    id SERIAL PRIMARY KEY,
+    schema_id INTEGER REFERENCES schemas(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(schema_id, version)
+);
+
+-- Keys bound to a registered schema are validated against its latest version.
+ALTER TABLE configs ADD COLUMN IF NOT EXISTS schema_name VARCHAR(255);
+
+-- Down
+ALTER TABLE configs DROP COLUMN schema_name;
+DROP TABLE schema_versions;
+DROP TABLE schemas;
+ALTER TABLE projects DROP COLUMN admin_key;
@@ -0,0 +1,19 @@
+-- Up
+-- Which API keys still read configs containing deprecated fields, so owners
+-- know who to chase before a field's sunset date.
+CREATE TABLE IF NOT EXISTS deprecated_field_reads (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    environment_id INTEGER REFERENCES environments(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    field VARCHAR(255) NOT NULL,
+    api_key_id VARCHAR(16) NOT NULL, -- Fingerprint of the API key, never the key itself
+    user_agent TEXT NOT NULL DEFAULT '',
+    reads BIGINT NOT NULL DEFAULT 0,
+    first_read_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_read_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(project_id, environment_id, key, field, api_key_id)
+);
+
+-- Down
+DROP TABLE deprecated_field_reads;
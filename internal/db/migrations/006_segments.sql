@@ -0,0 +1,23 @@
+-- Up
+-- Project-level segments that flag rules target. Like config versions,
+-- segment versions are immutable; flags always use the latest one.
+CREATE TABLE IF NOT EXISTS segments (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(project_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS segment_versions (
+    id SERIAL PRIMARY KEY,
+    segment_id INTEGER REFERENCES segments(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(segment_id, version)
+);
+
+-- Down
+DROP TABLE segment_versions;
+DROP TABLE segments;
@@ -0,0 +1,17 @@
+-- Up
+-- How often each flag served each variation, per hour, from server-side
+-- evaluations and the counts SDKs report for local ones. Drives the stale flag
+-- report.
+CREATE TABLE IF NOT EXISTS flag_evaluations (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    environment_id INTEGER REFERENCES environments(id) ON DELETE CASCADE,
+    flag_key VARCHAR(255) NOT NULL,
+    variation VARCHAR(255) NOT NULL DEFAULT '', -- Empty for failed evaluations
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    UNIQUE(project_id, environment_id, flag_key, variation, hour)
+);
+
+-- Down
+DROP TABLE flag_evaluations;
@@ -0,0 +1,17 @@
+-- Up
+-- Experiments, one row per experiment and environment. The definition holds
+-- the layer, traffic allocation and arms; version increases on every change.
+CREATE TABLE IF NOT EXISTS experiments (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    environment_id INTEGER REFERENCES environments(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    definition JSONB NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(project_id, environment_id, key)
+);
+
+-- Down
+DROP TABLE experiments;
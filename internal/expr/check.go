@@ -0,0 +1,269 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// elemVar is the variable bound to the current element inside all, exists
+// and exists_one.
+const elemVar = "it"
+
+// builtin is a function with fixed parameter types; Dyn accepts anything.
+type builtin struct {
+	params []Type
+	result Type
+}
+
+var builtins = map[string]builtin{
+	"size":       {[]Type{Dyn}, Number}, // string, list or map
+	"startsWith": {[]Type{String, String}, Bool},
+	"endsWith":   {[]Type{String, String}, Bool},
+	"contains":   {[]Type{String, String}, Bool},
+	"matches":    {[]Type{String, String}, Bool}, // RE2 syntax
+	"lower":      {[]Type{String}, String},
+	"upper":      {[]Type{String}, String},
+	"trim":       {[]Type{String}, String},
+}
+
+// Macros take unevaluated arguments: has(path), and all/exists/exists_one
+// (list, predicate) where the predicate sees each element as `it`.
+var macros = map[string]bool{"has": true, "all": true, "exists": true, "exists_one": true}
+
+type checker struct {
+	vars  map[string]Type // nil means any variable is allowed, as Dyn
+	refs  map[string]bool
+	inPre int // > 0 inside a predicate, where `it` is bound
+}
+
+func (c *checker) check(n node) (Type, error) {
+	switch n := n.(type) {
+	case *litNode:
+		return typeOf(n.val), nil
+	case *identNode:
+		if n.name == elemVar && c.inPre > 0 {
+			return Dyn, nil
+		}
+		if c.vars == nil {
+			c.refs[n.name] = true
+			return Dyn, nil
+		}
+		t, ok := c.vars[n.name]
+		if !ok {
+			return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("undeclared reference to '%s'", n.name)}
+		}
+		c.refs[n.name] = true
+		return t, nil
+	case *memberNode:
+		t, err := c.check(n.x)
+		if err != nil {
+			return 0, err
+		}
+		if t != Map && t != Dyn {
+			return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("cannot select field '%s' from %s", n.name, t)}
+		}
+		return Dyn, nil
+	case *indexNode:
+		t, err := c.check(n.x)
+		if err != nil {
+			return 0, err
+		}
+		it, err := c.check(n.idx)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case t == List && (it == Number || it == Dyn), t == Map && (it == String || it == Dyn), t == Dyn:
+			return Dyn, nil
+		}
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("cannot index %s with %s", t, it)}
+	case *unaryNode:
+		t, err := c.check(n.x)
+		if err != nil {
+			return 0, err
+		}
+		want := Bool
+		if n.op == "-" {
+			want = Number
+		}
+		if t != want && t != Dyn {
+			return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("operator '%s' needs a %s, got %s", n.op, want, t)}
+		}
+		return want, nil
+	case *binaryNode:
+		return c.checkBinary(n)
+	case *condNode:
+		ct, err := c.check(n.c)
+		if err != nil {
+			return 0, err
+		}
+		if ct != Bool && ct != Dyn {
+			return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("condition must be a bool, got %s", ct)}
+		}
+		tt, err := c.check(n.t)
+		if err != nil {
+			return 0, err
+		}
+		ft, err := c.check(n.f)
+		if err != nil {
+			return 0, err
+		}
+		if tt == ft {
+			return tt, nil
+		}
+		return Dyn, nil
+	case *listNode:
+		for _, e := range n.elems {
+			if _, err := c.check(e); err != nil {
+				return 0, err
+			}
+		}
+		return List, nil
+	case *callNode:
+		return c.checkCall(n)
+	}
+	return 0, &Error{Pos: n.pos(), Msg: "unsupported expression"}
+}
+
+func (c *checker) checkBinary(n *binaryNode) (Type, error) {
+	l, err := c.check(n.l)
+	if err != nil {
+		return 0, err
+	}
+	r, err := c.check(n.r)
+	if err != nil {
+		return 0, err
+	}
+	mismatch := &Error{Pos: n.at, Msg: fmt.Sprintf("operator '%s' cannot be applied to %s and %s", n.op, l, r)}
+
+	switch n.op {
+	case "&&", "||":
+		if (l == Bool || l == Dyn) && (r == Bool || r == Dyn) {
+			return Bool, nil
+		}
+	case "==", "!=":
+		if l == r || l == Dyn || r == Dyn || l == Null || r == Null {
+			return Bool, nil
+		}
+	case "<", "<=", ">", ">=":
+		if (l == Dyn || l == Number || l == String) && (r == Dyn || r == Number || r == String) && (l == r || l == Dyn || r == Dyn) {
+			return Bool, nil
+		}
+	case "in":
+		if r == List || r == Map || r == Dyn {
+			return Bool, nil
+		}
+	case "+":
+		switch {
+		case l == Dyn || r == Dyn:
+			return Dyn, nil
+		case l == r && (l == Number || l == String || l == List):
+			return l, nil
+		}
+	default: // - * / %
+		if (l == Number || l == Dyn) && (r == Number || r == Dyn) {
+			return Number, nil
+		}
+	}
+	return 0, mismatch
+}
+
+func (c *checker) checkCall(n *callNode) (Type, error) {
+	if macros[n.fn] {
+		return c.checkMacro(n)
+	}
+	fn, ok := builtins[n.fn]
+	if !ok {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("unknown function '%s'", n.fn)}
+	}
+	if len(n.args) != len(fn.params) {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("%s expects %d argument(s), got %d", n.fn, len(fn.params), len(n.args))}
+	}
+	for i, arg := range n.args {
+		t, err := c.check(arg)
+		if err != nil {
+			return 0, err
+		}
+		want := fn.params[i]
+		if n.fn == "size" && t != String && t != List && t != Map && t != Dyn {
+			return 0, &Error{Pos: arg.pos(), Msg: fmt.Sprintf("size needs a string, list or map, got %s", t)}
+		}
+		if want != Dyn && t != Dyn && t != want {
+			return 0, &Error{Pos: arg.pos(), Msg: fmt.Sprintf("argument %d of %s must be a %s, got %s", i+1, n.fn, want, t)}
+		}
+	}
+	if lit, ok := n.args[len(n.args)-1].(*litNode); ok && n.fn == "matches" {
+		re, err := regexp.Compile(lit.val.(string))
+		if err != nil {
+			return 0, &Error{Pos: lit.at, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+		n.re = re
+	}
+	return fn.result, nil
+}
+
+func (c *checker) checkMacro(n *callNode) (Type, error) {
+	if n.fn == "has" {
+		if len(n.args) != 1 {
+			return 0, &Error{Pos: n.at, Msg: "has expects 1 argument"}
+		}
+		switch n.args[0].(type) {
+		case *identNode, *memberNode:
+		default:
+			return 0, &Error{Pos: n.at, Msg: "has needs a field, e.g. has(a) or has(a.b)"}
+		}
+		if _, err := c.check(n.args[0]); err != nil {
+			return 0, err
+		}
+		return Bool, nil
+	}
+
+	if len(n.args) != 2 {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("%s expects a list and a predicate", n.fn)}
+	}
+	t, err := c.check(n.args[0])
+	if err != nil {
+		return 0, err
+	}
+	if t != List && t != Map && t != Dyn {
+		return 0, &Error{Pos: n.args[0].pos(), Msg: fmt.Sprintf("%s needs a list or map, got %s", n.fn, t)}
+	}
+
+	// A call missing its first argument is applied to the element:
+	// all(xs, startsWith("a")) means all(xs, startsWith(it, "a")).
+	if call, ok := n.args[1].(*callNode); ok {
+		if fn, ok := builtins[call.fn]; ok && len(call.args) == len(fn.params)-1 {
+			call.args = append([]node{&identNode{at: call.at, name: elemVar}}, call.args...)
+		}
+	}
+
+	c.inPre++
+	pt, err := c.check(n.args[1])
+	c.inPre--
+	if err != nil {
+		return 0, err
+	}
+	if pt != Bool && pt != Dyn {
+		return 0, &Error{Pos: n.args[1].pos(), Msg: fmt.Sprintf("predicate of %s must be a bool, got %s", n.fn, pt)}
+	}
+	return Bool, nil
+}
+
+// typeOf returns the dynamic type of a value.
+func typeOf(v interface{}) Type {
+	switch v.(type) {
+	case nil:
+		return Null
+	case bool:
+		return Bool
+	case float64, float32, int, int64, int32:
+		return Number
+	case string:
+		return String
+	case []interface{}:
+		return List
+	case map[string]interface{}:
+		return Map
+	}
+	return Dyn
+}
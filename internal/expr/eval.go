@@ -0,0 +1,524 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type evaluator struct {
+	vars     map[string]interface{}
+	elems    []interface{} // Stack of `it` bindings
+	cost     int
+	patterns map[string]*regexp.Regexp // Patterns of matches not known until evaluation
+}
+
+// charge adds n to the cost of the evaluation, failing once it is over
+// MaxCost.
+func (e *evaluator) charge(pos, n int) error {
+	if e.cost += n; e.cost > MaxCost {
+		return &Error{Pos: pos, Msg: "evaluation cost limit exceeded"}
+	}
+	return nil
+}
+
+// chargeValue charges for v and every byte and element in it, as a deep
+// comparison of v may visit them all. It stops as soon as the budget runs
+// out.
+func (e *evaluator) chargeValue(pos int, v interface{}) error {
+	if err := e.charge(pos, 1); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		return e.charge(pos, len(v))
+	case []interface{}:
+		for _, item := range v {
+			if err := e.chargeValue(pos, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			if err := e.charge(pos, len(k)); err != nil {
+				return err
+			}
+			if err := e.chargeValue(pos, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) eval(n node) (interface{}, error) {
+	if err := e.charge(n.pos(), 1); err != nil {
+		return nil, err
+	}
+
+	switch n := n.(type) {
+	case *litNode:
+		return n.val, nil
+	case *identNode, *memberNode:
+		v, ok, err := e.lookup(n)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &Error{Pos: n.pos(), Msg: fmt.Sprintf("no such field '%s' (use has() for optional fields)", path(n))}
+		}
+		return v, nil
+	case *indexNode:
+		return e.index(n)
+	case *unaryNode:
+		x, err := e.eval(n.x)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			b, ok := x.(bool)
+			if !ok {
+				return nil, typeError(n.at, n.op, x)
+			}
+			return !b, nil
+		}
+		f, ok := x.(float64)
+		if !ok {
+			return nil, typeError(n.at, n.op, x)
+		}
+		return -f, nil
+	case *binaryNode:
+		return e.binary(n)
+	case *condNode:
+		c, err := e.eval(n.c)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := c.(bool)
+		if !ok {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("condition evaluated to %s, not a bool", typeOf(c))}
+		}
+		if b {
+			return e.eval(n.t)
+		}
+		return e.eval(n.f)
+	case *listNode:
+		out := make([]interface{}, 0, len(n.elems))
+		for _, el := range n.elems {
+			v, err := e.eval(el)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case *callNode:
+		return e.call(n)
+	}
+	return nil, &Error{Pos: n.pos(), Msg: "unsupported expression"}
+}
+
+// lookup resolves an identifier or a chain of field selections. ok is false
+// if any part of the path is missing or null.
+func (e *evaluator) lookup(n node) (interface{}, bool, error) {
+	switch n := n.(type) {
+	case *identNode:
+		if n.name == elemVar && len(e.elems) > 0 {
+			return e.elems[len(e.elems)-1], true, nil
+		}
+		v, ok := e.vars[n.name]
+		return normalize(v), ok && v != nil, nil
+	case *memberNode:
+		x, ok, err := e.lookup(n.x)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		m, isMap := x.(map[string]interface{})
+		if !isMap {
+			return nil, false, &Error{Pos: n.at, Msg: fmt.Sprintf("cannot select field '%s' from %s", n.name, typeOf(x))}
+		}
+		v, ok := m[n.name]
+		return normalize(v), ok && v != nil, nil
+	}
+	v, err := e.eval(n)
+	return v, v != nil, err
+}
+
+func (e *evaluator) index(n *indexNode) (interface{}, error) {
+	x, err := e.eval(n.x)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := e.eval(n.idx)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case []interface{}:
+		f, ok := idx.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("list index must be an integer, got %v", idx)}
+		}
+		if f < 0 || int(f) >= len(x) {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("index %v out of range (size %d)", f, len(x))}
+		}
+		return normalize(x[int(f)]), nil
+	case map[string]interface{}:
+		k, ok := idx.(string)
+		if !ok {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("map key must be a string, got %v", idx)}
+		}
+		v, ok := x[k]
+		if !ok {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("no such key '%s'", k)}
+		}
+		return normalize(v), nil
+	}
+	return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("cannot index %s", typeOf(x))}
+}
+
+func (e *evaluator) binary(n *binaryNode) (interface{}, error) {
+	l, err := e.eval(n.l)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logic.
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, typeError(n.at, n.op, l)
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := e.eval(n.r)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, typeError(n.at, n.op, r)
+		}
+		return rb, nil
+	}
+
+	r, err := e.eval(n.r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==", "!=":
+		if err := e.chargeValue(n.at, l); err != nil {
+			return nil, err
+		}
+		if err := e.chargeValue(n.at, r); err != nil {
+			return nil, err
+		}
+		return equal(l, r) == (n.op == "=="), nil
+	case "in":
+		switch r := r.(type) {
+		case []interface{}:
+			for _, item := range r {
+				if err := e.chargeValue(n.at, item); err != nil {
+					return nil, err
+				}
+				if equal(l, normalize(item)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			k, ok := l.(string)
+			_, found := r[k]
+			return ok && found, nil
+		}
+		return nil, typeError(n.at, n.op, r)
+	case "<", "<=", ">", ">=":
+		if s, ok := l.(string); ok {
+			if err := e.charge(n.at, len(s)); err != nil {
+				return nil, err
+			}
+		}
+		cmp, ok := compare(l, r)
+		if !ok {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("cannot compare %s and %s", typeOf(l), typeOf(r))}
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+	case "+":
+		switch l := l.(type) {
+		case string:
+			if r, ok := r.(string); ok {
+				if err := e.grow(n.at, len(l)+len(r), "string", "bytes"); err != nil {
+					return nil, err
+				}
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := r.([]interface{}); ok {
+				if err := e.grow(n.at, len(l)+len(r), "list", "elements"); err != nil {
+					return nil, err
+				}
+				return append(append([]interface{}{}, l...), r...), nil
+			}
+		}
+	}
+
+	lf, lok := l.(float64)
+	rf, rok := r.(float64)
+	if !lok || !rok {
+		return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("operator '%s' cannot be applied to %s and %s", n.op, typeOf(l), typeOf(r))}
+	}
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	}
+	if rf == 0 {
+		return nil, &Error{Pos: n.at, Msg: "division by zero"}
+	}
+	if n.op == "/" {
+		return lf / rf, nil
+	}
+	return math.Mod(lf, rf), nil
+}
+
+func (e *evaluator) call(n *callNode) (interface{}, error) {
+	switch n.fn {
+	case "has":
+		_, ok, err := e.lookup(n.args[0])
+		return ok, err
+	case "all", "exists", "exists_one":
+		return e.quantify(n)
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := e.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	if n.fn == "size" {
+		switch v := args[0].(type) {
+		case string:
+			if err := e.charge(n.at, len(v)); err != nil {
+				return nil, err
+			}
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("size needs a string, list or map, got %s", typeOf(args[0]))}
+	}
+
+	strs := make([]string, len(args))
+	for i, a := range args {
+		s, ok := a.(string)
+		if !ok {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("argument %d of %s must be a string, got %s", i+1, n.fn, typeOf(a))}
+		}
+		strs[i] = s
+	}
+	switch n.fn {
+	case "startsWith", "endsWith":
+		if err := e.charge(n.at, len(strs[1])); err != nil {
+			return nil, err
+		}
+		if n.fn == "startsWith" {
+			return strings.HasPrefix(strs[0], strs[1]), nil
+		}
+		return strings.HasSuffix(strs[0], strs[1]), nil
+	case "contains":
+		if err := e.charge(n.at, len(strs[0])+len(strs[1])); err != nil {
+			return nil, err
+		}
+		return strings.Contains(strs[0], strs[1]), nil
+	case "matches":
+		re, err := e.pattern(n, strs[1])
+		if err != nil {
+			return nil, err
+		}
+		// RE2 runs in time linear in the input, for a given pattern.
+		if err := e.charge(n.at, len(strs[0])*(1+len(re.String())/64)); err != nil {
+			return nil, err
+		}
+		return re.MatchString(strs[0]), nil
+	}
+
+	// The rest copy their argument.
+	if err := e.charge(n.at, len(strs[0])); err != nil {
+		return nil, err
+	}
+	switch n.fn {
+	case "lower":
+		return strings.ToLower(strs[0]), nil
+	case "upper":
+		return strings.ToUpper(strs[0]), nil
+	case "trim":
+		return strings.TrimSpace(strs[0]), nil
+	}
+	return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("unknown function '%s'", n.fn)}
+}
+
+// quantify evaluates all, exists and exists_one. Maps iterate their keys.
+func (e *evaluator) quantify(n *callNode) (interface{}, error) {
+	coll, err := e.eval(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+	var items []interface{}
+	switch c := coll.(type) {
+	case []interface{}:
+		items = c
+	case map[string]interface{}:
+		keys := make([]string, 0, len(c))
+		for k := range c {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			items = append(items, k)
+		}
+	default:
+		return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("%s needs a list or map, got %s", n.fn, typeOf(coll))}
+	}
+	if err := e.charge(n.at, len(items)); err != nil {
+		return nil, err
+	}
+
+	matched := 0
+	for _, item := range items {
+		e.elems = append(e.elems, normalize(item))
+		v, err := e.eval(n.args[1])
+		e.elems = e.elems[:len(e.elems)-1]
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("predicate of %s evaluated to %s, not a bool", n.fn, typeOf(v))}
+		}
+		switch {
+		case n.fn == "all" && !b:
+			return false, nil
+		case n.fn == "exists" && b:
+			return true, nil
+		case b:
+			matched++
+		}
+	}
+	switch n.fn {
+	case "all":
+		return true, nil
+	case "exists":
+		return false, nil
+	}
+	return matched == 1, nil
+}
+
+// grow checks the size of a string or list an expression builds, and charges
+// for copying it.
+func (e *evaluator) grow(pos, size int, kind, unit string) error {
+	if size > MaxValueSize {
+		return &Error{Pos: pos, Msg: fmt.Sprintf("%s would be longer than %d %s", kind, MaxValueSize, unit)}
+	}
+	return e.charge(pos, size)
+}
+
+// pattern returns the compiled pattern of a matches call: the checker's for
+// a literal, or one compiled once per Eval otherwise.
+func (e *evaluator) pattern(n *callNode, src string) (*regexp.Regexp, error) {
+	if n.re != nil {
+		return n.re, nil
+	}
+	if re, ok := e.patterns[src]; ok {
+		return re, nil
+	}
+	if err := e.charge(n.at, len(src)); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil, &Error{Pos: n.at, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+	}
+	e.patterns[src] = re
+	return re, nil
+}
+
+// normalize converts Go integer types to float64, the only number type.
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		switch {
+		case !ok:
+			return 0, false
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+func typeError(pos int, op string, v interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf("operator '%s' cannot be applied to %s", op, typeOf(v))}
+}
+
+// path renders an identifier or selection chain, e.g. "db.pool".
+func path(n node) string {
+	switch n := n.(type) {
+	case *identNode:
+		return n.name
+	case *memberNode:
+		return path(n.x) + "." + n.name
+	}
+	return "?"
+}
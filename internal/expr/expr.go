@@ -0,0 +1,113 @@
+// Package expr implements a small, sandboxed expression language for schema
+// assertions, modeled on CEL:
+//
+//	size(allowed_origins) <= 20 && all(allowed_origins, startsWith("https://"))
+//
+// Expressions are side-effect free, can only read the variables they are
+// given, and run under a fixed evaluation budget. Compile parses and
+// type-checks an expression against declared variable types; Eval runs it.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Type is the static type of an expression.
+type Type int
+
+const (
+	Dyn Type = iota // Unknown until evaluation
+	Bool
+	Number
+	String
+	List
+	Map
+	Null
+)
+
+func (t Type) String() string {
+	return [...]string{"dyn", "bool", "number", "string", "list", "map", "null"}[t]
+}
+
+// Limits keep evaluation of untrusted expressions bounded. Every node
+// evaluated costs 1, and so does every byte or element a builtin scans or
+// copies, so the budget bounds the work done, not just the size of the tree.
+const (
+	MaxLength    = 4096    // Characters in an expression
+	MaxDepth     = 64      // Nesting of the syntax tree
+	MaxCost      = 1000000 // Cost per Eval
+	MaxValueSize = 65536   // Bytes or elements in a string or list built by an expression
+)
+
+// Error is a compile or evaluation error. Pos is the 1-based column, or 0
+// when unknown.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if e.Pos > 0 {
+		return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+	}
+	return e.Msg
+}
+
+// Program is a compiled expression.
+type Program struct {
+	src    string
+	root   node
+	fields []string
+}
+
+// Compile parses src and checks it against vars, the declared variables and
+// their types. The expression must evaluate to a bool.
+func Compile(src string, vars map[string]Type) (*Program, error) {
+	if len(src) > MaxLength {
+		return nil, &Error{Msg: fmt.Sprintf("expression is longer than %d characters", MaxLength)}
+	}
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{vars: vars, refs: map[string]bool{}}
+	t, err := c.check(root)
+	if err != nil {
+		return nil, err
+	}
+	if t != Bool && t != Dyn {
+		return nil, &Error{Pos: root.pos(), Msg: fmt.Sprintf("expression must be a bool, got %s", t)}
+	}
+
+	p := &Program{src: src, root: root}
+	for name := range c.refs {
+		p.fields = append(p.fields, name)
+	}
+	sort.Strings(p.fields)
+	return p, nil
+}
+
+// Source returns the expression text.
+func (p *Program) Source() string { return p.src }
+
+// Fields returns the variables the expression reads, sorted.
+func (p *Program) Fields() []string { return p.fields }
+
+// Eval runs the program against vars. Numbers may be float64 or int; nested
+// values are map[string]interface{} and []interface{} as produced by
+// encoding/json.
+func (p *Program) Eval(vars map[string]interface{}) (bool, error) {
+	e := &evaluator{vars: vars, patterns: map[string]*regexp.Regexp{}}
+	v, err := e.eval(p.root)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, &Error{Pos: p.root.pos(), Msg: fmt.Sprintf("expression evaluated to %s, not a bool", typeOf(v))}
+	}
+	return b, nil
+}
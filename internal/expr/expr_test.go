@@ -0,0 +1,165 @@
+package expr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	var vars map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"allowed_origins": ["https://a.example", "https://b.example"],
+		"mode": "release",
+		"max_retries": 3,
+		"ratio": 0.5,
+		"db": {"pool": {"min": 2, "max": 10}, "hosts": ["a", "b"]},
+		"tags": {"team": "core"}
+	}`), &vars)
+	vars["count"] = 7 // Go ints are numbers too
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`size(allowed_origins) <= 20 && all(allowed_origins, startsWith("https://"))`, true},
+		{`all(allowed_origins, it.endsWith(".example"))`, true},
+		{`exists(allowed_origins, it == "http://c")`, false},
+		{`exists_one(db.hosts, it in ["a", "z"])`, true},
+		{`mode == "release" ? max_retries >= 1 : true`, true},
+		{`db.pool.min <= db.pool.max && db["pool"]["max"] == 10`, true},
+		{`max_retries * 2 + 1 == 7 && 7 % 4 == 3 && -ratio < 0`, true},
+		{`count > max_retries && count / 2 == 3.5`, true},
+		{`has(db.pool) && !has(db.replicas) && !has(missing)`, true},
+		{`"team" in tags && tags.team.upper() == "CORE"`, true},
+		{`matches(mode, '^rel') && size("héllo") == 5 && lower(trim("  A ")) == "a"`, true},
+		{`[1, 2] + [3] == [1, 2, 3] && "a" + "b" == "ab" && db.hosts[1] == "b"`, true},
+		{`mode != "release" || false`, false},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src, nil)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", tt.src, err)
+			continue
+		}
+		got, err := p.Eval(vars)
+		if err != nil {
+			t.Errorf("Eval(%q) error = %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vars := map[string]interface{}{"n": float64(1), "list": []interface{}{}, "s": "x"}
+	tests := []struct{ src, want string }{
+		{`missing > 1`, "no such field 'missing'"},
+		{`n / 0 == 1`, "division by zero"},
+		{`list[0] == 1`, "index 0 out of range"},
+		{`s.x == 1`, "cannot select field 'x' from string"},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src, nil)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", tt.src, err)
+		}
+		if _, err := p.Eval(vars); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	vars := map[string]Type{"name": String, "port": Number, "on": Bool, "extra": Dyn}
+	tests := []struct{ src, want string }{
+		{`port >`, "column 7: unexpected end of expression"},
+		{`port > 1 )`, "column 10: unexpected ')'"},
+		{`"open`, "unterminated string"},
+		{`port # 1`, "unexpected character '#'"},
+		{`nmae == "x"`, "column 1: undeclared reference to 'nmae'"},
+		{`name > 1`, "operator '>' cannot be applied to string and number"},
+		{`port + 1`, "expression must be a bool, got number"},
+		{`size(port) > 0`, "size needs a string, list or map, got number"},
+		{`startsWith(name)`, "startsWith expects 2 argument(s), got 1"},
+		{`name.trimPrefix("a") == ""`, "unknown function 'trimPrefix'"},
+		{`all(port, it > 1)`, "all needs a list or map, got number"},
+		{`matches(name, "(")`, "invalid regular expression"},
+		{`has(port + 1)`, "has needs a field"},
+		{`on.enabled`, "cannot select field 'enabled' from bool"},
+		{strings.Repeat("(", MaxDepth+1) + "on" + strings.Repeat(")", MaxDepth+1), "nested deeper than"},
+		{strings.Repeat("x", MaxLength+1), "longer than"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src, vars)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+
+	p, err := Compile(`extra.a > port && on`, vars)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if got := strings.Join(p.Fields(), ","); got != "extra,on,port" {
+		t.Errorf("Fields() = %s, want extra,on,port", got)
+	}
+}
+
+func TestEvalCostLimit(t *testing.T) {
+	list := make([]interface{}, 1000)
+	for i := range list {
+		list[i] = float64(i)
+	}
+	p, err := Compile(`all(xs, all(xs, it >= 0))`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Eval(map[string]interface{}{"xs": list}); err == nil || !strings.Contains(err.Error(), "cost limit") {
+		t.Errorf("Eval() error = %v, want the cost limit", err)
+	}
+}
+
+func TestEvalCostBoundsLargeInputs(t *testing.T) {
+	list := make([]interface{}, 20000)
+	for i := range list {
+		list[i] = float64(i)
+	}
+	vars := map[string]interface{}{"s": strings.Repeat("x", 1<<20), "l": list, "p": "^x+$"}
+
+	tests := []struct{ src, want string }{
+		{`all(l, size(s+s+s+s+s+s+s+s) > 0)`, "longer than"},
+		{`all(l, size(s) > 0)`, "cost limit"},
+		{`all(l, contains(s, "y") || true)`, "cost limit"},
+		{`all(l, matches(s, p))`, "cost limit"},
+		{`all(l, lower(s) != "")`, "cost limit"},
+		{`all(l, l == l)`, "cost limit"},
+		{`all(l, it in l)`, "cost limit"},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src, nil)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", tt.src, err)
+		}
+		start := time.Now()
+		_, err = p.Eval(vars)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Eval(%q) ran for %v before failing", tt.src, d)
+		}
+	}
+
+	// Within the budget, large inputs still evaluate.
+	p, err := Compile(`size(l) == 20000 && matches(p, "^\\^") && size("a" + p) == 5`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := p.Eval(vars); !ok || err != nil {
+		t.Errorf("Eval() = %v, %v", ok, err)
+	}
+}
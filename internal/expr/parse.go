@@ -0,0 +1,411 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Syntax tree nodes. Every node records its column for error messages.
+type (
+	node interface{ pos() int }
+
+	litNode struct {
+		at  int
+		val interface{} // float64, string, bool or nil
+	}
+	identNode struct {
+		at   int
+		name string
+	}
+	memberNode struct {
+		at   int
+		x    node
+		name string
+	}
+	indexNode struct {
+		at     int
+		x, idx node
+	}
+	unaryNode struct {
+		at int
+		op string
+		x  node
+	}
+	binaryNode struct {
+		at   int
+		op   string
+		l, r node
+	}
+	condNode struct {
+		at      int
+		c, t, f node
+	}
+	callNode struct {
+		at   int
+		fn   string
+		args []node
+		re   *regexp.Regexp // Literal pattern of matches, compiled by the checker
+	}
+	listNode struct {
+		at    int
+		elems []node
+	}
+)
+
+func (n *litNode) pos() int    { return n.at }
+func (n *identNode) pos() int  { return n.at }
+func (n *memberNode) pos() int { return n.at }
+func (n *indexNode) pos() int  { return n.at }
+func (n *unaryNode) pos() int  { return n.at }
+func (n *binaryNode) pos() int { return n.at }
+func (n *condNode) pos() int   { return n.at }
+func (n *callNode) pos() int   { return n.at }
+func (n *listNode) pos() int   { return n.at }
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string      // Identifier or punctuation
+	val  interface{} // Decoded number or string literal
+	at   int
+}
+
+// lex splits src into tokens.
+func lex(src string) ([]token, error) {
+	var toks []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		at := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E' ||
+				((runes[j] == '+' || runes[j] == '-') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			f, err := strconv.ParseFloat(string(runes[i:j]), 64)
+			if err != nil {
+				return nil, &Error{Pos: at, Msg: fmt.Sprintf("invalid number %q", string(runes[i:j]))}
+			}
+			toks = append(toks, token{kind: tokNumber, val: f, at: at})
+			i = j
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] != '\\' {
+					b.WriteRune(runes[j])
+					continue
+				}
+				j++
+				if j == len(runes) {
+					break
+				}
+				switch runes[j] {
+				case 'n':
+					b.WriteRune('\n')
+				case 't':
+					b.WriteRune('\t')
+				case '\\', '"', '\'':
+					b.WriteRune(runes[j])
+				default:
+					return nil, &Error{Pos: j, Msg: fmt.Sprintf("unknown escape \\%c", runes[j])}
+				}
+			}
+			if j >= len(runes) {
+				return nil, &Error{Pos: at, Msg: "unterminated string"}
+			}
+			toks = append(toks, token{kind: tokString, val: b.String(), at: at})
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(runes[i:j]), at: at})
+			i = j
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "&&", "||", "==", "!=", "<=", ">=":
+				toks = append(toks, token{kind: tokPunct, text: two, at: at})
+				i += 2
+				continue
+			}
+			if !strings.ContainsRune("()[],.?:!<>+-*/%", r) {
+				return nil, &Error{Pos: at, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+			toks = append(toks, token{kind: tokPunct, text: string(r), at: at})
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, at: len(runes) + 1}), nil
+}
+
+type parser struct {
+	toks  []token
+	i     int
+	depth int
+}
+
+func parse(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Pos: t.at, Msg: fmt.Sprintf("unexpected %s", describeToken(t))}
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the punctuation or keyword s.
+func (p *parser) accept(s string) bool {
+	t := p.peek()
+	if (t.kind == tokPunct || t.kind == tokIdent) && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if p.accept(s) {
+		return nil
+	}
+	t := p.peek()
+	return &Error{Pos: t.at, Msg: fmt.Sprintf("expected '%s', found %s", s, describeToken(t))}
+}
+
+func describeToken(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokNumber, tokString:
+		return fmt.Sprintf("literal %v", t.val)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+// expr parses a conditional: or ('?' expr ':' expr)?
+func (p *parser) expr() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxDepth {
+		return nil, &Error{Pos: p.peek().at, Msg: fmt.Sprintf("expression is nested deeper than %d levels", MaxDepth)}
+	}
+
+	c, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	at := p.peek().at
+	if !p.accept("?") {
+		return c, nil
+	}
+	t, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{at: at, c: c, t: t, f: f}, nil
+}
+
+// Binary operators by precedence level, lowest first.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	l, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := ""
+		for _, candidate := range binaryLevels[level] {
+			if (t.kind == tokPunct || t.kind == tokIdent) && t.text == candidate {
+				op = candidate
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{at: t.at, op: op, l: l, r: r}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.peek()
+	if t.kind == tokPunct && (t.text == "!" || t.text == "-") {
+		p.next()
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > MaxDepth {
+			return nil, &Error{Pos: t.at, Msg: fmt.Sprintf("expression is nested deeper than %d levels", MaxDepth)}
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{at: t.at, op: t.text, x: x}, nil
+	}
+	return p.postfix()
+}
+
+// postfix parses a primary followed by member access, indexing and method
+// calls; x.f(args) is sugar for f(x, args).
+func (p *parser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, &Error{Pos: name.at, Msg: fmt.Sprintf("expected a field name, found %s", describeToken(name))}
+			}
+			if p.peek().text == "(" && p.peek().kind == tokPunct {
+				p.next()
+				args, err := p.args(")")
+				if err != nil {
+					return nil, err
+				}
+				x = &callNode{at: name.at, fn: name.text, args: append([]node{x}, args...)}
+			} else {
+				x = &memberNode{at: name.at, x: x, name: name.text}
+			}
+		case p.accept("["):
+			idx, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{at: t.at, x: x, idx: idx}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber, tokString:
+		return &litNode{at: t.at, val: t.val}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &litNode{at: t.at, val: true}, nil
+		case "false":
+			return &litNode{at: t.at, val: false}, nil
+		case "null":
+			return &litNode{at: t.at, val: nil}, nil
+		case "in":
+			return nil, &Error{Pos: t.at, Msg: "unexpected 'in'"}
+		}
+		if p.accept("(") {
+			args, err := p.args(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{at: t.at, fn: t.text, args: args}, nil
+		}
+		return &identNode{at: t.at, name: t.text}, nil
+	case tokPunct:
+		switch t.text {
+		case "(":
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			elems, err := p.args("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{at: t.at, elems: elems}, nil
+		}
+	}
+	return nil, &Error{Pos: t.at, Msg: fmt.Sprintf("unexpected %s", describeToken(t))}
+}
+
+// args parses a comma-separated list of expressions up to the closing token.
+func (p *parser) args(closing string) ([]node, error) {
+	var out []node
+	if p.accept(closing) {
+		return out, nil
+	}
+	for {
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, x)
+		if p.accept(closing) {
+			return out, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package featureflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+	"github.com/clyvecute/configra/pkg/utils"
+)
+
+// MaxEvaluationCounts caps the counts accepted in one report.
+const MaxEvaluationCounts = 10000
+
+// EvaluationFlushInterval is how often the counts of server-side evaluations
+// are written to the database.
+const EvaluationFlushInterval = 10 * time.Second
+
+// maxClockSkew is how far ahead of the server's clock a reported hour may
+// start.
+const maxClockSkew = 5 * time.Minute
+
+// Defaults of the stale flag report.
+const (
+	DefaultUnusedDays = 30 // A flag nobody evaluated for this long is unused
+	DefaultSingleDays = 14 // A flag serving one variation for this long is settled
+)
+
+// Stale flag report reasons.
+const (
+	StaleUnused          = "unused"
+	StaleSingleVariation = "single_variation"
+)
+
+// StaleFlag is a flag the stale flag report suggests cleaning up.
+type StaleFlag struct {
+	Flag   string `json:"flag"`
+	Reason string `json:"reason"` // StaleUnused or StaleSingleVariation
+	On     bool   `json:"on"`
+	// LastEvaluated is the hour of the last recorded evaluation, if any.
+	LastEvaluated *time.Time `json:"last_evaluated,omitempty"`
+	// Variation is the only variation served, with how often, in the window.
+	Variation   string    `json:"variation,omitempty"`
+	Evaluations int64     `json:"evaluations,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"` // Last change to the flag
+}
+
+// variationActivity sums the evaluations of one flag variation.
+type variationActivity struct {
+	Flag      string
+	Variation string
+	Count     int64     // Since the start of the window
+	Last      time.Time // Last hour with an evaluation, ever
+}
+
+// RecordEvaluations adds hourly evaluation counts of an environment.
+func (r *Repository) RecordEvaluations(projectID, envID int, counts []flags.EvaluationCount) error {
+	if r.db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range counts {
+		_, err := tx.Exec(`
+			INSERT INTO flag_evaluations (project_id, environment_id, flag_key, variation, hour, count)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (project_id, environment_id, flag_key, variation, hour) DO UPDATE
+				SET count = flag_evaluations.count + EXCLUDED.count`,
+			projectID, envID, c.Flag, c.Variation, c.Hour.UTC().Truncate(time.Hour), c.Count)
+		if err != nil {
+			return fmt.Errorf("failed to record evaluations: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListEvaluations returns the hourly counts of a flag since a time, oldest
+// first.
+func (r *Repository) ListEvaluations(projectID, envID int, key string, since time.Time) ([]flags.EvaluationCount, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	rows, err := r.db.Query(`
+		SELECT flag_key, variation, hour, count
+		FROM flag_evaluations
+		WHERE project_id = $1 AND environment_id = $2 AND flag_key = $3 AND hour >= $4
+		ORDER BY hour, variation`, projectID, envID, key, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []flags.EvaluationCount{}
+	for rows.Next() {
+		var c flags.EvaluationCount
+		if err := rows.Scan(&c.Flag, &c.Variation, &c.Hour, &c.Count); err != nil {
+			return nil, err
+		}
+		c.Hour = c.Hour.UTC()
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// evaluationActivity sums the evaluations of every flag variation of an
+// environment since a time, with the last hour each was evaluated at all.
+func (r *Repository) evaluationActivity(projectID, envID int, since time.Time) ([]variationActivity, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	rows, err := r.db.Query(`
+		SELECT flag_key, variation, COALESCE(SUM(count) FILTER (WHERE hour >= $3), 0), MAX(hour)
+		FROM flag_evaluations
+		WHERE project_id = $1 AND environment_id = $2
+		GROUP BY flag_key, variation`, projectID, envID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []variationActivity{}
+	for rows.Next() {
+		var a variationActivity
+		if err := rows.Scan(&a.Flag, &a.Variation, &a.Count, &a.Last); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// RecordEvaluations stores evaluation counts an SDK collected while
+// evaluating flags locally. Counts of flags the environment does not have,
+// e.g. because they were deleted since, are skipped and their keys returned;
+// counts of hours that have not started yet reject the whole report.
+func (s *Service) RecordEvaluations(projectID, envID int, counts []flags.EvaluationCount) ([]string, error) {
+	list, err := s.repo.List(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(list))
+	for _, f := range list {
+		known[f.Key] = true
+	}
+	if errs := countErrors(counts, time.Now()); len(errs) > 0 {
+		return nil, &ValidationError{Kind: "evaluation counts", Errors: errs}
+	}
+	kept, unknown := knownCounts(counts, known)
+	if len(kept) == 0 {
+		return unknown, nil
+	}
+	return unknown, s.repo.RecordEvaluations(projectID, envID, kept)
+}
+
+// countErrors lists the counts that are for an hour after now.
+func countErrors(counts []flags.EvaluationCount, now time.Time) []string {
+	var errs []string
+	for _, c := range counts {
+		if c.Hour.After(now.Add(maxClockSkew)) {
+			errs = append(errs, fmt.Sprintf("counts of flag '%s' are for %s, which has not started", c.Flag, c.Hour.UTC().Format(time.RFC3339)))
+		}
+	}
+	return errs
+}
+
+// knownCounts splits counts into those of known flags and the keys of the
+// unknown flags, in order of first appearance.
+func knownCounts(counts []flags.EvaluationCount, known map[string]bool) (kept []flags.EvaluationCount, unknown []string) {
+	seen := map[string]bool{}
+	for _, c := range counts {
+		if known[c.Flag] {
+			kept = append(kept, c)
+			continue
+		}
+		if !seen[c.Flag] {
+			seen[c.Flag] = true
+			unknown = append(unknown, c.Flag)
+		}
+	}
+	return kept, unknown
+}
+
+// ListEvaluations returns the hourly counts of a flag over the last days.
+func (s *Service) ListEvaluations(projectID, envID int, key string, days int) ([]flags.EvaluationCount, error) {
+	return s.repo.ListEvaluations(projectID, envID, key, time.Now().AddDate(0, 0, -days))
+}
+
+// evaluationCounts buffers the counts of server-side evaluations per
+// environment between flushes, so evaluating never waits on the database.
+type evaluationCounts struct {
+	mu   sync.Mutex
+	envs map[envScope]*flags.Counter
+}
+
+type envScope struct{ projectID, envID int }
+
+func (e *evaluationCounts) counter(projectID, envID int) *flags.Counter {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.envs == nil {
+		e.envs = map[envScope]*flags.Counter{}
+	}
+	k := envScope{projectID, envID}
+	c := e.envs[k]
+	if c == nil {
+		c = &flags.Counter{}
+		e.envs[k] = c
+	}
+	return c
+}
+
+// drain returns the counters collected so far and starts new ones.
+func (e *evaluationCounts) drain() map[envScope]*flags.Counter {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	envs := e.envs
+	e.envs = nil
+	return envs
+}
+
+// recordResults counts server-side evaluations, for flushEvaluations to
+// store.
+func (s *Service) recordResults(projectID, envID int, results ...flags.Result) {
+	c := s.counts.counter(projectID, envID)
+	now := time.Now()
+	for _, res := range results {
+		c.Add(res, now)
+	}
+}
+
+// flushEvaluations stores the counts of server-side evaluations every
+// interval until stop is closed. Failing to is not worth keeping them around
+// for: counts that could not be stored are dropped.
+func (s *Service) flushEvaluations(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.storeEvaluations()
+		}
+	}
+}
+
+func (s *Service) storeEvaluations() {
+	for scope, c := range s.counts.drain() {
+		if err := s.repo.RecordEvaluations(scope.projectID, scope.envID, c.Drain()); err != nil {
+			fmt.Printf("Flag evaluation tracking failed (skipped): %v\n", err)
+		}
+	}
+}
+
+// StaleFlags reports the flags of an environment that look ready for
+// cleanup: those not evaluated for unusedDays, and those that served a
+// single variation to every evaluation, without being changed, for
+// singleDays.
+func (s *Service) StaleFlags(projectID, envID, unusedDays, singleDays int) ([]StaleFlag, error) {
+	list, err := s.repo.List(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	unusedSince, singleSince := now.AddDate(0, 0, -unusedDays), now.AddDate(0, 0, -singleDays)
+	activity, err := s.repo.evaluationActivity(projectID, envID, singleSince)
+	if err != nil {
+		return nil, err
+	}
+	return staleFlags(list, activity, unusedSince, singleSince), nil
+}
+
+func staleFlags(list []Flag, activity []variationActivity, unusedSince, singleSince time.Time) []StaleFlag {
+	byFlag := map[string][]variationActivity{}
+	for _, a := range activity {
+		byFlag[a.Flag] = append(byFlag[a.Flag], a)
+	}
+
+	stale := []StaleFlag{}
+	for _, f := range list {
+		var last *time.Time
+		var served []variationActivity
+		for _, a := range byFlag[f.Key] {
+			if last == nil || a.Last.After(*last) {
+				t := a.Last
+				last = &t
+			}
+			// Failed evaluations still show the flag is used, but serve nothing
+			if a.Count > 0 && a.Variation != "" {
+				served = append(served, a)
+			}
+		}
+
+		sf := StaleFlag{Flag: f.Key, On: f.On, LastEvaluated: last, UpdatedAt: f.UpdatedAt}
+		switch {
+		case f.CreatedAt.Before(unusedSince) && (last == nil || last.Before(unusedSince)):
+			sf.Reason = StaleUnused
+		case f.UpdatedAt.Before(singleSince) && len(served) == 1:
+			sf.Reason = StaleSingleVariation
+			sf.Variation, sf.Evaluations = served[0].Variation, served[0].Count
+		default:
+			continue
+		}
+		stale = append(stale, sf)
+	}
+	// Unused flags first, each group by key
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].Reason == StaleUnused && stale[j].Reason != StaleUnused })
+	return stale
+}
+
+type RecordEvaluationsRequest struct {
+	EnvID  int                     `json:"env_id"`
+	Env    string                  `json:"env,omitempty"` // Slug alternative to env_id
+	Counts []flags.EvaluationCount `json:"counts"`
+}
+
+// RecordEvaluations stores the evaluation counts an SDK collected while
+// evaluating flags locally.
+func (h *Handler) RecordEvaluations(w http.ResponseWriter, r *http.Request) {
+	var req RecordEvaluationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if (req.EnvID == 0 && req.Env == "") || len(req.Counts) == 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+	if len(req.Counts) > MaxEvaluationCounts {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("too many counts (max %d)", MaxEvaluationCounts)})
+		return
+	}
+	for _, c := range req.Counts {
+		if c.Flag == "" || c.Count <= 0 || c.Hour.IsZero() {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "counts need a flag, an hour and a positive count"})
+			return
+		}
+	}
+
+	projectID, envID, ok := h.scope(w, r, req.EnvID, req.Env)
+	if !ok {
+		return
+	}
+	unknown, err := h.service.RecordEvaluations(projectID, envID, req.Counts)
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+	resp := map[string]interface{}{"status": "recorded"}
+	if len(unknown) > 0 {
+		resp["unknown_flags"] = unknown // Their counts were skipped
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// Evaluations returns the hourly evaluation counts of the flag named by the
+// path, over the last 7 days or the number given by the days parameter.
+func (h *Handler) Evaluations(w http.ResponseWriter, r *http.Request) {
+	days, ok := daysParam(w, r, "days", 7)
+	if !ok {
+		return
+	}
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	counts, err := h.service.ListEvaluations(projectID, envID, r.PathValue("key"), days)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, counts)
+}
+
+// StaleFlags reports the flags of the environment given by env (or env_id)
+// that look ready for cleanup. unused_days and single_days override the
+// defaults.
+func (h *Handler) StaleFlags(w http.ResponseWriter, r *http.Request) {
+	unusedDays, ok := daysParam(w, r, "unused_days", DefaultUnusedDays)
+	if !ok {
+		return
+	}
+	singleDays, ok := daysParam(w, r, "single_days", DefaultSingleDays)
+	if !ok {
+		return
+	}
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	stale, err := h.service.StaleFlags(projectID, envID, unusedDays, singleDays)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, stale)
+}
+
+// daysParam reads a positive number of days from the query, or def when it
+// is absent. On failure it writes the error response and returns false.
+func daysParam(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days <= 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + name})
+		return 0, false
+	}
+	return days, true
+}
@@ -0,0 +1,107 @@
+package featureflags
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+func TestStaleFlags(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	flag := func(key string, created, updated int) Flag {
+		return Flag{Flag: flags.Flag{Key: key, On: true}, CreatedAt: daysAgo(created), UpdatedAt: daysAgo(updated)}
+	}
+
+	list := []Flag{
+		flag("abandoned", 90, 60),        // Last evaluated 40 days ago
+		flag("brand-new", 2, 2),          // Never evaluated, but too new to tell
+		flag("busy", 90, 60),             // Serves two variations
+		flag("fully-rolled-out", 90, 20), // Serves "on" only
+		flag("just-changed", 90, 3),      // Serves "on" only, but changed since
+		flag("never-evaluated", 90, 90),
+		flag("only-errors", 90, 60), // Fails to evaluate, but is checked
+	}
+	activity := []variationActivity{
+		{Flag: "abandoned", Variation: "on", Count: 0, Last: daysAgo(40)},
+		{Flag: "busy", Variation: "on", Count: 50, Last: daysAgo(0)},
+		{Flag: "busy", Variation: "off", Count: 50, Last: daysAgo(0)},
+		{Flag: "fully-rolled-out", Variation: "on", Count: 1200, Last: daysAgo(0)},
+		{Flag: "fully-rolled-out", Variation: "off", Count: 0, Last: daysAgo(25)},
+		{Flag: "just-changed", Variation: "on", Count: 300, Last: daysAgo(0)},
+		{Flag: "only-errors", Variation: "", Count: 10, Last: daysAgo(1)},
+	}
+
+	stale := staleFlags(list, activity, daysAgo(DefaultUnusedDays), daysAgo(DefaultSingleDays))
+	want := []struct {
+		flag, reason, variation string
+	}{
+		{"abandoned", StaleUnused, ""},
+		{"never-evaluated", StaleUnused, ""},
+		{"fully-rolled-out", StaleSingleVariation, "on"},
+	}
+	if len(stale) != len(want) {
+		t.Fatalf("staleFlags() = %+v, want %d flags", stale, len(want))
+	}
+	for i, w := range want {
+		got := stale[i]
+		if got.Flag != w.flag || got.Reason != w.reason || got.Variation != w.variation {
+			t.Errorf("stale[%d] = %+v, want %s (%s %s)", i, got, w.flag, w.reason, w.variation)
+		}
+	}
+	if last := stale[0].LastEvaluated; last == nil || !last.Equal(daysAgo(40)) {
+		t.Errorf("abandoned last evaluated %v, want %v", last, daysAgo(40))
+	}
+	if stale[1].LastEvaluated != nil {
+		t.Errorf("never-evaluated last evaluated %v", stale[1].LastEvaluated)
+	}
+	if stale[2].Evaluations != 1200 {
+		t.Errorf("fully-rolled-out evaluations = %d, want 1200", stale[2].Evaluations)
+	}
+}
+
+func TestCountErrors(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+	hour := now.Truncate(time.Hour)
+	counts := []flags.EvaluationCount{
+		{Flag: "checkout", Variation: "on", Hour: hour, Count: 3},
+		{Flag: "checkout", Variation: "on", Hour: hour.Add(-time.Hour), Count: 1},
+		{Flag: "gone", Variation: "on", Hour: hour, Count: 1},
+		{Flag: "gone", Variation: "off", Hour: hour, Count: 1},
+		{Flag: "checkout", Variation: "off", Hour: hour.Add(2 * time.Hour), Count: 1},
+	}
+	got := countErrors(counts, now)
+	want := []string{"counts of flag 'checkout' are for 2026-10-18T14:00:00Z, which has not started"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("countErrors() = %q, want %q", got, want)
+	}
+	if errs := countErrors(counts[:4], now); errs != nil {
+		t.Errorf("countErrors() = %q for valid counts", errs)
+	}
+
+	// A deleted flag's counts are skipped, the others kept.
+	kept, unknown := knownCounts(counts[:4], map[string]bool{"checkout": true})
+	if !reflect.DeepEqual(kept, counts[:2]) || !reflect.DeepEqual(unknown, []string{"gone"}) {
+		t.Errorf("knownCounts() = %+v, %q, want the checkout counts and 'gone'", kept, unknown)
+	}
+}
+
+func TestEvaluationCountsPerEnvironment(t *testing.T) {
+	var counts evaluationCounts
+	counts.counter(1, 1).Add(flags.Result{Key: "checkout", Variation: "on"}, time.Now())
+	counts.counter(1, 1).Add(flags.Result{Key: "checkout", Variation: "on"}, time.Now())
+	counts.counter(1, 2).Add(flags.Result{Key: "checkout", Variation: "off"}, time.Now())
+
+	envs := counts.drain()
+	if len(envs) != 2 {
+		t.Fatalf("drain() = %d environments, want 2", len(envs))
+	}
+	if got := envs[envScope{1, 1}].Drain(); len(got) != 1 || got[0].Count != 2 {
+		t.Errorf("env 1 counts = %+v, want 2 evaluations of on", got)
+	}
+	if envs := counts.drain(); len(envs) != 0 {
+		t.Errorf("second drain() = %v, want nothing", envs)
+	}
+}
@@ -0,0 +1,297 @@
+package featureflags
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+	"github.com/clyvecute/configra/pkg/utils"
+)
+
+// Experiment is a stored experiment definition.
+type Experiment struct {
+	flags.Experiment
+	ProjectID int       `json:"project_id"`
+	EnvID     int       `json:"env_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SaveExperiment creates an experiment or replaces its definition, bumping
+// its version. An experiment in a layer must fit in it (see
+// flags.Experiment.LayerErrors), or a ValidationError is returned.
+func (r *Repository) SaveExperiment(projectID, envID int, def flags.Experiment) (*Experiment, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	def.Version = 0
+	definition, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Saves into a layer take turns, so two of them can't both fit against
+	// the layer as it was before either.
+	if def.Layer != "" {
+		lock := fmt.Sprintf("experiment-layer:%d:%d:%s", projectID, envID, def.Layer)
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, lock); err != nil {
+			return nil, fmt.Errorf("failed to lock layer: %v", err)
+		}
+		list, err := listExperiments(tx, projectID, envID)
+		if err != nil {
+			return nil, err
+		}
+		others := make([]flags.Experiment, len(list))
+		for i, other := range list {
+			others[i] = other.Experiment
+		}
+		if errs := def.LayerErrors(others); len(errs) > 0 {
+			return nil, &ValidationError{Kind: "experiment", Errors: errs}
+		}
+	}
+
+	e := &Experiment{Experiment: def, ProjectID: projectID, EnvID: envID}
+	err = tx.QueryRow(`
+		INSERT INTO experiments (project_id, environment_id, key, definition)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, environment_id, key) DO UPDATE
+			SET definition = EXCLUDED.definition, version = experiments.version + 1, updated_at = NOW()
+		RETURNING version, created_at, updated_at`,
+		projectID, envID, def.Key, definition).Scan(&e.Version, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save experiment: %v", err)
+	}
+	return e, tx.Commit()
+}
+
+// GetExperiment returns an experiment, or nil if it does not exist.
+func (r *Repository) GetExperiment(projectID, envID int, key string) (*Experiment, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	row := r.db.QueryRow(`
+		SELECT definition, version, created_at, updated_at
+		FROM experiments
+		WHERE project_id = $1 AND environment_id = $2 AND key = $3`, projectID, envID, key)
+	e, err := scanExperiment(row, projectID, envID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// ListExperiments returns every experiment of an environment, ordered by key.
+func (r *Repository) ListExperiments(projectID, envID int) ([]Experiment, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	return listExperiments(r.db, projectID, envID)
+}
+
+func listExperiments(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, projectID, envID int) ([]Experiment, error) {
+	rows, err := q.Query(`
+		SELECT definition, version, created_at, updated_at
+		FROM experiments
+		WHERE project_id = $1 AND environment_id = $2
+		ORDER BY key`, projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Experiment{}
+	for rows.Next() {
+		e, err := scanExperiment(rows, projectID, envID)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+	return list, rows.Err()
+}
+
+// DeleteExperiment removes an experiment. It reports whether the experiment
+// existed.
+func (r *Repository) DeleteExperiment(projectID, envID int, key string) (bool, error) {
+	if r.db == nil {
+		return false, fmt.Errorf("database connection unavailable")
+	}
+	res, err := r.db.Exec(`DELETE FROM experiments WHERE project_id = $1 AND environment_id = $2 AND key = $3`, projectID, envID, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete experiment: %v", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanExperiment(row interface{ Scan(...interface{}) error }, projectID, envID int) (*Experiment, error) {
+	e := &Experiment{ProjectID: projectID, EnvID: envID}
+	var definition []byte
+	if err := row.Scan(&definition, &e.Version, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	version := e.Version
+	if err := json.Unmarshal(definition, &e.Experiment); err != nil {
+		return nil, fmt.Errorf("corrupt definition of experiment: %v", err)
+	}
+	e.Version = version
+	return e, nil
+}
+
+// SaveExperiment validates an experiment definition and stores it as the
+// experiment's next version. The repository checks that it fits in its layer
+// in the same transaction.
+func (s *Service) SaveExperiment(projectID, envID int, def flags.Experiment) (*Experiment, error) {
+	if errs := def.Validate(); len(errs) > 0 {
+		return nil, &ValidationError{Kind: "experiment", Errors: errs}
+	}
+	e, err := s.repo.SaveExperiment(projectID, envID, def)
+	if err == nil {
+		s.changes.notify(projectID, envID)
+	}
+	return e, err
+}
+
+func (s *Service) GetExperiment(projectID, envID int, key string) (*Experiment, error) {
+	return s.repo.GetExperiment(projectID, envID, key)
+}
+
+func (s *Service) ListExperiments(projectID, envID int) ([]Experiment, error) {
+	return s.repo.ListExperiments(projectID, envID)
+}
+
+func (s *Service) DeleteExperiment(projectID, envID int, key string) (bool, error) {
+	found, err := s.repo.DeleteExperiment(projectID, envID, key)
+	if found {
+		s.changes.notify(projectID, envID)
+	}
+	return found, err
+}
+
+// Assign assigns ctx to an arm of an experiment. It returns nil if the
+// experiment does not exist.
+func (s *Service) Assign(projectID, envID int, key string, ctx flags.Context) (*flags.Assignment, error) {
+	e, err := s.repo.GetExperiment(projectID, envID, key)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	a := e.Assign(ctx)
+	return &a, nil
+}
+
+type SaveExperimentRequest struct {
+	EnvID      int              `json:"env_id"`
+	Env        string           `json:"env,omitempty"` // Slug alternative to env_id
+	Experiment flags.Experiment `json:"experiment"`
+}
+
+// SaveExperiment creates or replaces an experiment in an environment.
+// Invalid definitions, and experiments conflicting with another of the
+// layer, are rejected with a 400 listing every problem.
+func (h *Handler) SaveExperiment(w http.ResponseWriter, r *http.Request) {
+	var req SaveExperimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.EnvID == 0 && req.Env == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+
+	projectID, envID, ok := h.scope(w, r, req.EnvID, req.Env)
+	if !ok {
+		return
+	}
+
+	e, err := h.service.SaveExperiment(projectID, envID, req.Experiment)
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, e)
+}
+
+// ListExperiments returns every experiment of the environment given by env
+// (or env_id).
+func (h *Handler) ListExperiments(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.service.ListExperiments(projectID, envID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetExperiment returns the experiment named by the path in the environment
+// given by env (or env_id).
+func (h *Handler) GetExperiment(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.service.GetExperiment(projectID, envID, r.PathValue("key"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if e == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "experiment not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, e)
+}
+
+// DeleteExperiment removes the experiment named by the path from the
+// environment given by env (or env_id).
+func (h *Handler) DeleteExperiment(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.service.DeleteExperiment(projectID, envID, r.PathValue("key"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !found {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "experiment not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// Assign returns the arm of the experiment named by the path that the
+// request's evaluation context is assigned to, if any. Exposure events are
+// left to the caller.
+func (h *Handler) Assign(w http.ResponseWriter, r *http.Request) {
+	req, projectID, envID, ok := h.evaluateRequest(w, r)
+	if !ok {
+		return
+	}
+	a, err := h.service.Assign(projectID, envID, r.PathValue("key"), req.Context)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if a == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "experiment not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, a)
+}
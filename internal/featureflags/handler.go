@@ -0,0 +1,327 @@
+package featureflags
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/clyvecute/configra/internal/middleware"
+	"github.com/clyvecute/configra/pkg/flags"
+	"github.com/clyvecute/configra/pkg/utils"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+type SaveRequest struct {
+	EnvID int        `json:"env_id"`
+	Env   string     `json:"env,omitempty"` // Slug alternative to env_id
+	Flag  flags.Flag `json:"flag"`
+}
+
+// Save creates or replaces a flag in an environment. Invalid definitions are
+// rejected with a 400 listing every problem.
+func (h *Handler) Save(w http.ResponseWriter, r *http.Request) {
+	var req SaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.EnvID == 0 && req.Env == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return
+	}
+
+	projectID, envID, ok := h.scope(w, r, req.EnvID, req.Env)
+	if !ok {
+		return
+	}
+
+	f, err := h.service.SaveFlag(projectID, envID, req.Flag)
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, f)
+}
+
+// List returns every flag of the environment given by env (or env_id).
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.service.ListFlags(projectID, envID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// Get returns the flag named by the path in the environment given by env
+// (or env_id).
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	f, err := h.service.GetFlag(projectID, envID, r.PathValue("key"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if f == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "flag not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, f)
+}
+
+// Delete removes the flag named by the path from the environment given by
+// env (or env_id).
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.service.DeleteFlag(projectID, envID, r.PathValue("key"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !found {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "flag not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+type EvaluateRequest struct {
+	EnvID   int           `json:"env_id"`
+	Env     string        `json:"env,omitempty"` // Slug alternative to env_id
+	Context flags.Context `json:"context"`
+}
+
+// Evaluate returns the variation the flag named by the path serves to the
+// request's evaluation context, and why.
+func (h *Handler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	req, projectID, envID, ok := h.evaluateRequest(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.Evaluate(projectID, envID, r.PathValue("key"), req.Context)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if res == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "flag not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// EvaluateAll evaluates every flag of the environment for the request's
+// evaluation context.
+func (h *Handler) EvaluateAll(w http.ResponseWriter, r *http.Request) {
+	req, projectID, envID, ok := h.evaluateRequest(w, r)
+	if !ok {
+		return
+	}
+	results, err := h.service.EvaluateAll(projectID, envID, req.Context)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, results)
+}
+
+type SaveSegmentRequest struct {
+	Segment flags.Segment `json:"segment"`
+}
+
+// SaveSegment stores the next version of a project-level segment. Flags
+// referring to it pick up the change immediately; check SegmentUsage first.
+func (h *Handler) SaveSegment(w http.ResponseWriter, r *http.Request) {
+	var req SaveSegmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	projectID, ok := projectScope(w, r)
+	if !ok {
+		return
+	}
+
+	seg, err := h.service.SaveSegment(projectID, req.Segment)
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, seg)
+}
+
+// ListSegments returns the latest version of every segment in the project.
+func (h *Handler) ListSegments(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectScope(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.service.ListSegments(projectID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetSegment returns a segment: the latest version, or the one given by the
+// version query parameter.
+func (h *Handler) GetSegment(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if r.URL.Query().Get("version") != "" && (err != nil || version <= 0) {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid version"})
+		return
+	}
+	projectID, ok := projectScope(w, r)
+	if !ok {
+		return
+	}
+
+	seg, err := h.service.GetSegment(projectID, r.PathValue("key"), version)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if seg == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "segment not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, seg)
+}
+
+// SegmentUsage lists the flags, in every environment, whose rules refer to
+// the segment named by the path.
+func (h *Handler) SegmentUsage(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectScope(w, r)
+	if !ok {
+		return
+	}
+	usage, err := h.service.SegmentUsage(projectID, r.PathValue("key"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, usage)
+}
+
+// DeleteSegment removes a segment. A segment still used by flags is a 409
+// listing them.
+func (h *Handler) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectScope(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.service.DeleteSegment(projectID, r.PathValue("key"))
+	if err != nil {
+		var inUse *SegmentInUseError
+		if errors.As(err, &inUse) {
+			utils.WriteJSON(w, http.StatusConflict, map[string]interface{}{
+				"error": err.Error(),
+				"flags": inUse.Usage,
+			})
+			return
+		}
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !found {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "segment not found"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// writeSaveError reports a failed flag or segment write: invalid definitions
+// are a 400 listing every problem, anything else a 500.
+func writeSaveError(w http.ResponseWriter, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  err.Error(),
+			"errors": validationErr.Errors,
+		})
+		return
+	}
+	utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+func (h *Handler) evaluateRequest(w http.ResponseWriter, r *http.Request) (EvaluateRequest, int, int, bool) {
+	var req EvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return req, 0, 0, false
+	}
+	if req.EnvID == 0 && req.Env == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return req, 0, 0, false
+	}
+	projectID, envID, ok := h.scope(w, r, req.EnvID, req.Env)
+	return req, projectID, envID, ok
+}
+
+// queryScope is scope for requests naming the environment in the query.
+func (h *Handler) queryScope(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	q := r.URL.Query()
+	env := q.Get("env_id")
+	if env == "" {
+		env = q.Get("env")
+	}
+	if env == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+		return 0, 0, false
+	}
+	return h.scope(w, r, 0, env)
+}
+
+// scope returns the project of the request's API key and the environment
+// given by envID or, when 0, by env (an ID or a slug). On failure it writes
+// the error response and returns false.
+func (h *Handler) scope(w http.ResponseWriter, r *http.Request, envID int, env string) (int, int, bool) {
+	projectID, ok := projectScope(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+	if envID != 0 {
+		return projectID, envID, true
+	}
+
+	envID, err := h.service.ResolveEnvironment(projectID, env)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return 0, 0, false
+	}
+	if envID == 0 {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "environment not found"})
+		return 0, 0, false
+	}
+	return projectID, envID, true
+}
+
+// projectScope returns the project of the request's API key. On failure it
+// writes the error response and returns false.
+func projectScope(w http.ResponseWriter, r *http.Request) (int, bool) {
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return 0, false
+	}
+	return projectID, true
+}
@@ -0,0 +1,149 @@
+// Package featureflags stores feature flags per project and environment and
+// serves their evaluation. The flag model and evaluator live in pkg/flags.
+package featureflags
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// Flag is a stored flag definition.
+type Flag struct {
+	flags.Flag
+	ProjectID int       `json:"project_id"`
+	EnvID     int       `json:"env_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Save creates a flag or replaces its definition, bumping its version. It is
+// rejected with a *ValidationError if a segment the flag refers to does not
+// exist.
+func (r *Repository) Save(projectID, envID int, def flags.Flag) (*Flag, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	def.Version = 0
+	definition, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the segments the flag refers to, so none is deleted before the
+	// flag is saved.
+	var unknown []string
+	for _, key := range def.SegmentKeys() {
+		var segmentID int
+		err := tx.QueryRow(`SELECT id FROM segments WHERE project_id = $1 AND key = $2 FOR SHARE`, projectID, key).Scan(&segmentID)
+		if err == sql.ErrNoRows {
+			unknown = append(unknown, fmt.Sprintf("unknown segment '%s'", key))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock segment: %v", err)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &ValidationError{Kind: "flag", Errors: unknown}
+	}
+
+	f := &Flag{Flag: def, ProjectID: projectID, EnvID: envID}
+	err = tx.QueryRow(`
+		INSERT INTO flags (project_id, environment_id, key, definition)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, environment_id, key) DO UPDATE
+			SET definition = EXCLUDED.definition, version = flags.version + 1, updated_at = NOW()
+		RETURNING version, created_at, updated_at`,
+		projectID, envID, def.Key, definition).Scan(&f.Version, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save flag: %v", err)
+	}
+	return f, tx.Commit()
+}
+
+// Get returns a flag, or nil if it does not exist.
+func (r *Repository) Get(projectID, envID int, key string) (*Flag, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	row := r.db.QueryRow(`
+		SELECT definition, version, created_at, updated_at
+		FROM flags
+		WHERE project_id = $1 AND environment_id = $2 AND key = $3`, projectID, envID, key)
+	f, err := scanFlag(row, projectID, envID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// List returns every flag of an environment, ordered by key.
+func (r *Repository) List(projectID, envID int) ([]Flag, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	rows, err := r.db.Query(`
+		SELECT definition, version, created_at, updated_at
+		FROM flags
+		WHERE project_id = $1 AND environment_id = $2
+		ORDER BY key`, projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Flag{}
+	for rows.Next() {
+		f, err := scanFlag(rows, projectID, envID)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *f)
+	}
+	return list, rows.Err()
+}
+
+// Delete removes a flag. It reports whether the flag existed.
+func (r *Repository) Delete(projectID, envID int, key string) (bool, error) {
+	if r.db == nil {
+		return false, fmt.Errorf("database connection unavailable")
+	}
+	res, err := r.db.Exec(`DELETE FROM flags WHERE project_id = $1 AND environment_id = $2 AND key = $3`, projectID, envID, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete flag: %v", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanFlag(row interface{ Scan(...interface{}) error }, projectID, envID int) (*Flag, error) {
+	f := &Flag{ProjectID: projectID, EnvID: envID}
+	var definition []byte
+	if err := row.Scan(&definition, &f.Version, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		return nil, err
+	}
+	version := f.Version
+	if err := json.Unmarshal(definition, &f.Flag); err != nil {
+		return nil, fmt.Errorf("corrupt definition of flag: %v", err)
+	}
+	f.Version = version
+	return f, nil
+}
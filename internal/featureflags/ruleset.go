@@ -0,0 +1,276 @@
+package featureflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+	"github.com/clyvecute/configra/pkg/utils"
+)
+
+// RulesetPollInterval is how often the ruleset of an environment with open
+// streams is reloaded, and how often streams get a keep-alive. Changes made
+// through this instance are pushed right away; the poll catches changes made
+// through other instances.
+var RulesetPollInterval = 10 * time.Second
+
+// broker keeps the ruleset of every environment with open streams and hands
+// it to them. Each ruleset is loaded once per change or poll, however many
+// streams are open.
+type broker struct {
+	load func(projectID, envID int) (*flags.Ruleset, error)
+
+	mu    sync.Mutex
+	feeds map[envScope]*feed
+}
+
+// feed is the ruleset of one environment and the streams subscribed to it.
+type feed struct {
+	current *flags.Ruleset // Last ruleset loaded, nil until the first load
+	subs    map[chan *flags.Ruleset]bool
+	wake    chan struct{} // Reload now
+	stop    chan struct{} // Closed when the last stream leaves
+}
+
+func newBroker(load func(projectID, envID int) (*flags.Ruleset, error)) *broker {
+	return &broker{load: load, feeds: map[envScope]*feed{}}
+}
+
+// subscribe returns a channel that receives the ruleset of the environment
+// whenever it changes, the ruleset loaded so far (nil if none yet), and a
+// function to unsubscribe. A stream that falls behind only gets the latest.
+func (b *broker) subscribe(projectID, envID int) (<-chan *flags.Ruleset, *flags.Ruleset, func()) {
+	k := envScope{projectID, envID}
+	ch := make(chan *flags.Ruleset, 1)
+	b.mu.Lock()
+	f := b.feeds[k]
+	if f == nil {
+		f = &feed{subs: map[chan *flags.Ruleset]bool{}, wake: make(chan struct{}, 1), stop: make(chan struct{})}
+		b.feeds[k] = f
+		go b.run(k, f)
+	}
+	f.subs[ch] = true
+	current := f.current
+	b.mu.Unlock()
+
+	return ch, current, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(f.subs, ch)
+		if len(f.subs) == 0 && b.feeds[k] == f {
+			close(f.stop)
+			delete(b.feeds, k)
+		}
+	}
+}
+
+// run reloads the ruleset of a feed after every change and every
+// RulesetPollInterval, until its last stream leaves.
+func (b *broker) run(k envScope, f *feed) {
+	ticker := time.NewTicker(RulesetPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-f.wake:
+		case <-ticker.C:
+		}
+		rs, err := b.load(k.projectID, k.envID)
+		if err != nil {
+			// The streams keep the last good ruleset.
+			fmt.Printf("Ruleset reload failed (skipped): %v\n", err)
+			continue
+		}
+		b.publish(f, rs)
+	}
+}
+
+// publish hands rs to the streams of a feed, unless they already have that
+// version.
+func (b *broker) publish(f *feed, rs *flags.Ruleset) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.publishLocked(f, rs)
+}
+
+func (b *broker) publishLocked(f *feed, rs *flags.Ruleset) {
+	if f.current != nil && f.current.Version == rs.Version {
+		return
+	}
+	f.current = rs
+	for ch := range f.subs {
+		select {
+		case ch <- rs:
+		default: // Replace the ruleset the stream has not taken yet
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- rs
+		}
+	}
+}
+
+// seed hands the ruleset a new stream loaded itself to the feed, if it has
+// none yet.
+func (b *broker) seed(projectID, envID int, rs *flags.Ruleset) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if f := b.feeds[envScope{projectID, envID}]; f != nil && f.current == nil {
+		b.publishLocked(f, rs)
+	}
+}
+
+// notify reloads the ruleset of an environment after a change to it.
+func (b *broker) notify(projectID, envID int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if f := b.feeds[envScope{projectID, envID}]; f != nil {
+		f.wakeUp()
+	}
+}
+
+// notifyProject reloads the ruleset of every environment of a project, after
+// a change to its segments.
+func (b *broker) notifyProject(projectID int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for k, f := range b.feeds {
+		if k.projectID == projectID {
+			f.wakeUp()
+		}
+	}
+}
+
+func (f *feed) wakeUp() {
+	select {
+	case f.wake <- struct{}{}:
+	default: // A reload is already pending
+	}
+}
+
+// Ruleset returns every flag and experiment of an environment and the
+// project's segments as one versioned document, for SDKs evaluating flags in
+// process.
+func (s *Service) Ruleset(projectID, envID int) (*flags.Ruleset, error) {
+	list, err := s.repo.List(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := s.repo.ListSegments(projectID)
+	if err != nil {
+		return nil, err
+	}
+	experiments, err := s.repo.ListExperiments(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := make([]flags.Flag, len(list))
+	for i, f := range list {
+		fs[i] = f.Flag
+	}
+	segs := make([]flags.Segment, len(segments))
+	for i, seg := range segments {
+		segs[i] = seg.Segment
+	}
+	exps := make([]flags.Experiment, len(experiments))
+	for i, e := range experiments {
+		exps[i] = e.Experiment
+	}
+	return flags.NewRuleset(envID, fs, segs, exps), nil
+}
+
+// Ruleset serves the ruleset of the environment given by env (or env_id).
+// Its version is the ETag, so polling clients get a 304 while nothing changed.
+func (h *Handler) Ruleset(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	rs, err := h.service.Ruleset(projectID, envID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	etag := `"` + rs.Version + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, rs)
+}
+
+// StreamRuleset streams the ruleset of the environment given by env (or
+// env_id) as server-sent events: a "ruleset" event with the whole document
+// on connect and after every change, and comment lines as keep-alives.
+func (h *Handler) StreamRuleset(w http.ResponseWriter, r *http.Request) {
+	projectID, envID, ok := h.queryScope(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	updates, rs, unsubscribe := h.service.changes.subscribe(projectID, envID)
+	defer unsubscribe()
+	if rs == nil {
+		// First stream of the environment: load the ruleset for the others too.
+		var err error
+		if rs, err = h.service.Ruleset(projectID, envID); err != nil {
+			utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		h.service.changes.seed(projectID, envID, rs)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep proxies from buffering events
+	w.WriteHeader(http.StatusOK)
+	if writeRulesetEvent(w, rs) != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(RulesetPollInterval)
+	defer ticker.Stop()
+	version := rs.Version
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case rs := <-updates:
+			if rs.Version == version {
+				continue
+			}
+			version = rs.Version
+			err = writeRulesetEvent(w, rs)
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeRulesetEvent(w http.ResponseWriter, rs *flags.Ruleset) error {
+	b, err := json.Marshal(rs)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: ruleset\nid: %s\ndata: %s\n\n", rs.Version, b)
+	return err
+}
@@ -0,0 +1,89 @@
+package featureflags
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+func TestBrokerLoadsOncePerChange(t *testing.T) {
+	defer func(d time.Duration) { RulesetPollInterval = d }(RulesetPollInterval)
+	RulesetPollInterval = time.Hour // Only reload on notify
+
+	var mu sync.Mutex
+	loads := map[envScope]int{}
+	b := newBroker(func(projectID, envID int) (*flags.Ruleset, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		k := envScope{projectID, envID}
+		loads[k]++
+		f := flags.Flag{Key: fmt.Sprintf("flag-%d", loads[k])}
+		return flags.NewRuleset(envID, []flags.Flag{f}, nil, nil), nil
+	})
+	loadsOf := func(envID int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads[envScope{1, envID}]
+	}
+	next := func(ch <-chan *flags.Ruleset) *flags.Ruleset {
+		t.Helper()
+		select {
+		case rs := <-ch:
+			return rs
+		case <-time.After(time.Second):
+			t.Fatal("no ruleset received")
+			return nil
+		}
+	}
+
+	prod, current, unsubscribe := b.subscribe(1, 1)
+	defer unsubscribe()
+	if current != nil {
+		t.Fatalf("first subscriber got %v, want nothing loaded yet", current)
+	}
+	seed := flags.NewRuleset(1, nil, nil, nil)
+	b.seed(1, 1, seed)
+	if rs := next(prod); rs.Version != seed.Version {
+		t.Fatalf("got version %s, want the seeded %s", rs.Version, seed.Version)
+	}
+
+	var streams []<-chan *flags.Ruleset
+	for i := 0; i < 5; i++ {
+		ch, current, unsubscribe := b.subscribe(1, 1)
+		defer unsubscribe()
+		if current == nil || current.Version != seed.Version {
+			t.Fatalf("subscriber %d got %v, want the loaded ruleset", i, current)
+		}
+		streams = append(streams, ch)
+	}
+	staging, _, unsubscribeStaging := b.subscribe(1, 2)
+
+	b.notify(1, 1)
+	changed := next(prod)
+	for _, ch := range streams {
+		if rs := next(ch); rs.Version != changed.Version {
+			t.Errorf("stream got version %s, want %s", rs.Version, changed.Version)
+		}
+	}
+	if loadsOf(1) != 1 || loadsOf(2) != 0 {
+		t.Errorf("loads = %v, want one of environment 1 only", loads)
+	}
+
+	b.notifyProject(1)
+	next(prod)
+	next(staging)
+	if loadsOf(1) != 2 || loadsOf(2) != 1 {
+		t.Errorf("loads = %v, want every environment reloaded once", loads)
+	}
+
+	unsubscribeStaging()
+	b.mu.Lock()
+	_, open := b.feeds[envScope{1, 2}]
+	b.mu.Unlock()
+	if open {
+		t.Error("feed of environment 2 outlived its last stream")
+	}
+}
@@ -0,0 +1,320 @@
+package featureflags
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// Segment is one version of a project's segment.
+type Segment struct {
+	flags.Segment
+	CreatedAt time.Time `json:"created_at"` // When this version was saved
+}
+
+// SegmentUsage is a flag whose rules refer to a segment.
+type SegmentUsage struct {
+	Env   string   `json:"env"`
+	EnvID int      `json:"env_id"`
+	Flag  string   `json:"flag"`
+	On    bool     `json:"on"`
+	Rules []string `json:"rules"` // IDs of the referring rules, rules[i] when unnamed
+}
+
+// SegmentInUseError rejects deleting a segment that flags still refer to.
+type SegmentInUseError struct {
+	Key   string
+	Usage []SegmentUsage
+}
+
+func (e *SegmentInUseError) Error() string {
+	return fmt.Sprintf("segment '%s' is used by %d flag(s)", e.Key, len(e.Usage))
+}
+
+// CreateSegmentVersion appends a version to a segment, creating the segment
+// on first use.
+func (r *Repository) CreateSegmentVersion(projectID int, def flags.Segment) (*Segment, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var segmentID int
+	err = tx.QueryRow(`
+		INSERT INTO segments (project_id, key)
+		VALUES ($1, $2)
+		ON CONFLICT (project_id, key) DO UPDATE SET key = EXCLUDED.key
+		RETURNING id`, projectID, def.Key).Scan(&segmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert segment: %v", err)
+	}
+
+	var currentVersion int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM segment_versions WHERE segment_id = $1`, segmentID).Scan(&currentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max segment version: %v", err)
+	}
+
+	s := &Segment{Segment: def}
+	s.Version = 0
+	definition, _ := json.Marshal(s.Segment)
+	s.Version = currentVersion + 1
+	err = tx.QueryRow(`
+		INSERT INTO segment_versions (segment_id, version, definition)
+		VALUES ($1, $2, $3)
+		RETURNING created_at`, segmentID, s.Version, definition).Scan(&s.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert segment version: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSegment returns a version of a segment, the latest one if version is 0,
+// or nil if it does not exist.
+func (r *Repository) GetSegment(projectID int, key string, version int) (*Segment, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	row := r.db.QueryRow(`
+		SELECT v.definition, v.version, v.created_at
+		FROM segments s
+		JOIN segment_versions v ON s.id = v.segment_id
+		WHERE s.project_id = $1 AND s.key = $2 AND ($3 = 0 OR v.version = $3)
+		ORDER BY v.version DESC
+		LIMIT 1`, projectID, key, version)
+	s, err := scanSegment(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListSegments returns the latest version of every segment in a project.
+func (r *Repository) ListSegments(projectID int) ([]Segment, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	rows, err := r.db.Query(`
+		SELECT DISTINCT ON (s.key) v.definition, v.version, v.created_at
+		FROM segments s
+		JOIN segment_versions v ON s.id = v.segment_id
+		WHERE s.project_id = $1
+		ORDER BY s.key, v.version DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Segment{}
+	for rows.Next() {
+		s, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// DeleteSegment removes a segment with all its versions, unless a flag
+// refers to it (a *SegmentInUseError). It reports whether the segment existed.
+func (r *Repository) DeleteSegment(projectID int, key string) (bool, error) {
+	if r.db == nil {
+		return false, fmt.Errorf("database connection unavailable")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Locking the segment waits for flags being saved with a reference to it,
+	// and keeps new ones from being saved until the delete commits.
+	var segmentID int
+	err = tx.QueryRow(`SELECT id FROM segments WHERE project_id = $1 AND key = $2 FOR UPDATE`, projectID, key).Scan(&segmentID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock segment: %v", err)
+	}
+	list, err := listProjectFlags(tx, projectID)
+	if err != nil {
+		return false, err
+	}
+	if usage := segmentUsage(list, key); len(usage) > 0 {
+		return false, &SegmentInUseError{Key: key, Usage: usage}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM segments WHERE id = $1`, segmentID); err != nil {
+		return false, fmt.Errorf("failed to delete segment: %v", err)
+	}
+	return true, tx.Commit()
+}
+
+// envFlag is a flag with the slug of its environment.
+type envFlag struct {
+	Env string
+	Flag
+}
+
+// ListProjectFlags returns the flags of every environment of a project.
+func (r *Repository) ListProjectFlags(projectID int) ([]envFlag, error) {
+	if r.db == nil {
+		return nil, fmt.Errorf("database connection unavailable")
+	}
+	return listProjectFlags(r.db, projectID)
+}
+
+// listProjectFlags runs ListProjectFlags on a database or transaction.
+func listProjectFlags(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, projectID int) ([]envFlag, error) {
+	rows, err := q.Query(`
+		SELECT e.slug, f.environment_id, f.definition, f.version, f.created_at, f.updated_at
+		FROM flags f
+		JOIN environments e ON e.id = f.environment_id
+		WHERE f.project_id = $1
+		ORDER BY e.slug, f.key`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []envFlag{}
+	for rows.Next() {
+		var ef envFlag
+		var definition []byte
+		if err := rows.Scan(&ef.Env, &ef.EnvID, &definition, &ef.Version, &ef.CreatedAt, &ef.UpdatedAt); err != nil {
+			return nil, err
+		}
+		version := ef.Version
+		if err := json.Unmarshal(definition, &ef.Flag.Flag); err != nil {
+			return nil, fmt.Errorf("corrupt definition of flag: %v", err)
+		}
+		ef.ProjectID, ef.Version = projectID, version
+		list = append(list, ef)
+	}
+	return list, rows.Err()
+}
+
+func scanSegment(row interface{ Scan(...interface{}) error }) (*Segment, error) {
+	var s Segment
+	var definition []byte
+	var version int
+	if err := row.Scan(&definition, &version, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definition, &s.Segment); err != nil {
+		return nil, fmt.Errorf("corrupt definition of segment: %v", err)
+	}
+	s.Version = version
+	return &s, nil
+}
+
+// SaveSegment validates a segment definition and stores it as the segment's
+// next version. Flags referring to it use the new version right away.
+func (s *Service) SaveSegment(projectID int, def flags.Segment) (*Segment, error) {
+	if errs := def.Validate(); len(errs) > 0 {
+		return nil, &ValidationError{Kind: "segment", Errors: errs}
+	}
+	seg, err := s.repo.CreateSegmentVersion(projectID, def)
+	if err == nil {
+		s.changes.notifyProject(projectID)
+	}
+	return seg, err
+}
+
+func (s *Service) GetSegment(projectID int, key string, version int) (*Segment, error) {
+	return s.repo.GetSegment(projectID, key, version)
+}
+
+func (s *Service) ListSegments(projectID int) ([]Segment, error) {
+	return s.repo.ListSegments(projectID)
+}
+
+// SegmentUsage lists the flags, in every environment, whose rules refer to a
+// segment, so the impact of editing it is known beforehand.
+func (s *Service) SegmentUsage(projectID int, key string) ([]SegmentUsage, error) {
+	list, err := s.repo.ListProjectFlags(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return segmentUsage(list, key), nil
+}
+
+func segmentUsage(list []envFlag, key string) []SegmentUsage {
+	usage := []SegmentUsage{}
+	for _, f := range list {
+		var rules []string
+		for i, r := range f.Rules {
+			if ruleUsesSegment(r, key) {
+				id := r.ID
+				if id == "" {
+					id = fmt.Sprintf("rules[%d]", i)
+				}
+				rules = append(rules, id)
+			}
+		}
+		if len(rules) > 0 {
+			usage = append(usage, SegmentUsage{Env: f.Env, EnvID: f.EnvID, Flag: f.Key, On: f.On, Rules: rules})
+		}
+	}
+	return usage
+}
+
+// DeleteSegment removes a segment no flag refers to any more.
+func (s *Service) DeleteSegment(projectID int, key string) (bool, error) {
+	found, err := s.repo.DeleteSegment(projectID, key)
+	if found {
+		s.changes.notifyProject(projectID)
+	}
+	return found, err
+}
+
+// segments loads the latest version of the project's segments, prepared for
+// evaluation, if any of the given flags needs them.
+func (s *Service) segments(projectID int, fs ...*flags.Flag) (flags.Segments, error) {
+	needed := false
+	for _, f := range fs {
+		needed = needed || len(f.SegmentKeys()) > 0
+	}
+	if !needed {
+		return nil, nil
+	}
+	list, err := s.repo.ListSegments(projectID)
+	if err != nil {
+		return nil, err
+	}
+	segments := make(flags.Segments, len(list))
+	for i := range list {
+		list[i].Prepare()
+		segments[list[i].Key] = &list[i].Segment
+	}
+	return segments, nil
+}
+
+func ruleUsesSegment(r flags.Rule, key string) bool {
+	for _, c := range r.Clauses {
+		if c.Op != flags.OpSegmentMatch {
+			continue
+		}
+		for _, v := range c.Values {
+			if v == key {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package featureflags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// EnvResolver maps an environment ID or slug to its ID within a project, or
+// 0 if there is no such environment. The configs service implements it.
+type EnvResolver interface {
+	ResolveEnvironment(projectID int, env string) (int, error)
+}
+
+// ValidationError lists why a flag or segment definition, or another
+// request, was rejected.
+type ValidationError struct {
+	Kind   string // "flag", "segment", "experiment" or "evaluation counts"
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Kind, strings.Join(e.Errors, "; "))
+}
+
+type Service struct {
+	repo    *Repository
+	envs    EnvResolver
+	changes *broker           // Feeds ruleset streams, reloading after writes
+	counts  *evaluationCounts // Server-side evaluations not stored yet
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewService(repo *Repository, envs EnvResolver) *Service {
+	s := &Service{repo: repo, envs: envs, counts: &evaluationCounts{}}
+	s.changes = newBroker(s.Ruleset)
+	return s
+}
+
+// Start stores the counts of the evaluations the service serves every
+// EvaluationFlushInterval, until Close.
+func (s *Service) Start() {
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go s.flushEvaluations(EvaluationFlushInterval)
+}
+
+// Close stops the flushing started by Start and stores the counts collected
+// since the last flush.
+func (s *Service) Close() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	s.storeEvaluations()
+}
+
+func (s *Service) ResolveEnvironment(projectID int, env string) (int, error) {
+	return s.envs.ResolveEnvironment(projectID, env)
+}
+
+// SaveFlag validates a flag definition and stores it as the flag's next
+// version. The repository checks that the segments it refers to exist in the
+// same transaction.
+func (s *Service) SaveFlag(projectID, envID int, def flags.Flag) (*Flag, error) {
+	if errs := def.Validate(); len(errs) > 0 {
+		return nil, &ValidationError{Kind: "flag", Errors: errs}
+	}
+	f, err := s.repo.Save(projectID, envID, def)
+	if err == nil {
+		s.changes.notify(projectID, envID)
+	}
+	return f, err
+}
+
+func (s *Service) GetFlag(projectID, envID int, key string) (*Flag, error) {
+	return s.repo.Get(projectID, envID, key)
+}
+
+func (s *Service) ListFlags(projectID, envID int) ([]Flag, error) {
+	return s.repo.List(projectID, envID)
+}
+
+func (s *Service) DeleteFlag(projectID, envID int, key string) (bool, error) {
+	found, err := s.repo.Delete(projectID, envID, key)
+	if found {
+		s.changes.notify(projectID, envID)
+	}
+	return found, err
+}
+
+// Evaluate evaluates one flag for ctx. It returns nil if the flag does not
+// exist.
+func (s *Service) Evaluate(projectID, envID int, key string, ctx flags.Context) (*flags.Result, error) {
+	f, err := s.repo.Get(projectID, envID, key)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	f.Prepare()
+	segments, err := s.segments(projectID, &f.Flag)
+	if err != nil {
+		return nil, err
+	}
+	res := flags.EvaluateWithSegments(&f.Flag, ctx, segments)
+	s.recordResults(projectID, envID, res)
+	return &res, nil
+}
+
+// EvaluateAll evaluates every flag of an environment for ctx, ordered by key.
+func (s *Service) EvaluateAll(projectID, envID int, ctx flags.Context) ([]flags.Result, error) {
+	list, err := s.repo.List(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	defs := make([]*flags.Flag, len(list))
+	for i := range list {
+		defs[i] = &list[i].Flag
+		defs[i].Prepare()
+	}
+	segments, err := s.segments(projectID, defs...)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]flags.Result, 0, len(list))
+	for _, f := range defs {
+		results = append(results, flags.EvaluateWithSegments(f, ctx, segments))
+	}
+	s.recordResults(projectID, envID, results...)
+	return results, nil
+}
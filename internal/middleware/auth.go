@@ -2,7 +2,9 @@
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"net/http"
 
 	"github.com/clyvecute/configra/pkg/utils"
@@ -19,7 +21,28 @@ func NewAuthMiddleware(db *sql.DB) *AuthMiddleware {
 type contextKey string
 const ProjectIDKey contextKey = "projectID"
 
+// APIKeyIDKey holds the KeyID of the key that authenticated the request.
+const APIKeyIDKey contextKey = "apiKeyID"
+
+// KeyID returns a short fingerprint identifying an API key in reports without
+// revealing it: the first 12 hex digits of its SHA-256.
+func KeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// RequireAPIKey accepts a project's API key or its admin key.
 func (m *AuthMiddleware) RequireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return m.requireKey("SELECT id FROM projects WHERE api_key = $1 OR admin_key = $1", "invalid api key", next)
+}
+
+// RequireAdminKey guards privileged operations, such as registering schemas,
+// and only accepts a project's admin key.
+func (m *AuthMiddleware) RequireAdminKey(next http.HandlerFunc) http.HandlerFunc {
+	return m.requireKey("SELECT id FROM projects WHERE admin_key = $1", "admin key required", next)
+}
+
+func (m *AuthMiddleware) requireKey(query, invalidMsg string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		apiKey := r.Header.Get("X-API-Key")
 		if apiKey == "" {
@@ -34,10 +57,10 @@ func (m *AuthMiddleware) RequireAPIKey(next http.HandlerFunc) http.HandlerFunc {
 
 		var projectID int
 		// Simple query to validate key and get ID
-		err := m.db.QueryRow("SELECT id FROM projects WHERE api_key = $1", apiKey).Scan(&projectID)
+		err := m.db.QueryRow(query, apiKey).Scan(&projectID)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+				utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": invalidMsg})
 				return
 			}
 			utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "auth error"})
@@ -46,6 +69,7 @@ func (m *AuthMiddleware) RequireAPIKey(next http.HandlerFunc) http.HandlerFunc {
 
 		// Store projectID in context
 		ctx := context.WithValue(r.Context(), ProjectIDKey, projectID)
+		ctx = context.WithValue(ctx, APIKeyIDKey, KeyID(apiKey))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
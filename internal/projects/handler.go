@@ -1 +1,42 @@
-﻿package projects
+package projects
+
+import (
+	"net/http"
+
+	"github.com/clyvecute/configra/internal/middleware"
+	"github.com/clyvecute/configra/pkg/utils"
+)
+
+type Handler struct {
+	repo *Repository
+}
+
+func NewHandler(repo *Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+// Current returns the project the request's API key belongs to. The CLI uses
+// it to verify credentials on login.
+func (h *Handler) Current(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := r.Context().Value(middleware.ProjectIDKey).(int)
+	if !ok || projectID == 0 {
+		utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized project scope"})
+		return
+	}
+
+	p, err := h.repo.GetByID(projectID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if p == nil {
+		utils.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"id":         p.ID,
+		"name":       p.Name,
+		"created_at": p.CreatedAt,
+	})
+}
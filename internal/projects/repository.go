@@ -12,6 +12,7 @@ type Project struct {
 	Name      string    `json:"name"`
 	OwnerID   int       `json:"owner_id"`
 	APIKey    string    `json:"api_key"`
+	AdminKey  string    `json:"admin_key"` // Authorizes privileged operations such as registering schemas
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -28,19 +29,24 @@ func (r *Repository) Create(name string, ownerID int) (*Project, error) {
 	if err != nil {
 		return nil, err
 	}
+	adminKey, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
 
 	query := `
-		INSERT INTO projects (name, owner_id, api_key) 
-		VALUES ($1, $2, $3) 
+		INSERT INTO projects (name, owner_id, api_key, admin_key) 
+		VALUES ($1, $2, $3, $4) 
 		RETURNING id, created_at`
 	
 	p := &Project{
-		Name:    name,
-		OwnerID: ownerID,
-		APIKey:  apiKey,
+		Name:     name,
+		OwnerID:  ownerID,
+		APIKey:   apiKey,
+		AdminKey: adminKey,
 	}
 
-	err = r.db.QueryRow(query, name, ownerID, apiKey).Scan(&p.ID, &p.CreatedAt)
+	err = r.db.QueryRow(query, name, ownerID, apiKey, adminKey).Scan(&p.ID, &p.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -49,10 +55,10 @@ func (r *Repository) Create(name string, ownerID int) (*Project, error) {
 }
 
 func (r *Repository) GetByID(id int) (*Project, error) {
-	query := `SELECT id, name, owner_id, api_key, created_at FROM projects WHERE id = $1`
+	query := `SELECT id, name, owner_id, api_key, COALESCE(admin_key, ''), created_at FROM projects WHERE id = $1`
 	
 	p := &Project{}
-	err := r.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.OwnerID, &p.APIKey, &p.CreatedAt)
+	err := r.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.OwnerID, &p.APIKey, &p.AdminKey, &p.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found
@@ -0,0 +1,73 @@
+package flags
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// EvaluationCount is how many times a flag served a variation within an hour.
+type EvaluationCount struct {
+	Flag      string    `json:"flag"`
+	Variation string    `json:"variation"` // "" for evaluations that failed
+	Hour      time.Time `json:"hour"`      // Start of the hour, UTC
+	Count     int64     `json:"count"`
+}
+
+type countKey struct {
+	flag, variation string
+	hour            int64
+}
+
+// Counter aggregates evaluations into hourly counts. The zero value is ready
+// to use, and it is safe for concurrent use.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[countKey]int64
+}
+
+// Add counts one evaluation, made at t.
+func (c *Counter) Add(res Result, t time.Time) {
+	c.add(countKey{res.Key, res.Variation, t.Unix() / 3600}, 1)
+}
+
+// Merge adds counts back in, such as those of a batch that failed to send.
+func (c *Counter) Merge(counts []EvaluationCount) {
+	for _, ec := range counts {
+		c.add(countKey{ec.Flag, ec.Variation, ec.Hour.Unix() / 3600}, ec.Count)
+	}
+}
+
+func (c *Counter) add(k countKey, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[countKey]int64{}
+	}
+	c.counts[k] += n
+}
+
+// Drain returns the counts collected so far, by hour, flag and variation,
+// and resets the counter.
+func (c *Counter) Drain() []EvaluationCount {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = nil
+	c.mu.Unlock()
+
+	list := make([]EvaluationCount, 0, len(counts))
+	for k, n := range counts {
+		list = append(list, EvaluationCount{Flag: k.flag, Variation: k.variation, Hour: time.Unix(k.hour*3600, 0).UTC(), Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.Flag != b.Flag {
+			return a.Flag < b.Flag
+		}
+		return a.Variation < b.Variation
+	})
+	return list
+}
@@ -0,0 +1,36 @@
+package flags
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	var c Counter
+	t0 := time.Date(2026, 10, 18, 13, 5, 0, 0, time.UTC)
+	c.Add(Result{Key: "b", Variation: "on"}, t0)
+	c.Add(Result{Key: "b", Variation: "on"}, t0.Add(50*time.Minute))
+	c.Add(Result{Key: "a", Variation: "off"}, t0)
+	c.Add(Result{Key: "a"}, t0.Add(time.Hour))
+
+	hour := time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)
+	want := []EvaluationCount{
+		{Flag: "a", Variation: "off", Hour: hour, Count: 1},
+		{Flag: "b", Variation: "on", Hour: hour, Count: 2},
+		{Flag: "a", Variation: "", Hour: hour.Add(time.Hour), Count: 1},
+	}
+	got := c.Drain()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Drain() = %+v, want %+v", got, want)
+	}
+	if rest := c.Drain(); len(rest) != 0 {
+		t.Errorf("Drain() after Drain() = %+v", rest)
+	}
+
+	c.Add(Result{Key: "b", Variation: "on"}, t0)
+	c.Merge(got)
+	if again := c.Drain(); again[1].Count != 3 || len(again) != 3 {
+		t.Errorf("Drain() after Merge() = %+v", again)
+	}
+}
@@ -0,0 +1,238 @@
+package flags
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Context holds the attributes a flag is evaluated against, e.g.
+// {"key": "user-42", "country": "DE", "plan": "pro", "app_version": "2.3.1"}.
+// By convention "key" identifies the user or device.
+type Context map[string]interface{}
+
+// KeyAttribute is the context attribute identifying the evaluated entity.
+const KeyAttribute = "key"
+
+// Reason kinds, named as in OpenFeature.
+const (
+	ReasonDisabled       = "DISABLED"        // The flag is off
+	ReasonTargetingMatch = "TARGETING_MATCH" // A rule matched
+	ReasonSplit          = "SPLIT"           // A percentage rollout picked the variation, in a rule or the default
+	ReasonDefault        = "DEFAULT"         // No rule matched
+	ReasonError          = "ERROR"           // The flag could not be evaluated
+)
+
+// Reason explains why a variation was served.
+type Reason struct {
+	Kind      string `json:"kind"`
+	RuleIndex *int   `json:"rule_index,omitempty"` // Set for TARGETING_MATCH, and SPLIT in a rule
+	RuleID    string `json:"rule_id,omitempty"`
+	Error     string `json:"error,omitempty"` // Set for ERROR
+}
+
+// Result is the outcome of evaluating a flag.
+type Result struct {
+	Key       string      `json:"key"`
+	Variation string      `json:"variation,omitempty"`
+	Value     interface{} `json:"value"`
+	Version   int         `json:"version,omitempty"` // Flag version that was evaluated
+	Reason    Reason      `json:"reason"`
+}
+
+// Evaluate decides which variation f serves to ctx. A flag referring to an
+// unknown variation, or a rollout without its bucketing attribute in ctx,
+// evaluates to an ERROR result without a value. Flags with segment_match
+// clauses need EvaluateWithSegments.
+func Evaluate(f *Flag, ctx Context) Result {
+	return EvaluateWithSegments(f, ctx, nil)
+}
+
+// EvaluateWithSegments is Evaluate for flags whose rules refer to segments.
+// A reference to a segment missing from segments is an ERROR.
+func EvaluateWithSegments(f *Flag, ctx Context, segments Segments) Result {
+	res := Result{Key: f.Key, Version: f.Version}
+	fail := func(err error) Result {
+		res.Reason = Reason{Kind: ReasonError, Error: err.Error()}
+		return res
+	}
+	variation := func(name string, reason Reason) Result {
+		v, ok := f.variation(name)
+		if !ok {
+			return fail(fmt.Errorf("unknown variation '%s'", name))
+		}
+		res.Variation, res.Value, res.Reason = v.Name, v.Value, reason
+		return res
+	}
+	serve := func(s Serve, reason Reason) Result {
+		if s.Rollout == nil {
+			return variation(s.Variation, reason)
+		}
+		name, err := s.Rollout.pick(f.Key, ctx)
+		if err != nil {
+			return fail(err)
+		}
+		reason.Kind = ReasonSplit
+		return variation(name, reason)
+	}
+
+	if !f.On {
+		return variation(f.OffVariation, Reason{Kind: ReasonDisabled})
+	}
+	for i, r := range f.Rules {
+		matched, err := r.matches(ctx, segments)
+		if err != nil {
+			return fail(err)
+		}
+		if matched {
+			index := i
+			id := r.ID
+			if id == "" {
+				id = fmt.Sprintf("rules[%d]", i)
+			}
+			return serve(r.Serve, Reason{Kind: ReasonTargetingMatch, RuleIndex: &index, RuleID: id})
+		}
+	}
+	return serve(f.Default, Reason{Kind: ReasonDefault})
+}
+
+// matches reports whether every clause of the rule matches ctx.
+func (r *Rule) matches(ctx Context, segments Segments) (bool, error) {
+	for _, c := range r.Clauses {
+		if ok, err := c.matches(ctx, segments); !ok || err != nil {
+			return false, err
+		}
+	}
+	return len(r.Clauses) > 0, nil
+}
+
+// matches reports whether the clause matches ctx.
+func (c Clause) matches(ctx Context, segments Segments) (bool, error) {
+	if c.Op == OpSegmentMatch {
+		for _, v := range c.Values {
+			key, _ := v.(string)
+			seg, ok := segments[key]
+			if !ok {
+				return false, fmt.Errorf("unknown segment '%s'", key)
+			}
+			if seg.Contains(ctx) {
+				return !c.Negate, nil
+			}
+		}
+		return c.Negate, nil
+	}
+
+	attr, ok := ctx[c.Attribute]
+	if !ok || attr == nil {
+		return false, nil
+	}
+	// A list attribute, such as groups, matches if any element does.
+	items, isList := attr.([]interface{})
+	if !isList {
+		items = []interface{}{attr}
+	}
+	for _, item := range items {
+		for i, want := range c.Values {
+			if c.patterns != nil {
+				if s, ok := item.(string); ok && c.patterns[i] != nil && c.patterns[i].MatchString(s) {
+					return !c.Negate, nil
+				}
+				continue
+			}
+			if matchValue(c.Op, item, want) {
+				return !c.Negate, nil
+			}
+		}
+	}
+	return c.Negate, nil
+}
+
+func matchValue(op string, got, want interface{}) bool {
+	switch op {
+	case OpIn:
+		if g, ok := toFloat(got); ok {
+			w, ok := toFloat(want)
+			return ok && g == w
+		}
+		return reflect.DeepEqual(got, want)
+	case OpStartsWith, OpEndsWith, OpContains, OpMatches:
+		g, ok1 := got.(string)
+		w, ok2 := want.(string)
+		if !ok1 || !ok2 {
+			return false
+		}
+		switch op {
+		case OpStartsWith:
+			return strings.HasPrefix(g, w)
+		case OpEndsWith:
+			return strings.HasSuffix(g, w)
+		case OpContains:
+			return strings.Contains(g, w)
+		}
+		// Clauses of a prepared flag or segment skip this.
+		re, err := regexp.Compile(w)
+		return err == nil && re.MatchString(g)
+	case OpLessThan, OpLessEq, OpGreater, OpGreaterEq:
+		g, ok1 := toFloat(got)
+		w, ok2 := toFloat(want)
+		if !ok1 || !ok2 {
+			return false
+		}
+		return compareHolds(op, sign(compareFloat(g, w)))
+	case OpSemverEq, OpSemverLt, OpSemverLte, OpSemverGt, OpSemverGte:
+		g, ok1 := got.(string)
+		w, ok2 := want.(string)
+		if !ok1 || !ok2 {
+			return false
+		}
+		gv, err1 := ParseVersion(g)
+		wv, err2 := ParseVersion(w)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		return compareHolds(op, gv.Compare(wv))
+	}
+	return false
+}
+
+// compareHolds applies a comparison operator to the result of a comparison.
+func compareHolds(op string, cmp int) bool {
+	switch op {
+	case OpLessThan, OpSemverLt:
+		return cmp < 0
+	case OpLessEq, OpSemverLte:
+		return cmp <= 0
+	case OpGreater, OpSemverGt:
+		return cmp > 0
+	case OpGreaterEq, OpSemverGte:
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
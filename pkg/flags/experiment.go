@@ -0,0 +1,184 @@
+package flags
+
+import "fmt"
+
+// Assignment reasons.
+const (
+	AssignInExperiment = "IN_EXPERIMENT" // Assigned to an arm
+	AssignNotAllocated = "NOT_ALLOCATED" // Outside the experiment's traffic
+	AssignStopped      = "STOPPED"       // The experiment is not running
+	AssignError        = "ERROR"
+)
+
+// Experiment splits a share of traffic between named arms. Experiments in
+// the same layer are mutually exclusive: each takes its own slice of the
+// layer's traffic, so a context is in at most one of them.
+type Experiment struct {
+	Key         string `json:"key"`
+	Description string `json:"description,omitempty"`
+	Running     bool   `json:"running"`
+	// Layer names the traffic the experiment takes its slice of. Without
+	// one, the experiment has traffic of its own.
+	Layer    string     `json:"layer,omitempty"`
+	Traffic  Allocation `json:"traffic"`
+	BucketBy string     `json:"bucket_by,omitempty"` // Context attribute to hash, default "key"
+	Arms     []Arm      `json:"arms"`
+	Version  int        `json:"version,omitempty"` // Set by the server on every change
+}
+
+// Allocation is the slice [Start, End) of a layer's traffic, in percent.
+type Allocation struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Arm is one treatment of an experiment, with its share of the experiment's
+// traffic in percent.
+type Arm struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Weight      float64     `json:"weight"`
+	Value       interface{} `json:"value,omitempty"`
+}
+
+// Assignment is the arm an experiment puts a context in, if any.
+type Assignment struct {
+	Experiment string      `json:"experiment"`
+	Layer      string      `json:"layer,omitempty"`
+	Version    int         `json:"version"`
+	Arm        string      `json:"arm,omitempty"` // Empty unless Reason is IN_EXPERIMENT
+	Value      interface{} `json:"value,omitempty"`
+	Unit       string      `json:"unit,omitempty"` // The bucketed attribute value
+	Reason     string      `json:"reason"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// InExperiment reports whether the context was assigned an arm.
+func (a Assignment) InExperiment() bool {
+	return a.Reason == AssignInExperiment
+}
+
+// Assign returns the arm of e for ctx. Contexts are hashed twice with
+// Bucket: with the layer ("layer:<layer>", or "<key>:traffic" without one)
+// to decide whether they fall in the experiment's traffic, then with
+// "experiment:<key>" to pick an arm, in order, by weight. Keys can't contain
+// a colon, so neither hash matches the rollout of a flag. Every SDK must
+// assign the same way.
+func (e *Experiment) Assign(ctx Context) Assignment {
+	a := Assignment{Experiment: e.Key, Layer: e.Layer, Version: e.Version}
+	if !e.Running {
+		a.Reason = AssignStopped
+		return a
+	}
+	arms := e.rollout()
+	value, ok := BucketValue(ctx[arms.bucketBy()])
+	if !ok {
+		a.Reason = AssignError
+		a.Error = fmt.Sprintf("context has no usable '%s' attribute to bucket by", arms.bucketBy())
+		return a
+	}
+	a.Unit = value
+
+	bucket := Bucket(e.layerSalt(), "", value)
+	if bucket < weightBuckets(e.Traffic.Start) || bucket >= weightBuckets(e.Traffic.End) {
+		a.Reason = AssignNotAllocated
+		return a
+	}
+	name, err := arms.pick("experiment:"+e.Key, ctx)
+	if err != nil {
+		a.Reason, a.Error = AssignError, err.Error()
+		return a
+	}
+	for _, arm := range e.Arms {
+		if arm.Name == name {
+			a.Arm, a.Value, a.Reason = arm.Name, arm.Value, AssignInExperiment
+		}
+	}
+	return a
+}
+
+// LayerErrors lists the conflicts of e with the other experiments of its
+// layer. Their traffic must not overlap, and they must all bucket by the same
+// attribute: the layer hash of different units would let a context into
+// several of them.
+func (e *Experiment) LayerErrors(others []Experiment) []string {
+	var errs []string
+	for i := range others {
+		other := &others[i]
+		if e.Layer == "" || other.Layer != e.Layer || other.Key == e.Key {
+			continue
+		}
+		if by, otherBy := e.rollout().bucketBy(), other.rollout().bucketBy(); by != otherBy {
+			errs = append(errs, fmt.Sprintf("layer '%s' buckets by '%s' (experiment '%s'), not '%s'", e.Layer, otherBy, other.Key, by))
+		}
+		if e.Overlaps(other) {
+			errs = append(errs, fmt.Sprintf("traffic %v-%v%% of layer '%s' overlaps experiment '%s' (%v-%v%%)",
+				e.Traffic.Start, e.Traffic.End, e.Layer, other.Key, other.Traffic.Start, other.Traffic.End))
+		}
+	}
+	return errs
+}
+
+// Overlaps reports whether e and other are in the same layer and their
+// traffic intersects, so that a context could be in both.
+func (e *Experiment) Overlaps(other *Experiment) bool {
+	if e.Layer == "" || e.Layer != other.Layer || e.Key == other.Key {
+		return false
+	}
+	return weightBuckets(e.Traffic.Start) < weightBuckets(other.Traffic.End) &&
+		weightBuckets(other.Traffic.Start) < weightBuckets(e.Traffic.End)
+}
+
+// Validate lists the problems of an experiment definition.
+func (e *Experiment) Validate() []string {
+	var errs []string
+	if !ValidKey(e.Key) {
+		errs = append(errs, fmt.Sprintf("invalid experiment key %q", e.Key))
+	}
+	if e.Layer != "" && !ValidKey(e.Layer) {
+		errs = append(errs, fmt.Sprintf("invalid layer %q", e.Layer))
+	}
+	if t := e.Traffic; t.Start < 0 || t.End > 100 || t.Start >= t.End {
+		errs = append(errs, fmt.Sprintf("traffic: need 0 <= start < end <= 100, got %v-%v", t.Start, t.End))
+	}
+
+	if len(e.Arms) < 2 {
+		errs = append(errs, "experiment needs at least two arms")
+	}
+	names := map[string]bool{}
+	total := 0
+	for i, arm := range e.Arms {
+		switch {
+		case arm.Name == "":
+			errs = append(errs, fmt.Sprintf("arms[%d] has no name", i))
+		case names[arm.Name]:
+			errs = append(errs, fmt.Sprintf("arm '%s' is defined twice", arm.Name))
+		}
+		names[arm.Name] = true
+		if arm.Weight < 0 || arm.Weight > 100 {
+			errs = append(errs, fmt.Sprintf("arms[%d]: weight must be between 0 and 100, got %v", i, arm.Weight))
+		}
+		total += weightBuckets(arm.Weight)
+	}
+	if len(e.Arms) > 0 && total != BucketCount {
+		errs = append(errs, fmt.Sprintf("arm weights add up to %v%%, not 100%%", float64(total)*100/BucketCount))
+	}
+	return errs
+}
+
+func (e *Experiment) layerSalt() string {
+	if e.Layer != "" {
+		return "layer:" + e.Layer
+	}
+	return e.Key + ":traffic"
+}
+
+// rollout is the rollout that picks an arm, once a context is in the
+// experiment's traffic.
+func (e *Experiment) rollout() *Rollout {
+	r := &Rollout{BucketBy: e.BucketBy, Variations: make([]WeightedVariation, len(e.Arms))}
+	for i, arm := range e.Arms {
+		r.Variations[i] = WeightedVariation{Variation: arm.Name, Weight: arm.Weight}
+	}
+	return r
+}
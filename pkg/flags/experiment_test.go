@@ -0,0 +1,196 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// experimentVectors are computed by a separate implementation of the
+// assignment algorithm; SDKs in other languages should pass them too.
+type experimentVectors struct {
+	Experiments []Experiment `json:"experiments"`
+	Assignments []struct {
+		Context Context           `json:"context"`
+		Arms    map[string]string `json:"arms"` // By experiment; "" when not allocated
+	} `json:"assignments"`
+}
+
+func TestExperimentVectors(t *testing.T) {
+	b, err := os.ReadFile("testdata/experiment_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v experimentVectors
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := NewRuleset(1, nil, nil, v.Experiments)
+	for _, tt := range v.Assignments {
+		for key, want := range tt.Arms {
+			a := rs.Assign(key, tt.Context)
+			if a.Arm != want || a.InExperiment() != (want != "") {
+				t.Errorf("Assign(%s, %v) = %+v, want arm %q", key, tt.Context, a, want)
+			}
+		}
+		// The checkout layer is split between two experiments, so every
+		// context is in exactly one of them.
+		if (tt.Arms["checkout-button"] == "") == (tt.Arms["checkout-copy"] == "") {
+			t.Errorf("%v is in both or neither checkout experiments: %v", tt.Context, tt.Arms)
+		}
+	}
+}
+
+func TestLayerIsMutuallyExclusive(t *testing.T) {
+	arms := []Arm{{Name: "control", Weight: 50}, {Name: "treatment", Weight: 50}}
+	layer := []Experiment{
+		{Key: "a", Running: true, Layer: "search", Traffic: Allocation{0, 20}, Arms: arms},
+		{Key: "b", Running: true, Layer: "search", Traffic: Allocation{20, 30}, Arms: arms},
+		{Key: "c", Running: true, Layer: "search", Traffic: Allocation{60, 100}, Arms: arms},
+	}
+	in := map[string]map[string]int{"a": {}, "b": {}, "c": {}}
+	const users = 20000
+	for i := 0; i < users; i++ {
+		ctx := Context{"key": fmt.Sprintf("user-%d", i)}
+		n := 0
+		for j := range layer {
+			if a := layer[j].Assign(ctx); a.InExperiment() {
+				in[a.Experiment][a.Arm]++
+				n++
+			}
+		}
+		if n > 1 {
+			t.Fatalf("%v is in %d experiments of one layer", ctx, n)
+		}
+	}
+
+	for key, share := range map[string]float64{"a": 0.2, "b": 0.1, "c": 0.4} {
+		got := in[key]["control"] + in[key]["treatment"]
+		if want := share * users; float64(got) < want*0.9 || float64(got) > want*1.1 {
+			t.Errorf("experiment %s got %d contexts, want about %.0f", key, got, want)
+		}
+		// Arms are hashed independently of the layer, so they split evenly.
+		if c := float64(in[key]["control"]) / float64(got); c < 0.45 || c > 0.55 {
+			t.Errorf("experiment %s control share = %.2f, want about 0.5", key, c)
+		}
+	}
+}
+
+func TestAssignStoppedAndErrors(t *testing.T) {
+	e := Experiment{Key: "pricing", Traffic: Allocation{0, 100}, BucketBy: "org",
+		Arms: []Arm{{Name: "control", Weight: 50}, {Name: "discount", Weight: 50, Value: 0.1}}}
+	if a := e.Assign(Context{"org": "acme"}); a.Reason != AssignStopped || a.Arm != "" {
+		t.Errorf("stopped experiment assigned %+v", a)
+	}
+	e.Running = true
+	if a := e.Assign(Context{"key": "user-1"}); a.Reason != AssignError || !strings.Contains(a.Error, "'org'") {
+		t.Errorf("missing bucket attribute: %+v", a)
+	}
+	if a := e.Assign(Context{"org": "acme"}); !a.InExperiment() || a.Unit != "acme" {
+		t.Errorf("full traffic: %+v", a)
+	}
+	rs := NewRuleset(1, nil, nil, []Experiment{e})
+	if a := rs.Assign("nope", Context{}); a.Reason != AssignError || a.Error != "experiment 'nope' not found" {
+		t.Errorf("unknown experiment: %+v", a)
+	}
+}
+
+func TestValidateExperiment(t *testing.T) {
+	e := Experiment{
+		Key:     "bad key",
+		Layer:   "checkout",
+		Traffic: Allocation{60, 40},
+		Arms:    []Arm{{Name: "control", Weight: 60}, {Name: "control", Weight: 30}, {Weight: -1}},
+	}
+	got := strings.Join(e.Validate(), "\n")
+	for _, want := range []string{
+		`invalid experiment key "bad key"`,
+		"traffic: need 0 <= start < end <= 100, got 60-40",
+		"arm 'control' is defined twice",
+		"arms[2] has no name",
+		"arms[2]: weight must be between 0 and 100, got -1",
+		"arm weights add up to 89%, not 100%",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Validate() missing %q in:\n%s", want, got)
+		}
+	}
+
+	arms := []Arm{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}
+	ok := Experiment{Key: "ok", Traffic: Allocation{0, 50}, Arms: arms}
+	if errs := ok.Validate(); len(errs) > 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+
+	x := Experiment{Key: "x", Layer: "l", Traffic: Allocation{0, 50}}
+	for _, tt := range []struct {
+		other Experiment
+		want  bool
+	}{
+		{Experiment{Key: "y", Layer: "l", Traffic: Allocation{50, 100}}, false},
+		{Experiment{Key: "y", Layer: "l", Traffic: Allocation{49.5, 60}}, true},
+		{Experiment{Key: "y", Layer: "m", Traffic: Allocation{0, 50}}, false},
+		{Experiment{Key: "y", Traffic: Allocation{0, 50}}, false},
+		{Experiment{Key: "x", Layer: "l", Traffic: Allocation{0, 50}}, false}, // Itself
+	} {
+		if got := x.Overlaps(&tt.other); got != tt.want {
+			t.Errorf("Overlaps(%+v) = %v, want %v", tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestLayerErrors(t *testing.T) {
+	arms := []Arm{{Name: "control", Weight: 50}, {Name: "treatment", Weight: 50}}
+	byKey := Experiment{Key: "a", Running: true, Layer: "l", Traffic: Allocation{0, 50}, Arms: arms}
+	byOrg := Experiment{Key: "b", Running: true, Layer: "l", Traffic: Allocation{50, 100}, BucketBy: "org", Arms: arms}
+
+	// Hashing different units, disjoint slices still share contexts.
+	both := 0
+	for i := 0; i < 1000; i++ {
+		ctx := Context{"key": fmt.Sprintf("user-%d", i), "org": fmt.Sprintf("org-%d", i%37)}
+		if byKey.Assign(ctx).InExperiment() && byOrg.Assign(ctx).InExperiment() {
+			both++
+		}
+	}
+	if both == 0 {
+		t.Fatal("expected contexts in both experiments when bucketing by different attributes")
+	}
+	errs := byOrg.LayerErrors([]Experiment{byKey, byOrg})
+	if len(errs) != 1 || errs[0] != "layer 'l' buckets by 'key' (experiment 'a'), not 'org'" {
+		t.Errorf("LayerErrors() = %q", errs)
+	}
+
+	byOrg.BucketBy = KeyAttribute
+	if errs := byOrg.LayerErrors([]Experiment{byKey}); len(errs) != 0 {
+		t.Errorf("LayerErrors() = %q, want none", errs)
+	}
+	byOrg.Traffic = Allocation{40, 100}
+	if errs := byOrg.LayerErrors([]Experiment{byKey}); len(errs) != 1 || !strings.Contains(errs[0], "overlaps experiment 'a'") {
+		t.Errorf("LayerErrors() = %q, want an overlap", errs)
+	}
+}
+
+func TestArmsAreNotCorrelatedWithFlags(t *testing.T) {
+	e := Experiment{Key: "checkout", Running: true, Traffic: Allocation{0, 100},
+		Arms: []Arm{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}}
+	r := &Rollout{Variations: []WeightedVariation{{Variation: "a", Weight: 50}, {Variation: "b", Weight: 50}}}
+	same := 0
+	const users = 2000
+	for i := 0; i < users; i++ {
+		ctx := Context{"key": fmt.Sprintf("user-%d", i)}
+		v, err := r.pick("checkout", ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Assign(ctx).Arm == v {
+			same++
+		}
+	}
+	// Independent splits agree about half the time.
+	if share := float64(same) / users; share < 0.45 || share > 0.55 {
+		t.Errorf("flag and experiment 'checkout' agree for %.2f of users", share)
+	}
+}
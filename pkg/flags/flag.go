@@ -0,0 +1,217 @@
+// Package flags defines Configra feature flags and evaluates them against an
+// evaluation context. It has no dependencies beyond the standard library so
+// that the server and the SDK evaluate flags identically.
+package flags
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Flag is a feature flag in one environment. When it is off, every context
+// gets OffVariation. When it is on, the first rule whose clauses all match
+// decides the variation, and Default serves everyone else.
+type Flag struct {
+	Key          string      `json:"key"`
+	Description  string      `json:"description,omitempty"`
+	On           bool        `json:"on"`
+	Variations   []Variation `json:"variations"`
+	OffVariation string      `json:"off_variation"`
+	Rules        []Rule      `json:"rules,omitempty"`
+	Default      Serve       `json:"default"`
+	Version      int         `json:"version,omitempty"` // Set by the server on every change
+}
+
+// Variation is one of the values a flag can serve.
+type Variation struct {
+	Name        string      `json:"name"`
+	Value       interface{} `json:"value"`
+	Description string      `json:"description,omitempty"`
+}
+
+// Serve says what a rule or the default serves: one variation, or a
+// percentage rollout across several.
+type Serve struct {
+	Variation string   `json:"variation,omitempty"`
+	Rollout   *Rollout `json:"rollout,omitempty"`
+}
+
+// Rule targets the contexts matching all of its clauses.
+type Rule struct {
+	ID          string   `json:"id,omitempty"` // Reported in the reason; defaults to rules[i]
+	Description string   `json:"description,omitempty"`
+	Clauses     []Clause `json:"clauses"`
+	Serve
+}
+
+// Clause matches a context attribute against a list of values; it matches if
+// the operator holds for any of them. A missing attribute never matches,
+// negated or not.
+type Clause struct {
+	Attribute string        `json:"attribute"`
+	Op        string        `json:"op"`
+	Values    []interface{} `json:"values"`
+	Negate    bool          `json:"negate,omitempty"`
+
+	patterns []*regexp.Regexp // Compiled Values of a matches clause, set by Prepare
+}
+
+// Clause operators.
+const (
+	OpIn         = "in"          // Equal to one of the values
+	OpStartsWith = "starts_with" // Strings
+	OpEndsWith   = "ends_with"
+	OpContains   = "contains"
+	OpMatches    = "matches" // RE2 regular expression
+	OpLessThan   = "lt"      // Numbers
+	OpLessEq     = "lte"
+	OpGreater    = "gt"
+	OpGreaterEq  = "gte"
+	OpSemverEq   = "semver_eq" // Semantic versions, e.g. app_version
+	OpSemverLt   = "semver_lt"
+	OpSemverLte  = "semver_lte"
+	OpSemverGt   = "semver_gt"
+	OpSemverGte  = "semver_gte"
+)
+
+var operators = map[string]bool{
+	OpIn: true, OpStartsWith: true, OpEndsWith: true, OpContains: true, OpMatches: true,
+	OpLessThan: true, OpLessEq: true, OpGreater: true, OpGreaterEq: true,
+	OpSemverEq: true, OpSemverLt: true, OpSemverLte: true, OpSemverGt: true, OpSemverGte: true,
+	OpSegmentMatch: true,
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidKey reports whether key can name a flag.
+func ValidKey(key string) bool {
+	return len(key) <= 255 && keyPattern.MatchString(key)
+}
+
+// Validate lists everything that would keep the flag from evaluating as
+// written: unknown variations, operators or malformed clause values.
+func (f *Flag) Validate() []string {
+	var errs []string
+	if !ValidKey(f.Key) {
+		errs = append(errs, fmt.Sprintf("invalid flag key %q", f.Key))
+	}
+	if len(f.Variations) == 0 {
+		errs = append(errs, "flag needs at least one variation")
+	}
+	names := map[string]bool{}
+	for i, v := range f.Variations {
+		switch {
+		case v.Name == "":
+			errs = append(errs, fmt.Sprintf("variations[%d] has no name", i))
+		case names[v.Name]:
+			errs = append(errs, fmt.Sprintf("variation '%s' is defined twice", v.Name))
+		}
+		names[v.Name] = true
+	}
+
+	variation := func(where, name string) {
+		if !names[name] {
+			errs = append(errs, fmt.Sprintf("%s: unknown variation '%s'", where, name))
+		}
+	}
+	serve := func(where string, s Serve) {
+		switch {
+		case s.Rollout == nil:
+			variation(where, s.Variation)
+		case s.Variation != "":
+			errs = append(errs, where+": set either a variation or a rollout, not both")
+		default:
+			errs = append(errs, s.Rollout.validate(where, names)...)
+		}
+	}
+	variation("off_variation", f.OffVariation)
+	serve("default", f.Default)
+
+	for i, r := range f.Rules {
+		where := fmt.Sprintf("rules[%d]", i)
+		if len(r.Clauses) == 0 {
+			errs = append(errs, where+": rule needs at least one clause")
+		}
+		for j, c := range r.Clauses {
+			errs = append(errs, c.validate(fmt.Sprintf("%s.clauses[%d]", where, j))...)
+		}
+		serve(where, r.Serve)
+	}
+	return errs
+}
+
+func (c Clause) validate(where string) []string {
+	var errs []string
+	if c.Attribute == "" && c.Op != OpSegmentMatch {
+		errs = append(errs, where+": attribute is required")
+	}
+	if !operators[c.Op] {
+		return append(errs, fmt.Sprintf("%s: unknown operator %q", where, c.Op))
+	}
+	if len(c.Values) == 0 {
+		errs = append(errs, where+": at least one value is required")
+	}
+	for _, v := range c.Values {
+		switch c.Op {
+		case OpStartsWith, OpEndsWith, OpContains:
+			if _, ok := v.(string); !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s needs string values, got %v", where, c.Op, v))
+			}
+		case OpMatches:
+			s, ok := v.(string)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s needs string values, got %v", where, c.Op, v))
+			} else if _, err := regexp.Compile(s); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid regular expression %q: %v", where, s, err))
+			}
+		case OpLessThan, OpLessEq, OpGreater, OpGreaterEq:
+			if _, ok := toFloat(v); !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s needs numeric values, got %v", where, c.Op, v))
+			}
+		case OpSemverEq, OpSemverLt, OpSemverLte, OpSemverGt, OpSemverGte:
+			s, _ := v.(string)
+			if _, err := ParseVersion(s); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+			}
+		case OpSegmentMatch:
+			if s, _ := v.(string); !ValidKey(s) {
+				errs = append(errs, fmt.Sprintf("%s: invalid segment key %v", where, v))
+			}
+		}
+	}
+	return errs
+}
+
+// Prepare compiles the regular expressions of the flag's matches clauses, so
+// evaluating it does not. It must not run concurrently with an evaluation of
+// the flag. Rulesets prepare their flags themselves.
+func (f *Flag) Prepare() {
+	for i := range f.Rules {
+		prepareClauses(f.Rules[i].Clauses)
+	}
+}
+
+func prepareClauses(clauses []Clause) {
+	for i := range clauses {
+		c := &clauses[i]
+		if c.Op != OpMatches {
+			continue
+		}
+		c.patterns = make([]*regexp.Regexp, len(c.Values))
+		for j, v := range c.Values {
+			if s, ok := v.(string); ok {
+				c.patterns[j], _ = regexp.Compile(s) // nil if invalid: never matches
+			}
+		}
+	}
+}
+
+// variation looks up a variation by name.
+func (f *Flag) variation(name string) (Variation, bool) {
+	for _, v := range f.Variations {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variation{}, false
+}
@@ -0,0 +1,161 @@
+package flags
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkout is a flag exercising every kind of clause, as it would arrive from
+// the API (JSON numbers are float64).
+const checkout = `{
+	"key": "new-checkout",
+	"on": true,
+	"variations": [
+		{"name": "off", "value": false},
+		{"name": "on", "value": true},
+		{"name": "beta", "value": true}
+	],
+	"off_variation": "off",
+	"rules": [
+		{"id": "staff", "clauses": [{"attribute": "email", "op": "ends_with", "values": ["@example.com"]}], "variation": "on"},
+		{"id": "old-apps", "clauses": [{"attribute": "app_version", "op": "semver_lt", "values": ["2.0.0"]}], "variation": "off"},
+		{"clauses": [
+			{"attribute": "country", "op": "in", "values": ["DE", "AT", "CH"]},
+			{"attribute": "plan", "op": "in", "values": ["free"], "negate": true}
+		], "variation": "beta"},
+		{"clauses": [{"attribute": "seats", "op": "gte", "values": [50]}], "variation": "on"}
+	],
+	"default": {"variation": "off"}
+}`
+
+func mustFlag(t *testing.T, src string) *Flag {
+	t.Helper()
+	var f Flag
+	if err := json.Unmarshal([]byte(src), &f); err != nil {
+		t.Fatalf("decode flag: %v", err)
+	}
+	if errs := f.Validate(); len(errs) > 0 {
+		t.Fatalf("flag is invalid: %v", errs)
+	}
+	return &f
+}
+
+func TestEvaluate(t *testing.T) {
+	f := mustFlag(t, checkout)
+
+	tests := []struct {
+		name      string
+		ctx       Context
+		variation string
+		kind      string
+		ruleID    string
+	}{
+		{"staff", Context{"key": "u1", "email": "ana@example.com", "app_version": "1.0.0"}, "on", ReasonTargetingMatch, "staff"},
+		{"old app", Context{"key": "u2", "app_version": "1.9.3", "country": "DE"}, "off", ReasonTargetingMatch, "old-apps"},
+		{"prerelease of 2.0 is older", Context{"key": "u3", "app_version": "2.0.0-rc.1"}, "off", ReasonTargetingMatch, "old-apps"},
+		{"paying dach user", Context{"key": "u4", "app_version": "2.1", "country": "AT", "plan": "pro"}, "beta", ReasonTargetingMatch, "rules[2]"},
+		{"free dach user", Context{"key": "u5", "app_version": "2.1", "country": "AT", "plan": "free"}, "off", ReasonDefault, ""},
+		{"negated clause needs the attribute", Context{"key": "u6", "country": "CH"}, "off", ReasonDefault, ""},
+		{"numbers compare numerically", Context{"key": "u7", "seats": 120}, "on", ReasonTargetingMatch, "rules[3]"},
+		{"nobody in particular", Context{"key": "u8"}, "off", ReasonDefault, ""},
+		{"list attribute", Context{"key": "u9", "country": []interface{}{"US", "CH"}, "plan": "team"}, "beta", ReasonTargetingMatch, "rules[2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Evaluate(f, tt.ctx)
+			if res.Variation != tt.variation || res.Reason.Kind != tt.kind || res.Reason.RuleID != tt.ruleID {
+				t.Errorf("got %s (%s %s), want %s (%s %s)", res.Variation, res.Reason.Kind, res.Reason.RuleID, tt.variation, tt.kind, tt.ruleID)
+			}
+		})
+	}
+}
+
+func TestEvaluateOffAndErrors(t *testing.T) {
+	f := mustFlag(t, checkout)
+	f.On = false
+	res := Evaluate(f, Context{"email": "ana@example.com"})
+	if res.Variation != "off" || res.Value != false || res.Reason.Kind != ReasonDisabled {
+		t.Errorf("disabled flag: got %+v", res)
+	}
+
+	f.On = true
+	f.Default.Variation = "missing"
+	res = Evaluate(f, Context{"key": "u1"})
+	if res.Reason.Kind != ReasonError || res.Value != nil || !strings.Contains(res.Reason.Error, "missing") {
+		t.Errorf("unknown variation: got %+v", res)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var f Flag
+	json.Unmarshal([]byte(`{
+		"key": "bad key",
+		"on": true,
+		"variations": [{"name": "a", "value": 1}, {"name": "a", "value": 2}],
+		"off_variation": "a",
+		"rules": [
+			{"clauses": [], "variation": "a"},
+			{"clauses": [
+				{"attribute": "", "op": "in", "values": ["x"]},
+				{"attribute": "v", "op": "semver_gt", "values": ["1.x"]},
+				{"attribute": "n", "op": "lt", "values": ["ten"]},
+				{"attribute": "s", "op": "matches", "values": ["("]},
+				{"attribute": "s", "op": "matches", "values": [1]},
+				{"attribute": "s", "op": "like", "values": ["x"]}
+			], "variation": "b"}
+		],
+		"default": {}
+	}`), &f)
+
+	want := []string{
+		`invalid flag key "bad key"`,
+		"variation 'a' is defined twice",
+		"default: unknown variation ''",
+		"rules[0]: rule needs at least one clause",
+		"rules[1].clauses[0]: attribute is required",
+		`rules[1].clauses[1]: invalid version "1.x"`,
+		"rules[1].clauses[2]: lt needs numeric values, got ten",
+		`rules[1].clauses[3]: invalid regular expression "(": error parsing regexp: missing closing ): ` + "`(`",
+		"rules[1].clauses[4]: matches needs string values, got 1",
+		`rules[1].clauses[5]: unknown operator "like"`,
+		"rules[1]: unknown variation 'b'",
+	}
+	if got := f.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	// Each version is lower than the next.
+	ordered := []string{
+		"0.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "v1.0.0", "1.0.1", "1.2", "2",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, err := ParseVersion(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(ordered[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+
+	a, _ := ParseVersion("1.4.0+build.7")
+	b, _ := ParseVersion("1.4")
+	if a.Compare(b) != 0 {
+		t.Errorf("build metadata and missing patch should not matter")
+	}
+
+	for _, bad := range []string{"", "x", "1.2.3.4", "1.-1", "1.0.0-", "1.0.0-a..b"} {
+		if _, err := ParseVersion(bad); err == nil {
+			t.Errorf("ParseVersion(%q): expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package flags
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// BucketCount is the number of buckets contexts are hashed into; a weight of
+// 1% covers 1000 of them.
+const BucketCount = 100000
+
+// Rollout serves variations by percentage. Each context is hashed into a
+// stable bucket, and buckets are handed out to the variations in order. To
+// ramp a variation up without moving anyone who already has it, list it first
+// and raise its weight.
+type Rollout struct {
+	BucketBy   string              `json:"bucket_by,omitempty"` // Context attribute to hash, default "key"
+	Seed       string              `json:"seed,omitempty"`      // Hashed instead of the flag key; change it to reshuffle everyone
+	Variations []WeightedVariation `json:"variations"`
+}
+
+// WeightedVariation is a variation's share of a rollout, in percent.
+type WeightedVariation struct {
+	Variation string  `json:"variation"`
+	Weight    float64 `json:"weight"`
+}
+
+// Bucket returns the bucket in [0, BucketCount) of a context whose bucketing
+// attribute is value, for the flag key (or the rollout seed, when set): the
+// first 8 bytes of SHA-256("<flag key or seed>.<value>") as a big-endian
+// integer, modulo BucketCount. Every SDK must compute it the same way.
+func Bucket(flagKey, seed, value string) int {
+	salt := flagKey
+	if seed != "" {
+		salt = seed
+	}
+	sum := sha256.Sum256([]byte(salt + "." + value))
+	return int(binary.BigEndian.Uint64(sum[:8]) % BucketCount)
+}
+
+// BucketValue renders a bucketing attribute as hashed by Bucket. Strings are
+// used as they are and numbers in their shortest decimal form (42, 1.5); other
+// values can't be bucketed.
+func BucketValue(v interface{}) (string, bool) {
+	if s, ok := v.(string); ok {
+		return s, s != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// pick returns the variation ctx falls into.
+func (r *Rollout) pick(flagKey string, ctx Context) (string, error) {
+	if len(r.Variations) == 0 {
+		return "", fmt.Errorf("rollout has no variations")
+	}
+	attr := r.bucketBy()
+	value, ok := BucketValue(ctx[attr])
+	if !ok {
+		return "", fmt.Errorf("context has no usable '%s' attribute to bucket by", attr)
+	}
+
+	bucket := Bucket(flagKey, r.Seed, value)
+	upper := 0
+	for _, wv := range r.Variations {
+		upper += weightBuckets(wv.Weight)
+		if bucket < upper {
+			return wv.Variation, nil
+		}
+	}
+	// Weights of an unvalidated rollout may leave the top buckets uncovered;
+	// they go to the last variation.
+	return r.Variations[len(r.Variations)-1].Variation, nil
+}
+
+func (r *Rollout) bucketBy() string {
+	if r.BucketBy == "" {
+		return KeyAttribute
+	}
+	return r.BucketBy
+}
+
+// weightBuckets converts a weight in percent to a number of buckets.
+func weightBuckets(weight float64) int {
+	return int(math.Round(weight * BucketCount / 100))
+}
+
+func (r *Rollout) validate(where string, names map[string]bool) []string {
+	var errs []string
+	if len(r.Variations) == 0 {
+		errs = append(errs, where+": rollout needs at least one variation")
+	}
+	total := 0
+	for i, wv := range r.Variations {
+		if !names[wv.Variation] {
+			errs = append(errs, fmt.Sprintf("%s.rollout.variations[%d]: unknown variation '%s'", where, i, wv.Variation))
+		}
+		if wv.Weight < 0 || wv.Weight > 100 {
+			errs = append(errs, fmt.Sprintf("%s.rollout.variations[%d]: weight must be between 0 and 100, got %v", where, i, wv.Weight))
+		}
+		total += weightBuckets(wv.Weight)
+	}
+	if len(r.Variations) > 0 && total != BucketCount {
+		errs = append(errs, fmt.Sprintf("%s: rollout weights add up to %v%%, not 100%%", where, float64(total)*100/BucketCount))
+	}
+	return errs
+}
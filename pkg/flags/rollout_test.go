@@ -0,0 +1,128 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// rolloutVectors are computed by a separate implementation of the bucketing
+// algorithm; SDKs in other languages should pass them too.
+type rolloutVectors struct {
+	Buckets []struct {
+		FlagKey string `json:"flag_key"`
+		Seed    string `json:"seed"`
+		Value   string `json:"value"`
+		Bucket  int    `json:"bucket"`
+	} `json:"buckets"`
+	Flag        Flag `json:"flag"`
+	Evaluations []struct {
+		Context   Context `json:"context"`
+		Variation string  `json:"variation"`
+		Bucket    int     `json:"bucket"`
+	} `json:"evaluations"`
+}
+
+func loadRolloutVectors(t *testing.T) rolloutVectors {
+	t.Helper()
+	b, err := os.ReadFile("testdata/rollout_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v rolloutVectors
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestBucketVectors(t *testing.T) {
+	for _, tt := range loadRolloutVectors(t).Buckets {
+		if got := Bucket(tt.FlagKey, tt.Seed, tt.Value); got != tt.Bucket {
+			t.Errorf("Bucket(%q, %q, %q) = %d, want %d", tt.FlagKey, tt.Seed, tt.Value, got, tt.Bucket)
+		}
+	}
+}
+
+func TestRolloutVectors(t *testing.T) {
+	v := loadRolloutVectors(t)
+	if errs := v.Flag.Validate(); len(errs) > 0 {
+		t.Fatalf("vector flag is invalid: %v", errs)
+	}
+	for _, tt := range v.Evaluations {
+		res := Evaluate(&v.Flag, tt.Context)
+		if res.Variation != tt.Variation || res.Reason.Kind != ReasonSplit {
+			t.Errorf("Evaluate(%v) = %s (%s), want %s (bucket %d)", tt.Context, res.Variation, res.Reason.Kind, tt.Variation, tt.Bucket)
+		}
+	}
+}
+
+func TestRolloutIsSticky(t *testing.T) {
+	f := mustFlag(t, `{
+		"key": "search-v2", "on": true,
+		"variations": [{"name": "on", "value": true}, {"name": "off", "value": false}],
+		"off_variation": "off",
+		"default": {"rollout": {"variations": [{"variation": "on", "weight": 10}, {"variation": "off", "weight": 90}]}}
+	}`)
+
+	served := func() map[string]bool {
+		on := map[string]bool{}
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("user-%d", i)
+			if Evaluate(f, Context{"key": key}).Variation == "on" {
+				on[key] = true
+			}
+		}
+		return on
+	}
+
+	at10 := served()
+	f.Default.Rollout.Variations[0].Weight, f.Default.Rollout.Variations[1].Weight = 25, 75
+	at25 := served()
+	for key := range at10 {
+		if !at25[key] {
+			t.Fatalf("%s lost the variation when the rollout grew from 10%% to 25%%", key)
+		}
+	}
+	// 5000 users should land within a few points of the weights.
+	if n := len(at10); n < 400 || n > 600 {
+		t.Errorf("10%% rollout served %d of 5000 users", n)
+	}
+	if n := len(at25); n < 1100 || n > 1400 {
+		t.Errorf("25%% rollout served %d of 5000 users", n)
+	}
+
+	res := Evaluate(f, Context{"country": "DE"})
+	if res.Reason.Kind != ReasonError || res.Reason.Error != "context has no usable 'key' attribute to bucket by" {
+		t.Errorf("missing bucketing attribute: got %+v", res)
+	}
+}
+
+func TestValidateRollout(t *testing.T) {
+	var f Flag
+	json.Unmarshal([]byte(`{
+		"key": "search-v2", "on": true,
+		"variations": [{"name": "on", "value": true}, {"name": "off", "value": false}],
+		"off_variation": "off",
+		"rules": [
+			{"clauses": [{"attribute": "plan", "op": "in", "values": ["pro"]}], "variation": "on",
+			 "rollout": {"variations": [{"variation": "on", "weight": 100}]}},
+			{"clauses": [{"attribute": "plan", "op": "in", "values": ["team"]}],
+			 "rollout": {"variations": [{"variation": "maybe", "weight": 50}, {"variation": "off", "weight": 120}]}}
+		],
+		"default": {"rollout": {"variations": [{"variation": "on", "weight": 33.3}, {"variation": "off", "weight": 66.6}]}}
+	}`), &f)
+
+	want := []string{
+		"default: rollout weights add up to 99.9%, not 100%",
+		"rules[0]: set either a variation or a rollout, not both",
+		"rules[1].rollout.variations[0]: unknown variation 'maybe'",
+		"rules[1].rollout.variations[1]: weight must be between 0 and 100, got 120",
+		"rules[1]: rollout weights add up to 170%, not 100%",
+	}
+	if got := f.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package flags
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Ruleset is everything needed to evaluate an environment's flags and assign
+// its experiments in process: every flag and experiment, and the project's
+// segments, as one document.
+type Ruleset struct {
+	Version     string       `json:"version"` // Content hash; changes whenever a flag, segment or experiment does
+	EnvID       int          `json:"env_id"`
+	Flags       []Flag       `json:"flags"`
+	Segments    []Segment    `json:"segments,omitempty"`
+	Experiments []Experiment `json:"experiments,omitempty"`
+
+	once        sync.Once
+	index       map[string]*Flag
+	segments    Segments
+	experiments map[string]*Experiment
+}
+
+// NewRuleset assembles a ruleset and computes its version. Flags, segments
+// and experiments should be in a stable order, such as by key, so that
+// unchanged rules keep their version.
+func NewRuleset(envID int, fs []Flag, segments []Segment, experiments []Experiment) *Ruleset {
+	if fs == nil {
+		fs = []Flag{}
+	}
+	rs := &Ruleset{EnvID: envID, Flags: fs, Segments: segments, Experiments: experiments}
+	b, _ := json.Marshal(struct {
+		EnvID       int          `json:"env_id"`
+		Flags       []Flag       `json:"flags"`
+		Segments    []Segment    `json:"segments"`
+		Experiments []Experiment `json:"experiments,omitempty"`
+	}{envID, fs, segments, experiments})
+	sum := sha256.Sum256(b)
+	rs.Version = hex.EncodeToString(sum[:8])
+	return rs
+}
+
+// Flag returns the flag with the given key, or nil.
+func (rs *Ruleset) Flag(key string) *Flag {
+	rs.prepare()
+	return rs.index[key]
+}
+
+// Evaluate evaluates a flag of the ruleset for ctx. An unknown flag is an
+// ERROR result.
+func (rs *Ruleset) Evaluate(key string, ctx Context) Result {
+	f := rs.Flag(key)
+	if f == nil {
+		return Result{Key: key, Reason: Reason{Kind: ReasonError, Error: fmt.Sprintf("flag '%s' not found", key)}}
+	}
+	return EvaluateWithSegments(f, ctx, rs.segments)
+}
+
+// Assign assigns ctx to an arm of an experiment of the ruleset. An unknown
+// experiment is an ERROR assignment.
+func (rs *Ruleset) Assign(key string, ctx Context) Assignment {
+	rs.prepare()
+	e := rs.experiments[key]
+	if e == nil {
+		return Assignment{Experiment: key, Reason: AssignError, Error: fmt.Sprintf("experiment '%s' not found", key)}
+	}
+	return e.Assign(ctx)
+}
+
+// prepare indexes flags, segments and experiments by key, once. A ruleset
+// must not be modified after its first evaluation; it is then safe for
+// concurrent use.
+func (rs *Ruleset) prepare() {
+	rs.once.Do(rs.buildIndex)
+}
+
+func (rs *Ruleset) buildIndex() {
+	rs.index = make(map[string]*Flag, len(rs.Flags))
+	for i := range rs.Flags {
+		rs.Flags[i].Prepare()
+		rs.index[rs.Flags[i].Key] = &rs.Flags[i]
+	}
+	rs.segments = make(Segments, len(rs.Segments))
+	for i := range rs.Segments {
+		rs.Segments[i].Prepare()
+		rs.segments[rs.Segments[i].Key] = &rs.Segments[i]
+	}
+	rs.experiments = make(map[string]*Experiment, len(rs.Experiments))
+	for i := range rs.Experiments {
+		rs.experiments[rs.Experiments[i].Key] = &rs.Experiments[i]
+	}
+}
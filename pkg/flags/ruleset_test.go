@@ -0,0 +1,84 @@
+package flags
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRuleset(t *testing.T) {
+	checkoutFlag := *mustFlag(t, checkout)
+	segment := mustSegment(t, `{"key": "beta", "included": ["user-1"]}`)
+	beta := *mustFlag(t, `{
+		"key": "beta-banner", "on": true,
+		"variations": [{"name": "hidden", "value": false}, {"name": "shown", "value": true}],
+		"off_variation": "hidden",
+		"rules": [{"clauses": [{"op": "segment_match", "values": ["beta"]}], "variation": "shown"}],
+		"default": {"variation": "hidden"}
+	}`)
+
+	rs := NewRuleset(1, []Flag{beta, checkoutFlag}, []Segment{*segment}, nil)
+	if len(rs.Version) != 16 {
+		t.Fatalf("Version = %q, want 16 hex digits", rs.Version)
+	}
+	if again := NewRuleset(1, []Flag{beta, checkoutFlag}, []Segment{*segment}, nil); again.Version != rs.Version {
+		t.Errorf("same rules got versions %s and %s", rs.Version, again.Version)
+	}
+	segment.Included = append(segment.Included, "user-2")
+	if changed := NewRuleset(1, []Flag{beta, checkoutFlag}, []Segment{*segment}, nil); changed.Version == rs.Version {
+		t.Errorf("changing a segment kept version %s", rs.Version)
+	}
+
+	// A ruleset sent over the wire evaluates like the flags it holds.
+	b, _ := json.Marshal(rs)
+	var decoded Ruleset
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if res := decoded.Evaluate("beta-banner", Context{"key": "user-1"}); res.Variation != "shown" {
+		t.Errorf("beta-banner for user-1 = %+v", res)
+	}
+	if res := decoded.Evaluate("new-checkout", Context{"key": "u1", "email": "ana@example.com"}); res.Variation != "on" || res.Reason.RuleID != "staff" {
+		t.Errorf("new-checkout for staff = %+v", res)
+	}
+	if res := decoded.Evaluate("missing", Context{}); res.Reason.Kind != ReasonError || res.Reason.Error != "flag 'missing' not found" {
+		t.Errorf("missing flag = %+v", res)
+	}
+}
+
+func TestRulesetMatches(t *testing.T) {
+	segment := mustSegment(t, `{"key": "staff", "rules": [{"clauses": [{"attribute": "email", "op": "matches", "values": ["@example\\.com$"]}]}]}`)
+	flag := *mustFlag(t, `{
+		"key": "banner", "on": true,
+		"variations": [{"name": "hidden", "value": false}, {"name": "shown", "value": true}],
+		"off_variation": "hidden",
+		"rules": [
+			{"clauses": [{"attribute": "plan", "op": "matches", "values": ["^ent", "^pro$"]}], "variation": "shown"},
+			{"clauses": [{"op": "segment_match", "values": ["staff"]}], "variation": "shown"}
+		],
+		"default": {"variation": "hidden"}
+	}`)
+
+	rs := NewRuleset(1, []Flag{flag}, []Segment{*segment}, nil)
+	prepared, preparedSegment := flag, *segment
+	prepared.Prepare()
+	preparedSegment.Prepare()
+	for _, tc := range []struct {
+		ctx  Context
+		want string
+	}{
+		{Context{"key": "u1", "plan": "pro"}, "shown"},
+		{Context{"key": "u1", "plan": "enterprise"}, "shown"},
+		{Context{"key": "u1", "plan": "professional"}, "hidden"},
+		{Context{"key": "u1", "plan": 3}, "hidden"},
+		{Context{"key": "u1", "email": "ana@example.com"}, "shown"},
+		{Context{"key": "u1", "email": "ana@example.org"}, "hidden"},
+	} {
+		if res := rs.Evaluate("banner", tc.ctx); res.Variation != tc.want {
+			t.Errorf("Evaluate(%v) = %+v, want %s", tc.ctx, res, tc.want)
+		}
+		// The server prepares flags and segments without a ruleset.
+		if res := EvaluateWithSegments(&prepared, tc.ctx, Segments{"staff": &preparedSegment}); res.Variation != tc.want {
+			t.Errorf("prepared flag: Evaluate(%v) = %+v, want %s", tc.ctx, res, tc.want)
+		}
+	}
+}
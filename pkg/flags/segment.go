@@ -0,0 +1,131 @@
+package flags
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OpSegmentMatch matches contexts in any of the segments named by the clause
+// values. It ignores the clause attribute.
+const OpSegmentMatch = "segment_match"
+
+// Segment is a reusable group of contexts, such as internal employees or beta
+// customers, that flag rules target with a segment_match clause. A context is
+// in the segment if its key is included, or else if its key is not excluded
+// and any rule matches it.
+type Segment struct {
+	Key         string        `json:"key"`
+	Description string        `json:"description,omitempty"`
+	Included    []string      `json:"included,omitempty"` // Context keys always in the segment
+	Excluded    []string      `json:"excluded,omitempty"` // Context keys kept out of its rules
+	Rules       []SegmentRule `json:"rules,omitempty"`
+	Version     int           `json:"version,omitempty"` // Set by the server on every change
+}
+
+// SegmentRule adds the contexts matching all of its clauses to a segment.
+type SegmentRule struct {
+	Description string   `json:"description,omitempty"`
+	Clauses     []Clause `json:"clauses"`
+}
+
+// Segments holds the segments a flag may refer to, by key.
+type Segments map[string]*Segment
+
+// Contains reports whether ctx is in the segment.
+func (s *Segment) Contains(ctx Context) bool {
+	key, hasKey := BucketValue(ctx[KeyAttribute])
+	if hasKey {
+		if contains(s.Included, key) {
+			return true
+		}
+		if contains(s.Excluded, key) {
+			return false
+		}
+	}
+	for _, r := range s.Rules {
+		matched := len(r.Clauses) > 0
+		for _, c := range r.Clauses {
+			// Segment rules can't refer to segments, so this can't fail.
+			if ok, _ := c.matches(ctx, nil); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Prepare compiles the regular expressions of the segment's matches clauses,
+// like Flag.Prepare.
+func (s *Segment) Prepare() {
+	for i := range s.Rules {
+		prepareClauses(s.Rules[i].Clauses)
+	}
+}
+
+// Validate lists the problems of a segment definition.
+func (s *Segment) Validate() []string {
+	var errs []string
+	if !ValidKey(s.Key) {
+		errs = append(errs, fmt.Sprintf("invalid segment key %q", s.Key))
+	}
+	for i, key := range s.Included {
+		if key == "" {
+			errs = append(errs, fmt.Sprintf("included[%d] is empty", i))
+		}
+	}
+	for i, key := range s.Excluded {
+		if key == "" {
+			errs = append(errs, fmt.Sprintf("excluded[%d] is empty", i))
+		}
+	}
+	for i, r := range s.Rules {
+		where := fmt.Sprintf("rules[%d]", i)
+		if len(r.Clauses) == 0 {
+			errs = append(errs, where+": rule needs at least one clause")
+		}
+		for j, c := range r.Clauses {
+			cwhere := fmt.Sprintf("%s.clauses[%d]", where, j)
+			if c.Op == OpSegmentMatch {
+				errs = append(errs, cwhere+": segments can't refer to other segments")
+				continue
+			}
+			errs = append(errs, c.validate(cwhere)...)
+		}
+	}
+	return errs
+}
+
+// SegmentKeys returns the keys of the segments the flag's rules refer to,
+// sorted.
+func (f *Flag) SegmentKeys() []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, r := range f.Rules {
+		for _, c := range r.Clauses {
+			if c.Op != OpSegmentMatch {
+				continue
+			}
+			for _, v := range c.Values {
+				if key, ok := v.(string); ok && !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package flags
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func mustSegment(t *testing.T, src string) *Segment {
+	t.Helper()
+	var s Segment
+	if err := json.Unmarshal([]byte(src), &s); err != nil {
+		t.Fatalf("decode segment: %v", err)
+	}
+	if errs := s.Validate(); len(errs) > 0 {
+		t.Fatalf("segment is invalid: %v", errs)
+	}
+	return &s
+}
+
+func TestSegmentMatch(t *testing.T) {
+	segments := Segments{
+		"employees": mustSegment(t, `{
+			"key": "employees",
+			"included": ["contractor-7"],
+			"excluded": ["ana"],
+			"rules": [{"clauses": [{"attribute": "email", "op": "ends_with", "values": ["@example.com"]}]}]
+		}`),
+		"beta": mustSegment(t, `{"key": "beta", "included": ["user-1", "user-2"]}`),
+	}
+	f := mustFlag(t, `{
+		"key": "new-checkout", "on": true,
+		"variations": [{"name": "off", "value": false}, {"name": "on", "value": true}],
+		"off_variation": "off",
+		"rules": [
+			{"id": "internal", "clauses": [{"op": "segment_match", "values": ["employees", "beta"]}], "variation": "on"},
+			{"id": "not-beta", "clauses": [
+				{"attribute": "plan", "op": "in", "values": ["pro"]},
+				{"op": "segment_match", "values": ["beta"], "negate": true}
+			], "variation": "off"}
+		],
+		"default": {"variation": "on"}
+	}`)
+
+	tests := []struct {
+		name      string
+		ctx       Context
+		variation string
+		ruleID    string
+	}{
+		{"matched by rule", Context{"key": "bo", "email": "bo@example.com"}, "on", "internal"},
+		{"included", Context{"key": "contractor-7", "email": "c7@agency.test"}, "on", "internal"},
+		{"excluded despite rule", Context{"key": "ana", "email": "ana@example.com", "plan": "pro"}, "off", "not-beta"},
+		{"in the second segment", Context{"key": "user-2"}, "on", "internal"},
+		{"negated segment", Context{"key": "user-9", "plan": "pro"}, "off", "not-beta"},
+		{"outside both", Context{"key": "user-9"}, "on", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := EvaluateWithSegments(f, tt.ctx, segments)
+			if res.Variation != tt.variation || res.Reason.RuleID != tt.ruleID {
+				t.Errorf("got %s (%s %s), want %s (%s)", res.Variation, res.Reason.Kind, res.Reason.RuleID, tt.variation, tt.ruleID)
+			}
+		})
+	}
+
+	if keys := f.SegmentKeys(); !reflect.DeepEqual(keys, []string{"beta", "employees"}) {
+		t.Errorf("SegmentKeys() = %v", keys)
+	}
+	res := Evaluate(f, Context{"key": "user-1"})
+	if res.Reason.Kind != ReasonError || res.Reason.Error != "unknown segment 'employees'" {
+		t.Errorf("Evaluate without segments: got %+v", res)
+	}
+}
+
+func TestValidateSegment(t *testing.T) {
+	var s Segment
+	json.Unmarshal([]byte(`{
+		"key": "",
+		"included": [""],
+		"rules": [
+			{"clauses": []},
+			{"clauses": [{"op": "segment_match", "values": ["beta"]}, {"attribute": "plan", "op": "gt", "values": ["pro"]}]}
+		]
+	}`), &s)
+
+	want := []string{
+		`invalid segment key ""`,
+		"included[0] is empty",
+		"rules[0]: rule needs at least one clause",
+		"rules[1].clauses[0]: segments can't refer to other segments",
+		"rules[1].clauses[1]: gt needs numeric values, got pro",
+	}
+	if got := s.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package flags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version (https://semver.org). Build metadata is
+// ignored when comparing.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 []string // Pre-release identifiers, e.g. ["rc", "1"]
+}
+
+// ParseVersion parses MAJOR[.MINOR[.PATCH]][-PRERELEASE][+BUILD]. A leading
+// "v" and missing minor or patch numbers ("v2", "1.4") are accepted, since app
+// versions are often reported that way.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.Pre = strings.Split(rest[i+1:], ".")
+		rest = rest[:i]
+		for _, id := range v.Pre {
+			if id == "" {
+				return Version{}, fmt.Errorf("invalid version %q: empty pre-release identifier", s)
+			}
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 || rest == "" {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	nums := [3]int{}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than w.
+// A pre-release is lower than its release: 1.0.0-rc.1 < 1.0.0.
+func (v Version) Compare(w Version) int {
+	for _, d := range []int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.Pre) == 0 && len(w.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(w.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(w.Pre); i++ {
+		if c := comparePre(v.Pre[i], w.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.Pre) - len(w.Pre))
+}
+
+// comparePre orders pre-release identifiers: numeric ones numerically and
+// below alphanumeric ones, which compare as strings.
+func comparePre(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(na - nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
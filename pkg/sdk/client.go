@@ -0,0 +1,214 @@
+// Package sdk is the Go client for reading configs and evaluating feature
+// flags on a Configra server.
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config is a single config version as served by GET /v1/configs.
+type Config struct {
+	ID        int                    `json:"id"`
+	ProjectID int                    `json:"project_id"`
+	EnvID     int                    `json:"env_id"`
+	Key       string                 `json:"key"`
+	Version   int                    `json:"version"`
+	Data      map[string]interface{} `json:"data"`
+	Schema    map[string]interface{} `json:"schema"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	// Warnings lists deprecated fields set in the config, with their
+	// replacement and sunset date.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Stale is true when the config was loaded from a local snapshot
+	// because the server could not be reached.
+	Stale bool `json:"-"`
+	// FetchedAt is when the config was last retrieved from the server.
+	FetchedAt time.Time `json:"-"`
+}
+
+// StaleEvent describes a fallback to a local snapshot.
+type StaleEvent struct {
+	EnvID   int
+	Key     string
+	Version int
+	Age     time.Duration // Time since the snapshot was fetched from the server
+	Err     error         // The error that caused the fallback
+}
+
+// Client reads configs from the Configra API using a project API key.
+type Client struct {
+	BaseURL string
+	APIKey  string
+	Timeout time.Duration
+
+	// Defaults asks the server to fill in schema defaults for fields that
+	// were not written. Stored versions only hold the written values.
+	Defaults bool
+
+	// SnapshotDir, when set, enables offline fallback: every successful fetch
+	// is persisted there and served back when the API is unreachable.
+	SnapshotDir string
+	// OnStale is called whenever a snapshot is served instead of live data.
+	OnStale func(StaleEvent)
+	// OnSnapshotError is called when a fetched config can't be persisted to
+	// SnapshotDir. Get still returns the config; only the fallback is lost.
+	OnSnapshotError func(error)
+
+	mu    sync.Mutex
+	stale map[string]time.Time // snapshot key -> FetchedAt of the snapshot being served
+}
+
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		Timeout: 5 * time.Second,
+	}
+}
+
+// APIError is returned when the server answers with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("configra: server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Get fetches the latest version of a config key. If the server is
+// unreachable (or failing) and a snapshot exists, the snapshot is returned
+// with Stale set and OnStale is notified. Failing to persist a fetched
+// config is reported to OnSnapshotError, not returned.
+func (c *Client) Get(envID int, key string) (*Config, error) {
+	cfg, err := c.fetch(envID, key)
+	if err == nil {
+		c.markFresh(envID, key)
+		if c.SnapshotDir != "" {
+			if serr := writeSnapshot(c.SnapshotDir, c.snapshotName(envID, key), cfg); serr != nil && c.OnSnapshotError != nil {
+				c.OnSnapshotError(fmt.Errorf("configra: failed to write snapshot: %w", serr))
+			}
+		}
+		return cfg, nil
+	}
+
+	if c.SnapshotDir == "" || !isUnavailable(err) {
+		return nil, err
+	}
+
+	snap, serr := readSnapshot(c.SnapshotDir, c.snapshotName(envID, key), envID, key)
+	if serr != nil {
+		return nil, fmt.Errorf("%w (no usable snapshot: %v)", err, serr)
+	}
+	c.markStale(snap, err)
+	return snap, nil
+}
+
+// Staleness reports how old the config currently served for envID/key is.
+// It returns 0 when the last Get was answered by the server.
+func (c *Client) Staleness(envID int, key string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fetchedAt, ok := c.stale[c.snapshotName(envID, key)]
+	if !ok {
+		return 0
+	}
+	return time.Since(fetchedAt)
+}
+
+func (c *Client) fetch(envID int, key string) (*Config, error) {
+	q := url.Values{}
+	q.Set("env_id", strconv.Itoa(envID))
+	q.Set("key", key)
+	if c.Defaults {
+		q.Set("defaults", "true")
+	}
+
+	var cfg Config
+	if err := c.do(http.MethodGet, "/v1/configs?"+q.Encode(), nil, &cfg); err != nil {
+		return nil, err
+	}
+	cfg.FetchedAt = time.Now().UTC()
+	return &cfg, nil
+}
+
+// do performs a request against the API and decodes a JSON response into out.
+func (c *Client) do(method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: c.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("configra: server unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("configra: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// isUnavailable reports whether err means the server could not serve the
+// request at all, as opposed to rejecting it (bad key, missing config).
+func isUnavailable(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return true
+	}
+	return apiErr.StatusCode >= 500
+}
+
+func (c *Client) markFresh(envID int, key string) {
+	c.mu.Lock()
+	delete(c.stale, c.snapshotName(envID, key))
+	c.mu.Unlock()
+}
+
+func (c *Client) markStale(cfg *Config, cause error) {
+	c.mu.Lock()
+	if c.stale == nil {
+		c.stale = make(map[string]time.Time)
+	}
+	c.stale[c.snapshotName(cfg.EnvID, cfg.Key)] = cfg.FetchedAt
+	c.mu.Unlock()
+
+	if c.OnStale != nil {
+		c.OnStale(StaleEvent{
+			EnvID:   cfg.EnvID,
+			Key:     cfg.Key,
+			Version: cfg.Version,
+			Age:     time.Since(cfg.FetchedAt),
+			Err:     cause,
+		})
+	}
+}
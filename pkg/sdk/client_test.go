@@ -0,0 +1,173 @@
+package sdk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+func TestGetFallsBackToSnapshot(t *testing.T) {
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"env_id": 1, "key": "app", "version": 3, "data": {"mode": "release"}}`))
+	}))
+	defer srv.Close()
+
+	var events []StaleEvent
+	c := NewClient(srv.URL, "key")
+	c.SnapshotDir = t.TempDir()
+	c.OnStale = func(e StaleEvent) { events = append(events, e) }
+
+	cfg, err := c.Get(1, "app")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cfg.Stale || cfg.Version != 3 {
+		t.Fatalf("Get() = %+v, want fresh version 3", cfg)
+	}
+
+	up = false
+	cfg, err = c.Get(1, "app")
+	if err != nil {
+		t.Fatalf("Get() with server down error = %v", err)
+	}
+	if !cfg.Stale || cfg.Version != 3 || cfg.Data["mode"] != "release" {
+		t.Errorf("Get() = %+v, want stale snapshot of version 3", cfg)
+	}
+	if len(events) != 1 || events[0].Version != 3 {
+		t.Errorf("OnStale events = %+v, want one for version 3", events)
+	}
+	if c.Staleness(1, "app") <= 0 {
+		t.Errorf("Staleness() = 0, want > 0 while serving a snapshot")
+	}
+
+	// A tampered snapshot must not be served.
+	path := filepath.Join(c.SnapshotDir, c.snapshotName(1, "app"))
+	b, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(b), "release", "debug", 1)), 0o644)
+	if _, err := c.Get(1, "app"); err == nil {
+		t.Errorf("Get() with tampered snapshot succeeded, want error")
+	}
+}
+
+func TestSnapshotsAreScopedToServerAndEnvironment(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"env_id": 1, "key": "app", "version": 3, "data": {"mode": "release"}}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	a := NewClient(srv.URL, "project-a")
+	a.SnapshotDir = dir
+	if _, err := a.Get(1, "app"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	// A client of another project sharing the directory has no snapshot.
+	b := NewClient(srv.URL, "project-b")
+	b.SnapshotDir = dir
+	if a.snapshotName(1, "app") == b.snapshotName(1, "app") {
+		t.Fatalf("projects share snapshot name %s", a.snapshotName(1, "app"))
+	}
+	if _, err := readSnapshot(dir, b.snapshotName(1, "app"), 1, "app"); err == nil {
+		t.Error("project-b read project-a's snapshot")
+	}
+
+	// A snapshot of another environment is not served in its place.
+	if _, err := readSnapshot(dir, a.snapshotName(1, "app"), 2, "app"); err == nil {
+		t.Error("snapshot of environment 1 served for environment 2")
+	}
+}
+
+func TestGetReportsSnapshotWriteErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"env_id": 1, "key": "app", "version": 3, "data": {"mode": "release"}}`))
+	}))
+	defer srv.Close()
+
+	var errs []error
+	c := NewClient(srv.URL, "key")
+	c.SnapshotDir = filepath.Join(t.TempDir(), "file")
+	os.WriteFile(c.SnapshotDir, nil, 0o644) // not a directory
+	c.OnSnapshotError = func(err error) { errs = append(errs, err) }
+
+	cfg, err := c.Get(1, "app")
+	if err != nil || cfg.Version != 3 {
+		t.Fatalf("Get() = %+v, %v, want version 3 and no error", cfg, err)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "failed to write snapshot") {
+		t.Errorf("OnSnapshotError calls = %v, want one write failure", errs)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/flags/new-checkout/evaluate" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"key": "new-checkout", "variation": "on", "value": true, "reason": {"kind": "TARGETING_MATCH", "rule_index": 0, "rule_id": "staff"}}`))
+	}))
+	defer srv.Close()
+
+	res, err := NewClient(srv.URL, "key").Evaluate(2, "new-checkout", flags.Context{"key": "u1", "plan": "pro"})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if res.Value != true || res.Reason.Kind != flags.ReasonTargetingMatch || res.Reason.RuleID != "staff" {
+		t.Errorf("Evaluate() = %+v", res)
+	}
+	ctx, _ := body["context"].(map[string]interface{})
+	if body["env_id"] != float64(2) || ctx["plan"] != "pro" {
+		t.Errorf("request body = %v", body)
+	}
+}
+
+// TestLocalEvaluationMatchesVectors checks that a flag fetched through the SDK
+// evaluates locally to the variations the server serves for the shared
+// bucketing test vectors.
+func TestLocalEvaluationMatchesVectors(t *testing.T) {
+	b, err := os.ReadFile("../flags/testdata/rollout_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors struct {
+		Flag        json.RawMessage `json:"flag"`
+		Evaluations []struct {
+			Context   flags.Context `json:"context"`
+			Variation string        `json:"variation"`
+		} `json:"evaluations"`
+	}
+	if err := json.Unmarshal(b, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/flags/new-checkout" || r.URL.Query().Get("env_id") != "1" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write(vectors.Flag)
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.URL, "key").Flag(1, "new-checkout")
+	if err != nil {
+		t.Fatalf("Flag() error = %v", err)
+	}
+	for _, tt := range vectors.Evaluations {
+		if got := flags.Evaluate(f, tt.Context); got.Variation != tt.Variation {
+			t.Errorf("Evaluate(%v) = %s, want %s", tt.Context, got.Variation, tt.Variation)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultExposureInterval is how often LocalFlags sends exposure events.
+const DefaultExposureInterval = 10 * time.Second
+
+// maxBufferedExposures bounds the exposures kept while a sink is failing;
+// the oldest are dropped beyond it.
+const maxBufferedExposures = 100000
+
+// Exposure records that a unit was assigned an arm of an experiment, for
+// the analytics pipeline that measures it.
+type Exposure struct {
+	Experiment string    `json:"experiment"`
+	Layer      string    `json:"layer,omitempty"`
+	Version    int       `json:"version"` // Experiment version the unit was assigned with
+	Arm        string    `json:"arm"`
+	EnvID      int       `json:"env_id"`
+	Unit       string    `json:"unit"` // The bucketed attribute value
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ExposureSink receives batches of exposure events. Send is never called
+// concurrently by LocalFlags. A batch it fails to send is retried with the
+// next one.
+type ExposureSink interface {
+	Send([]Exposure) error
+}
+
+// FileSink appends exposures to a file as JSON lines.
+type FileSink struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileSink) Send(exposures []Exposure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range exposures {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HTTPSink posts each batch of exposures to URL as a JSON array.
+type HTTPSink struct {
+	URL    string
+	Header http.Header // Extra request headers, e.g. Authorization
+	// Client defaults to an http.Client with a 10 second timeout.
+	Client *http.Client
+}
+
+func (s *HTTPSink) Send(exposures []Exposure) error {
+	b, err := json.Marshal(exposures)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("configra: exposure sink unreachable: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("configra: exposure sink returned %s", resp.Status)
+	}
+	return nil
+}
+
+// exposureBuffer collects exposures between batches. A unit seen again in
+// the same arm before the batch is sent is only recorded once.
+type exposureBuffer struct {
+	mu   sync.Mutex
+	list []Exposure
+	seen map[string]bool
+}
+
+func (b *exposureBuffer) add(e Exposure) {
+	id := fmt.Sprintf("%s\x00%d\x00%s\x00%s", e.Experiment, e.Version, e.Arm, e.Unit)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.seen[id] {
+		return
+	}
+	if b.seen == nil {
+		b.seen = make(map[string]bool)
+	}
+	b.seen[id] = true
+	b.list = append(b.list, e)
+}
+
+// drain returns the buffered exposures and empties the buffer.
+func (b *exposureBuffer) drain() []Exposure {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := b.list
+	b.list, b.seen = nil, nil
+	return list
+}
+
+// requeue puts back a batch that could not be sent, ahead of the exposures
+// buffered since, keeping the newest maxBufferedExposures.
+func (b *exposureBuffer) requeue(batch []Exposure) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.list = append(batch, b.list...)
+	if n := len(b.list) - maxBufferedExposures; n > 0 {
+		b.list = b.list[n:]
+	}
+}
@@ -0,0 +1,147 @@
+package sdk
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+type memorySink struct {
+	mu    sync.Mutex
+	fail  error
+	sent  []Exposure
+	calls int
+}
+
+func (s *memorySink) Send(exposures []Exposure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.fail != nil {
+		return s.fail
+	}
+	s.sent = append(s.sent, exposures...)
+	return nil
+}
+
+func TestLocalFlagsRecordExposures(t *testing.T) {
+	exp := flags.Experiment{
+		Key:     "checkout-button",
+		Running: true,
+		Layer:   "checkout",
+		Traffic: flags.Allocation{Start: 0, End: 100},
+		Arms:    []flags.Arm{{Name: "control", Weight: 50}, {Name: "green", Weight: 50}},
+		Version: 3,
+	}
+	rs := flags.NewRuleset(1, nil, nil, []flags.Experiment{exp})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/ruleset" {
+			json.NewEncoder(w).Encode(rs)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	sink := &memorySink{fail: errors.New("pipeline down")}
+	local := NewClient(srv.URL, "key").LocalFlags(1)
+	local.CountInterval = -1
+	local.Exposures = sink
+	local.ExposureInterval = time.Hour // Only the batches sent explicitly and by Close
+	var failures int
+	local.OnError = func(error) { failures++ }
+	if err := local.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	a := local.Assign("checkout-button", flags.Context{"key": "user-1"})
+	if !a.InExperiment() || a.Version != 3 || a.Unit != "user-1" {
+		t.Fatalf("Assign() = %+v", a)
+	}
+	local.Assign("checkout-button", flags.Context{"key": "user-1"}) // Same unit and arm: one exposure
+	local.Assign("nope", flags.Context{"key": "user-2"})
+
+	local.sendExposures() // Fails; kept for the next batch
+	local.Assign("checkout-button", flags.Context{"key": "user-2"})
+	local.Close() // Fails again
+
+	if failures != 2 || sink.calls != 2 {
+		t.Errorf("got %d failures in %d sends, want 2", failures, sink.calls)
+	}
+	if len(sink.sent) != 0 {
+		t.Fatalf("failing sink received %v", sink.sent)
+	}
+
+	sink.fail = nil
+	local.sendExposures()
+	if len(sink.sent) != 2 || sink.sent[0].Unit != "user-1" || sink.sent[1].Unit != "user-2" {
+		t.Fatalf("sent %+v, want user-1 then user-2", sink.sent)
+	}
+	if e := sink.sent[0]; e.Experiment != "checkout-button" || e.Layer != "checkout" || e.Arm != a.Arm || e.EnvID != 1 || e.Timestamp.IsZero() {
+		t.Errorf("exposure = %+v", e)
+	}
+}
+
+func TestExposureSinks(t *testing.T) {
+	batch := []Exposure{
+		{Experiment: "a", Version: 1, Arm: "control", EnvID: 1, Unit: "user-1", Timestamp: time.Unix(0, 0).UTC()},
+		{Experiment: "a", Version: 1, Arm: "treatment", EnvID: 1, Unit: "user-2", Timestamp: time.Unix(0, 0).UTC()},
+	}
+
+	path := filepath.Join(t.TempDir(), "exposures.jsonl")
+	file := &FileSink{Path: path}
+	for i := 0; i < 2; i++ {
+		if err := file.Send(batch); err != nil {
+			t.Fatalf("FileSink.Send() error = %v", err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []Exposure
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Exposure
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, e)
+	}
+	if len(lines) != 4 || lines[3] != batch[1] {
+		t.Errorf("file holds %+v, want the batch twice", lines)
+	}
+
+	var got []Exposure
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("missing Authorization header")
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := &HTTPSink{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer token"}}}
+	if err := sink.Send(batch); err != nil {
+		t.Fatalf("HTTPSink.Send() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != batch[0] {
+		t.Errorf("server received %+v", got)
+	}
+	status = http.StatusServiceUnavailable
+	if err := sink.Send(batch); err == nil {
+		t.Error("HTTPSink.Send() ignored a 503")
+	}
+}
@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// Evaluate asks the server which variation of a flag it serves to ctx.
+func (c *Client) Evaluate(envID int, key string, ctx flags.Context) (*flags.Result, error) {
+	var res flags.Result
+	if err := c.postJSON("/v1/flags/"+url.PathEscape(key)+"/evaluate", envID, ctx, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// EvaluateAll evaluates every flag of an environment for ctx, keyed by flag.
+func (c *Client) EvaluateAll(envID int, ctx flags.Context) (map[string]flags.Result, error) {
+	var list []flags.Result
+	if err := c.postJSON("/v1/evaluate", envID, ctx, &list); err != nil {
+		return nil, err
+	}
+	results := make(map[string]flags.Result, len(list))
+	for _, res := range list {
+		results[res.Key] = res
+	}
+	return results, nil
+}
+
+// Assign asks the server which arm of an experiment it assigns ctx to. No
+// exposure is recorded; use LocalFlags.Assign for that.
+func (c *Client) Assign(envID int, key string, ctx flags.Context) (*flags.Assignment, error) {
+	var a flags.Assignment
+	if err := c.postJSON("/v1/experiments/"+url.PathEscape(key)+"/assign", envID, ctx, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (c *Client) postJSON(path string, envID int, ctx flags.Context, out interface{}) error {
+	b, err := json.Marshal(map[string]interface{}{"env_id": envID, "context": ctx})
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, path, bytes.NewReader(b), out)
+}
+
+// Flag fetches a flag definition. Evaluating it with flags.Evaluate gives
+// exactly the server's answer, percentage rollouts included, without a round
+// trip per evaluation.
+func (c *Client) Flag(envID int, key string) (*flags.Flag, error) {
+	var f flags.Flag
+	path := "/v1/flags/" + url.PathEscape(key) + "?env_id=" + strconv.Itoa(envID)
+	if err := c.do(http.MethodGet, path, nil, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Segments fetches the latest version of the project's segments, for
+// evaluating flags with segment_match clauses via flags.EvaluateWithSegments.
+func (c *Client) Segments() (flags.Segments, error) {
+	var list []flags.Segment
+	if err := c.do(http.MethodGet, "/v1/segments", nil, &list); err != nil {
+		return nil, err
+	}
+	segments := make(flags.Segments, len(list))
+	for i := range list {
+		segments[list[i].Key] = &list[i]
+	}
+	return segments, nil
+}
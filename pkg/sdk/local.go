@@ -0,0 +1,334 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+// streamIdleTimeout drops a ruleset stream that has been silent this long.
+// The server sends a keep-alive at least every 10 seconds.
+const streamIdleTimeout = time.Minute
+
+// DefaultCountInterval is how often LocalFlags reports evaluation counts.
+const DefaultCountInterval = time.Minute
+
+// maxCountsPerRequest matches the server's limit on counts per report.
+const maxCountsPerRequest = 10000
+
+// LocalFlags evaluates an environment's flags in process from its ruleset,
+// which a background stream keeps up to date. Evaluations give exactly the
+// server's answers without a round trip. They are counted per flag and
+// variation, and the counts sent to the server in batches, so that its stale
+// flag report knows which flags are still checked. Experiments in the
+// ruleset are assigned the same way, and each exposure to an arm is sent to
+// the Exposures sink.
+type LocalFlags struct {
+	// OnUpdate is called after a new ruleset version has been installed.
+	OnUpdate func(version string)
+	// OnError is called when the update stream fails, or a batch of counts
+	// can't be sent. The stream reconnects on its own, with backoff, and
+	// evaluations keep using the last ruleset meanwhile; unsent counts are
+	// retried with the next batch.
+	OnError func(error)
+	// CountInterval is how often evaluation counts are sent; 0 means
+	// DefaultCountInterval, and a negative value turns counting off. Set it
+	// before Start.
+	CountInterval time.Duration
+	// Exposures receives an event for every unit Assign puts in an
+	// experiment arm; nil means exposures are not recorded. Failed batches
+	// are reported to OnError and retried. Set it before Start.
+	Exposures ExposureSink
+	// ExposureInterval is how often exposures are sent; 0 means
+	// DefaultExposureInterval.
+	ExposureInterval time.Duration
+
+	client    *Client
+	envID     int
+	ruleset   atomic.Pointer[flags.Ruleset]
+	counts    flags.Counter
+	exposures exposureBuffer
+
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// LocalFlags returns a local evaluator for an environment. Set its callbacks,
+// then call Start.
+func (c *Client) LocalFlags(envID int) *LocalFlags {
+	return &LocalFlags{client: c, envID: envID}
+}
+
+// Start loads the ruleset and starts streaming updates. It fails if the
+// first ruleset can't be loaded.
+func (l *LocalFlags) Start() error {
+	var rs flags.Ruleset
+	if err := l.client.do(http.MethodGet, "/v1/ruleset?env_id="+strconv.Itoa(l.envID), nil, &rs); err != nil {
+		return err
+	}
+	l.install(&rs)
+
+	l.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		l.cancel = cancel
+		l.wg.Add(1)
+		go l.stream(ctx)
+		if l.CountInterval >= 0 {
+			l.wg.Add(1)
+			go l.report(ctx)
+		}
+		if l.Exposures != nil {
+			l.wg.Add(1)
+			go l.expose(ctx)
+		}
+	})
+	return nil
+}
+
+// Close stops the update stream and sends the remaining evaluation counts
+// and exposures.
+func (l *LocalFlags) Close() {
+	if l.cancel != nil {
+		l.cancel()
+		l.wg.Wait()
+	}
+}
+
+// Evaluate evaluates a flag for ctx against the current ruleset.
+func (l *LocalFlags) Evaluate(key string, ctx flags.Context) flags.Result {
+	rs := l.ruleset.Load()
+	if rs == nil {
+		return flags.Result{Key: key, Reason: flags.Reason{Kind: flags.ReasonError, Error: "ruleset not loaded; call Start"}}
+	}
+	res := rs.Evaluate(key, ctx)
+	if l.CountInterval >= 0 && rs.Flag(key) != nil {
+		l.counts.Add(res, time.Now())
+	}
+	return res
+}
+
+// Assign assigns ctx to an arm of an experiment against the current
+// ruleset, and records an exposure when it is in one.
+func (l *LocalFlags) Assign(key string, ctx flags.Context) flags.Assignment {
+	rs := l.ruleset.Load()
+	if rs == nil {
+		return flags.Assignment{Experiment: key, Reason: flags.AssignError, Error: "ruleset not loaded; call Start"}
+	}
+	a := rs.Assign(key, ctx)
+	if a.InExperiment() && l.Exposures != nil {
+		l.exposures.add(Exposure{
+			Experiment: a.Experiment,
+			Layer:      a.Layer,
+			Version:    a.Version,
+			Arm:        a.Arm,
+			EnvID:      l.envID,
+			Unit:       a.Unit,
+			Timestamp:  time.Now().UTC(),
+		})
+	}
+	return a
+}
+
+// Version returns the version of the ruleset in use, or "" before Start.
+func (l *LocalFlags) Version() string {
+	if rs := l.ruleset.Load(); rs != nil {
+		return rs.Version
+	}
+	return ""
+}
+
+// Ruleset returns the ruleset in use, or nil before Start. It must not be
+// modified.
+func (l *LocalFlags) Ruleset() *flags.Ruleset {
+	return l.ruleset.Load()
+}
+
+// install swaps in rs if it is a new version.
+func (l *LocalFlags) install(rs *flags.Ruleset) {
+	if old := l.ruleset.Load(); old != nil && old.Version == rs.Version {
+		return
+	}
+	l.ruleset.Store(rs)
+	if l.OnUpdate != nil {
+		l.OnUpdate(rs.Version)
+	}
+}
+
+// stream follows the ruleset stream until ctx is cancelled, reconnecting
+// with exponential backoff (1s to 30s) after failures.
+func (l *LocalFlags) stream(ctx context.Context) {
+	defer l.wg.Done()
+	backoff := time.Second
+	for {
+		received, err := l.streamOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && l.OnError != nil {
+			l.OnError(err)
+		}
+		if received {
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// streamOnce reads one connection's worth of events. It reports whether any
+// ruleset was received, and why the connection ended.
+func (l *LocalFlags) streamOnce(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	idle := time.AfterFunc(streamIdleTimeout, cancel)
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.client.BaseURL+"/v1/ruleset/stream?env_id="+strconv.Itoa(l.envID), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-API-Key", l.client.APIKey)
+	req.Header.Set("Accept", "text/event-stream")
+
+	// No client timeout: the response never ends on its own.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("configra: ruleset stream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+
+	received := false
+	var event string
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 32<<20)
+	for scanner.Scan() {
+		idle.Reset(streamIdleTimeout)
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "ruleset" && len(data) > 0 {
+				var rs flags.Ruleset
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &rs); err != nil {
+					return received, fmt.Errorf("configra: invalid ruleset event: %w", err)
+				}
+				l.install(&rs)
+				received = true
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// Keep-alive comment
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return received, fmt.Errorf("configra: ruleset stream: %w", err)
+	}
+	return received, fmt.Errorf("configra: ruleset stream closed")
+}
+
+// report sends the evaluation counts every CountInterval, and a last time
+// when ctx is cancelled.
+func (l *LocalFlags) report(ctx context.Context) {
+	defer l.wg.Done()
+	interval := l.CountInterval
+	if interval == 0 {
+		interval = DefaultCountInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			l.sendCounts()
+			return
+		case <-ticker.C:
+			l.sendCounts()
+		}
+	}
+}
+
+// sendCounts posts the counts collected since the last batch, in requests of
+// at most maxCountsPerRequest. Counts the server could not be reached for are
+// kept for the next batch; a request it rejected is dropped, and the
+// remaining ones are still sent.
+func (l *LocalFlags) sendCounts() {
+	counts := l.counts.Drain()
+	for len(counts) > 0 {
+		n := min(len(counts), maxCountsPerRequest)
+		b, err := json.Marshal(map[string]interface{}{"env_id": l.envID, "counts": counts[:n]})
+		if err == nil {
+			err = l.client.do(http.MethodPost, "/v1/flags/evaluations", bytes.NewReader(b), nil)
+		}
+		if err != nil {
+			if l.OnError != nil {
+				l.OnError(fmt.Errorf("configra: sending evaluation counts: %w", err))
+			}
+			if isUnavailable(err) {
+				l.counts.Merge(counts)
+				return
+			}
+		}
+		counts = counts[n:]
+	}
+}
+
+// expose sends the buffered exposures every ExposureInterval, and a last
+// time when ctx is cancelled.
+func (l *LocalFlags) expose(ctx context.Context) {
+	defer l.wg.Done()
+	interval := l.ExposureInterval
+	if interval == 0 {
+		interval = DefaultExposureInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			l.sendExposures()
+			return
+		case <-ticker.C:
+			l.sendExposures()
+		}
+	}
+}
+
+// sendExposures passes the buffered exposures to the sink, keeping them for
+// the next batch if it fails.
+func (l *LocalFlags) sendExposures() {
+	batch := l.exposures.drain()
+	if len(batch) == 0 {
+		return
+	}
+	if err := l.Exposures.Send(batch); err != nil {
+		l.exposures.requeue(batch)
+		if l.OnError != nil {
+			l.OnError(fmt.Errorf("configra: sending exposures: %w", err))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clyvecute/configra/pkg/flags"
+)
+
+func testRuleset(t *testing.T, on bool) *flags.Ruleset {
+	t.Helper()
+	f := flags.Flag{
+		Key:          "new-checkout",
+		On:           on,
+		Variations:   []flags.Variation{{Name: "off", Value: false}, {Name: "on", Value: true}},
+		OffVariation: "off",
+		Rules: []flags.Rule{{
+			ID:      "beta",
+			Clauses: []flags.Clause{{Op: flags.OpSegmentMatch, Values: []interface{}{"beta"}}},
+			Serve:   flags.Serve{Variation: "on"},
+		}},
+		Default: flags.Serve{Variation: "off"},
+	}
+	if errs := f.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	return flags.NewRuleset(1, []flags.Flag{f}, []flags.Segment{{Key: "beta", Included: []string{"user-1"}}}, nil)
+}
+
+func TestLocalFlagsFollowStream(t *testing.T) {
+	v1, v2 := testRuleset(t, false), testRuleset(t, true)
+	push := make(chan *flags.Ruleset)
+	reported := make(chan []flags.EvaluationCount, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/flags/evaluations" {
+			var req struct {
+				EnvID  int                     `json:"env_id"`
+				Counts []flags.EvaluationCount `json:"counts"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.EnvID != 1 {
+				t.Errorf("bad count report: %+v, %v", req, err)
+			}
+			reported <- req.Counts
+			return
+		}
+		if r.URL.Query().Get("env_id") != "1" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		switch r.URL.Path {
+		case "/v1/ruleset":
+			json.NewEncoder(w).Encode(v1)
+		case "/v1/ruleset/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, rs := range []*flags.Ruleset{v1, nil} {
+				if rs == nil {
+					select {
+					case rs = <-push:
+					case <-r.Context().Done():
+						return
+					}
+				}
+				b, _ := json.Marshal(rs)
+				fmt.Fprintf(w, ": ping\n\nevent: ruleset\nid: %s\ndata: %s\n\n", rs.Version, b)
+				w.(http.Flusher).Flush()
+			}
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	local := NewClient(srv.URL, "key").LocalFlags(1)
+	updates := make(chan string, 4)
+	local.OnUpdate = func(version string) { updates <- version }
+	local.OnError = func(err error) { t.Errorf("stream error: %v", err) }
+	local.CountInterval = time.Hour // Only the report sent by Close
+	if err := local.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if <-updates != v1.Version || local.Version() != v1.Version {
+		t.Fatalf("Version() = %s, want %s", local.Version(), v1.Version)
+	}
+	if res := local.Evaluate("new-checkout", flags.Context{"key": "user-1"}); res.Reason.Kind != flags.ReasonDisabled {
+		t.Errorf("before the update: %+v", res)
+	}
+
+	push <- v2
+	select {
+	case version := <-updates:
+		if version != v2.Version {
+			t.Fatalf("update to %s, want %s", version, v2.Version)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update received from the stream")
+	}
+	if res := local.Evaluate("new-checkout", flags.Context{"key": "user-1"}); res.Variation != "on" || res.Reason.RuleID != "beta" {
+		t.Errorf("after the update: %+v", res)
+	}
+	if res := local.Evaluate("nope", flags.Context{}); res.Reason.Kind != flags.ReasonError {
+		t.Errorf("unknown flag: %+v", res)
+	}
+
+	local.Close()
+	select {
+	case counts := <-reported:
+		total := map[string]int64{}
+		for _, c := range counts {
+			total[c.Flag+"/"+c.Variation] += c.Count
+		}
+		if want := map[string]int64{"new-checkout/off": 1, "new-checkout/on": 1}; !reflect.DeepEqual(total, want) {
+			t.Errorf("reported counts %v, want %v", total, want)
+		}
+	default:
+		t.Error("Close() did not report the evaluation counts")
+	}
+}
@@ -0,0 +1,351 @@
+// Package openfeature is a Configra provider for the OpenFeature Go SDK.
+// Flags are evaluated in process from the environment's ruleset, which is
+// kept current by the server's update stream (see sdk.LocalFlags).
+//
+//	provider := openfeature.NewProvider(sdk.NewClient(url, apiKey), envID)
+//	if err := of.SetProviderAndWait(provider); err != nil {
+//		log.Fatal(err)
+//	}
+//	client := of.NewDefaultClient()
+//	enabled := client.Boolean(ctx, "new-checkout", false, of.NewEvaluationContext("user-42", nil))
+package openfeature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+
+	"github.com/clyvecute/configra/pkg/flags"
+	"github.com/clyvecute/configra/pkg/sdk"
+)
+
+// Name is the provider name reported in OpenFeature metadata.
+const Name = "Configra"
+
+var reasons = map[string]of.Reason{
+	flags.ReasonDisabled:       of.DisabledReason,
+	flags.ReasonTargetingMatch: of.TargetingMatchReason,
+	flags.ReasonSplit:          of.SplitReason,
+	flags.ReasonDefault:        of.DefaultReason,
+	flags.ReasonError:          of.ErrorReason,
+}
+
+// Provider implements of.FeatureProvider, of.StateHandler and
+// of.EventHandler for one Configra environment.
+type Provider struct {
+	// OnError is called when the update stream fails, or evaluation counts
+	// can't be sent. Evaluations keep using the last ruleset while it
+	// reconnects.
+	OnError func(error)
+
+	client *sdk.Client
+	envID  int
+	events chan of.Event
+
+	mu    sync.Mutex
+	local *sdk.LocalFlags
+	last  *flags.Ruleset // Last ruleset announced, for the flags an update changed
+}
+
+// NewProvider returns a provider for an environment. It loads the ruleset
+// when OpenFeature initializes it.
+func NewProvider(client *sdk.Client, envID int) *Provider {
+	return &Provider{client: client, envID: envID, events: make(chan of.Event, 64)}
+}
+
+func (p *Provider) Metadata() of.Metadata {
+	return of.Metadata{Name: Name}
+}
+
+func (p *Provider) Hooks() []of.Hook {
+	return nil
+}
+
+// Init loads the environment's ruleset and starts following its updates.
+func (p *Provider) Init(of.EvaluationContext) error {
+	local := p.client.LocalFlags(p.envID)
+	local.OnUpdate = func(string) { p.updated(local.Ruleset()) }
+	local.OnError = func(err error) {
+		if p.OnError != nil {
+			p.OnError(err)
+		}
+	}
+	if err := local.Start(); err != nil {
+		return fmt.Errorf("configra: loading ruleset: %w", err)
+	}
+
+	p.mu.Lock()
+	old := p.local
+	p.local = local
+	p.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Shutdown stops following ruleset updates. Evaluations then report
+// PROVIDER_NOT_READY until the next Init.
+func (p *Provider) Shutdown() {
+	p.mu.Lock()
+	local := p.local
+	p.local, p.last = nil, nil
+	p.mu.Unlock()
+	if local != nil {
+		local.Close()
+	}
+}
+
+// EventChannel emits PROVIDER_CONFIGURATION_CHANGED, with the keys of the
+// affected flags, whenever the stream delivers a new ruleset.
+func (p *Provider) EventChannel() <-chan of.Event {
+	return p.events
+}
+
+// updated announces a new ruleset. The first one, loaded by Init, is not a
+// change.
+func (p *Provider) updated(rs *flags.Ruleset) {
+	p.mu.Lock()
+	old := p.last
+	p.last = rs
+	p.mu.Unlock()
+	if old == nil || rs == nil {
+		return
+	}
+
+	event := of.Event{
+		ProviderName: Name,
+		EventType:    of.ProviderConfigChange,
+		ProviderEventDetails: of.ProviderEventDetails{
+			Message:       "ruleset updated to version " + rs.Version,
+			FlagChanges:   changedFlags(old, rs),
+			EventMetadata: map[string]any{"rulesetVersion": rs.Version},
+		},
+	}
+	select {
+	case p.events <- event:
+	default: // Nobody is listening; evaluations already use the new ruleset
+	}
+}
+
+func (p *Provider) BooleanEvaluation(_ context.Context, flag string, defaultValue bool, flatCtx of.FlattenedContext) of.BoolResolutionDetail {
+	value, detail := p.resolve(flag, flatCtx, func(v interface{}) (interface{}, bool) {
+		b, ok := v.(bool)
+		return b, ok
+	})
+	if value == nil {
+		return of.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.BoolResolutionDetail{Value: value.(bool), ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) StringEvaluation(_ context.Context, flag string, defaultValue string, flatCtx of.FlattenedContext) of.StringResolutionDetail {
+	value, detail := p.resolve(flag, flatCtx, func(v interface{}) (interface{}, bool) {
+		s, ok := v.(string)
+		return s, ok
+	})
+	if value == nil {
+		return of.StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.StringResolutionDetail{Value: value.(string), ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) FloatEvaluation(_ context.Context, flag string, defaultValue float64, flatCtx of.FlattenedContext) of.FloatResolutionDetail {
+	value, detail := p.resolve(flag, flatCtx, func(v interface{}) (interface{}, bool) {
+		f, ok := toFloat(v)
+		return f, ok
+	})
+	if value == nil {
+		return of.FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.FloatResolutionDetail{Value: value.(float64), ProviderResolutionDetail: detail}
+}
+
+// IntEvaluation accepts numbers without a fractional part.
+func (p *Provider) IntEvaluation(_ context.Context, flag string, defaultValue int64, flatCtx of.FlattenedContext) of.IntResolutionDetail {
+	value, detail := p.resolve(flag, flatCtx, func(v interface{}) (interface{}, bool) {
+		f, ok := toFloat(v)
+		if !ok || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return nil, false
+		}
+		return int64(f), true
+	})
+	if value == nil {
+		return of.IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.IntResolutionDetail{Value: value.(int64), ProviderResolutionDetail: detail}
+}
+
+// ObjectEvaluation accepts JSON objects and arrays.
+func (p *Provider) ObjectEvaluation(_ context.Context, flag string, defaultValue any, flatCtx of.FlattenedContext) of.InterfaceResolutionDetail {
+	value, detail := p.resolve(flag, flatCtx, func(v interface{}) (interface{}, bool) {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return v, true
+		}
+		return nil, false
+	})
+	if value == nil {
+		return of.InterfaceResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.InterfaceResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// resolve evaluates a flag and converts the served value with convert. On
+// failure it returns a nil value, and the detail carries the error code.
+func (p *Provider) resolve(key string, flatCtx of.FlattenedContext, convert func(interface{}) (interface{}, bool)) (interface{}, of.ProviderResolutionDetail) {
+	fail := func(err of.ResolutionError) (interface{}, of.ProviderResolutionDetail) {
+		return nil, of.ProviderResolutionDetail{ResolutionError: err, Reason: of.ErrorReason}
+	}
+
+	p.mu.Lock()
+	local := p.local
+	p.mu.Unlock()
+	var rs *flags.Ruleset
+	if local != nil {
+		rs = local.Ruleset()
+	}
+	if rs == nil {
+		return fail(of.NewProviderNotReadyResolutionError("ruleset not loaded"))
+	}
+	f := rs.Flag(key)
+	if f == nil {
+		return fail(of.NewFlagNotFoundResolutionError(fmt.Sprintf("flag '%s' not found", key)))
+	}
+
+	ctx := toContext(flatCtx)
+	res := local.Evaluate(key, ctx) // Counted for the stale flag report
+	if res.Reason.Kind == flags.ReasonError {
+		if _, ok := ctx[flags.KeyAttribute]; !ok && bucketsByKey(f) {
+			return fail(of.NewTargetingKeyMissingResolutionError(res.Reason.Error))
+		}
+		return fail(of.NewGeneralResolutionError(res.Reason.Error))
+	}
+
+	value, ok := convert(res.Value)
+	if !ok {
+		return fail(of.NewTypeMismatchResolutionError(fmt.Sprintf("flag '%s' served variation '%s' of type %T", key, res.Variation, res.Value)))
+	}
+	metadata := of.FlagMetadata{"flagVersion": int64(res.Version), "rulesetVersion": rs.Version}
+	if res.Reason.RuleID != "" {
+		metadata["ruleId"] = res.Reason.RuleID
+	}
+	return value, of.ProviderResolutionDetail{
+		Reason:       reasons[res.Reason.Kind],
+		Variant:      res.Variation,
+		FlagMetadata: metadata,
+	}
+}
+
+// toContext maps an OpenFeature context to a Configra one: the targeting key
+// becomes the "key" attribute unless the context sets "key" itself.
+func toContext(flatCtx of.FlattenedContext) flags.Context {
+	ctx := make(flags.Context, len(flatCtx))
+	for name, v := range flatCtx {
+		if name != of.TargetingKey {
+			ctx[name] = v
+		}
+	}
+	if key, ok := flatCtx[of.TargetingKey].(string); ok && key != "" {
+		if _, set := ctx[flags.KeyAttribute]; !set {
+			ctx[flags.KeyAttribute] = key
+		}
+	}
+	return ctx
+}
+
+// bucketsByKey reports whether any rollout of f buckets by the "key"
+// attribute, i.e. whether it needs a targeting key.
+func bucketsByKey(f *flags.Flag) bool {
+	serves := []flags.Serve{f.Default}
+	for _, r := range f.Rules {
+		serves = append(serves, r.Serve)
+	}
+	for _, s := range serves {
+		if s.Rollout != nil && (s.Rollout.BucketBy == "" || s.Rollout.BucketBy == flags.KeyAttribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFlags returns the keys of the flags that were added, removed or
+// edited between two rulesets, or that use a segment that was.
+func changedFlags(old, rs *flags.Ruleset) []string {
+	segments := map[string]bool{}
+	for _, key := range diffKeys(byKey(old.Segments, segmentKey), byKey(rs.Segments, segmentKey)) {
+		segments[key] = true
+	}
+
+	changed := map[string]bool{}
+	for _, key := range diffKeys(byKey(old.Flags, flagKey), byKey(rs.Flags, flagKey)) {
+		changed[key] = true
+	}
+	for _, r := range []*flags.Ruleset{old, rs} {
+		for i := range r.Flags {
+			for _, key := range r.Flags[i].SegmentKeys() {
+				if segments[key] {
+					changed[r.Flags[i].Key] = true
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func flagKey(f flags.Flag) string       { return f.Key }
+func segmentKey(s flags.Segment) string { return s.Key }
+
+// byKey renders each item as JSON, by key.
+func byKey[T any](items []T, key func(T) string) map[string]string {
+	m := make(map[string]string, len(items))
+	for _, item := range items {
+		b, _ := json.Marshal(item)
+		m[key(item)] = string(b)
+	}
+	return m
+}
+
+// diffKeys returns the keys present in only one of the maps or with
+// different values.
+func diffKeys(a, b map[string]string) []string {
+	var keys []string
+	for key, v := range a {
+		if w, ok := b[key]; !ok || v != w {
+			keys = append(keys, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,329 @@
+package openfeature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+
+	"github.com/clyvecute/configra/pkg/flags"
+	"github.com/clyvecute/configra/pkg/sdk"
+)
+
+const testFlags = `[
+	{
+		"key": "new-checkout", "on": true,
+		"variations": [{"name": "off", "value": false}, {"name": "on", "value": true}],
+		"off_variation": "off",
+		"rules": [{"id": "beta", "clauses": [{"op": "segment_match", "values": ["beta"]}], "variation": "on"}],
+		"default": {"rollout": {"variations": [{"variation": "on", "weight": 50}, {"variation": "off", "weight": 50}]}}
+	},
+	{
+		"key": "killswitch", "on": false,
+		"variations": [{"name": "off", "value": false}, {"name": "on", "value": true}],
+		"off_variation": "off", "default": {"variation": "on"}
+	},
+	{
+		"key": "banner", "on": true,
+		"variations": [{"name": "blue", "value": "blue"}, {"name": "green", "value": "green"}],
+		"off_variation": "blue", "default": {"variation": "blue"}
+	},
+	{
+		"key": "max-items", "on": true,
+		"variations": [{"name": "small", "value": 25}, {"name": "large", "value": 100}],
+		"off_variation": "small",
+		"rules": [{"id": "pro", "clauses": [{"attribute": "plan", "op": "in", "values": ["pro"]}], "variation": "large"}],
+		"default": {"variation": "small"}
+	},
+	{
+		"key": "discount", "on": true,
+		"variations": [{"name": "none", "value": 0}, {"name": "some", "value": 0.15}],
+		"off_variation": "none", "default": {"variation": "some"}
+	},
+	{
+		"key": "theme", "on": true,
+		"variations": [{"name": "dark", "value": {"color": "dark", "sizes": [1, 2]}}],
+		"off_variation": "dark", "default": {"variation": "dark"}
+	},
+	{
+		"key": "broken", "on": true,
+		"variations": [{"name": "off", "value": false}],
+		"off_variation": "off",
+		"rules": [{"clauses": [{"op": "segment_match", "values": ["gone"]}], "variation": "off"}],
+		"default": {"variation": "off"}
+	}
+]`
+
+func testRuleset(t *testing.T, bannerDefault string) *flags.Ruleset {
+	t.Helper()
+	var fs []flags.Flag
+	if err := json.Unmarshal([]byte(testFlags), &fs); err != nil {
+		t.Fatal(err)
+	}
+	fs[2].Default.Variation = bannerDefault
+	return flags.NewRuleset(1, fs, []flags.Segment{{Key: "beta", Included: []string{"user-1"}}}, nil)
+}
+
+// startServer serves v1 as the ruleset, and pushes every ruleset sent on the
+// returned channel down the update stream.
+func startServer(t *testing.T, v1 *flags.Ruleset) (*httptest.Server, chan<- *flags.Ruleset) {
+	push := make(chan *flags.Ruleset)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/ruleset":
+			json.NewEncoder(w).Encode(v1)
+		case "/v1/ruleset/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for rs := v1; ; {
+				b, _ := json.Marshal(rs)
+				fmt.Fprintf(w, "event: ruleset\nid: %s\ndata: %s\n\n", rs.Version, b)
+				w.(http.Flusher).Flush()
+				select {
+				case rs = <-push:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, push
+}
+
+func newTestClient(t *testing.T, domain string, p *Provider) *of.Client {
+	t.Helper()
+	if err := of.SetNamedProviderAndWait(domain, p); err != nil {
+		t.Fatalf("SetNamedProviderAndWait() error = %v", err)
+	}
+	t.Cleanup(p.Shutdown)
+	return of.NewClient(domain)
+}
+
+func TestProviderConformance(t *testing.T) {
+	srv, _ := startServer(t, testRuleset(t, "blue"))
+	p := NewProvider(sdk.NewClient(srv.URL, "key"), 1)
+	if p.Metadata().Name != "Configra" {
+		t.Errorf("Metadata() = %+v", p.Metadata())
+	}
+	client := newTestClient(t, "conformance", p)
+	ctx := context.Background()
+
+	user := func(key string, attrs map[string]any) of.EvaluationContext {
+		return of.NewEvaluationContext(key, attrs)
+	}
+
+	type want struct {
+		value   interface{}
+		variant string
+		reason  of.Reason
+		code    of.ErrorCode
+		ruleID  string
+	}
+	tests := []struct {
+		name string
+		eval func() (interface{}, of.EvaluationDetails, error)
+		want want
+	}{
+		{
+			name: "boolean targeting match via targeting key",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.BooleanValueDetails(ctx, "new-checkout", false, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: true, variant: "on", reason: of.TargetingMatchReason, ruleID: "beta"},
+		},
+		{
+			name: "boolean disabled",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.BooleanValueDetails(ctx, "killswitch", true, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: false, variant: "off", reason: of.DisabledReason},
+		},
+		{
+			name: "string default",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.StringValueDetails(ctx, "banner", "none", user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: "blue", variant: "blue", reason: of.DefaultReason},
+		},
+		{
+			name: "int targeting match on an attribute",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.IntValueDetails(ctx, "max-items", 1, user("user-1", map[string]any{"plan": "pro"}))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: int64(100), variant: "large", reason: of.TargetingMatchReason, ruleID: "pro"},
+		},
+		{
+			name: "float",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.FloatValueDetails(ctx, "discount", 0, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: 0.15, variant: "some", reason: of.DefaultReason},
+		},
+		{
+			name: "float accepts whole numbers",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.FloatValueDetails(ctx, "max-items", 0, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: 25.0, variant: "small", reason: of.DefaultReason},
+		},
+		{
+			name: "object",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.ObjectValueDetails(ctx, "theme", nil, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: map[string]interface{}{"color": "dark", "sizes": []interface{}{1.0, 2.0}}, variant: "dark", reason: of.DefaultReason},
+		},
+		{
+			name: "flag not found",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.BooleanValueDetails(ctx, "nope", true, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: true, reason: of.ErrorReason, code: of.FlagNotFoundCode},
+		},
+		{
+			name: "type mismatch",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.StringValueDetails(ctx, "new-checkout", "fallback", user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: "fallback", reason: of.ErrorReason, code: of.TypeMismatchCode},
+		},
+		{
+			name: "int rejects fractions",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.IntValueDetails(ctx, "discount", 7, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: int64(7), reason: of.ErrorReason, code: of.TypeMismatchCode},
+		},
+		{
+			name: "object rejects scalars",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.ObjectValueDetails(ctx, "banner", "fallback", user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: "fallback", reason: of.ErrorReason, code: of.TypeMismatchCode},
+		},
+		{
+			name: "rollout without a targeting key",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.BooleanValueDetails(ctx, "new-checkout", true, of.NewTargetlessEvaluationContext(nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: true, reason: of.ErrorReason, code: of.TargetingKeyMissingCode},
+		},
+		{
+			name: "evaluation error",
+			eval: func() (interface{}, of.EvaluationDetails, error) {
+				d, err := client.BooleanValueDetails(ctx, "broken", true, user("user-1", nil))
+				return d.Value, d.EvaluationDetails, err
+			},
+			want: want{value: true, reason: of.ErrorReason, code: of.GeneralCode},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, d, err := tt.eval()
+			if !reflect.DeepEqual(value, tt.want.value) {
+				t.Errorf("value = %#v, want %#v", value, tt.want.value)
+			}
+			if d.Variant != tt.want.variant || d.Reason != tt.want.reason || d.ErrorCode != tt.want.code {
+				t.Errorf("variant, reason, code = %q, %s, %q, want %q, %s, %q",
+					d.Variant, d.Reason, d.ErrorCode, tt.want.variant, tt.want.reason, tt.want.code)
+			}
+			if (err != nil) != (tt.want.code != "") {
+				t.Errorf("error = %v", err)
+			}
+			if tt.want.code != "" {
+				return
+			}
+			if d.ErrorMessage != "" {
+				t.Errorf("ErrorMessage = %q", d.ErrorMessage)
+			}
+			if ruleID, _ := d.FlagMetadata["ruleId"].(string); ruleID != tt.want.ruleID {
+				t.Errorf("ruleId = %q, want %q", ruleID, tt.want.ruleID)
+			}
+			if _, err := d.FlagMetadata.GetString("rulesetVersion"); err != nil {
+				t.Errorf("rulesetVersion: %v", err)
+			}
+		})
+	}
+}
+
+func TestProviderSplit(t *testing.T) {
+	srv, _ := startServer(t, testRuleset(t, "blue"))
+	client := newTestClient(t, "split", NewProvider(sdk.NewClient(srv.URL, "key"), 1))
+
+	served := map[bool]int{}
+	for i := 0; i < 40; i++ {
+		d, err := client.BooleanValueDetails(context.Background(), "new-checkout", false, of.NewEvaluationContext(fmt.Sprintf("user-%d", i+100), nil))
+		if err != nil || d.Reason != of.SplitReason || d.Variant != map[bool]string{true: "on", false: "off"}[d.Value] {
+			t.Fatalf("user-%d: %+v, %v", i+100, d, err)
+		}
+		served[d.Value]++
+	}
+	if served[true] == 0 || served[false] == 0 {
+		t.Errorf("rollout served only one variation: %v", served)
+	}
+}
+
+func TestProviderNotReady(t *testing.T) {
+	p := NewProvider(sdk.NewClient("http://127.0.0.1:0", "key"), 1)
+	res := p.BooleanEvaluation(context.Background(), "new-checkout", true, of.FlattenedContext{of.TargetingKey: "user-1"})
+	if !res.Value || res.Reason != of.ErrorReason || res.ResolutionDetail().ErrorCode != of.ProviderNotReadyCode {
+		t.Errorf("before Init: %+v", res)
+	}
+	if err := p.Init(of.EvaluationContext{}); err == nil {
+		t.Error("Init() with an unreachable server succeeded")
+	}
+}
+
+func TestProviderConfigurationChanged(t *testing.T) {
+	srv, push := startServer(t, testRuleset(t, "blue"))
+	client := newTestClient(t, "events", NewProvider(sdk.NewClient(srv.URL, "key"), 1))
+
+	events := make(chan of.EventDetails, 4)
+	handler := func(e of.EventDetails) { events <- e }
+	client.AddHandler(of.ProviderConfigChange, &handler)
+
+	push <- testRuleset(t, "green")
+	select {
+	case e := <-events:
+		if !reflect.DeepEqual(e.FlagChanges, []string{"banner"}) {
+			t.Errorf("FlagChanges = %v, want [banner]", e.FlagChanges)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no PROVIDER_CONFIGURATION_CHANGED event")
+	}
+	if got := client.String(context.Background(), "banner", "none", of.NewEvaluationContext("user-1", nil)); got != "green" {
+		t.Errorf("banner after the update = %q, want green", got)
+	}
+}
+
+func TestChangedFlags(t *testing.T) {
+	old := testRuleset(t, "blue")
+	rs := flags.NewRuleset(1, old.Flags[1:], []flags.Segment{{Key: "beta", Included: []string{"user-2"}}}, nil)
+	if got, want := changedFlags(old, rs), []string{"new-checkout"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changedFlags() = %v, want %v", got, want)
+	}
+	if got := changedFlags(old, old); len(got) != 0 {
+		t.Errorf("changedFlags() of the same ruleset = %v", got)
+	}
+}
@@ -0,0 +1,114 @@
+package sdk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshot is the on-disk format of a last-known-good config.
+type snapshot struct {
+	EnvID     int                    `json:"env_id"`
+	Key       string                 `json:"key"`
+	Version   int                    `json:"version"`
+	Checksum  string                 `json:"checksum"` // sha256 of the JSON-encoded data
+	FetchedAt time.Time              `json:"fetched_at"`
+	Data      map[string]interface{} `json:"data"`
+	Schema    map[string]interface{} `json:"schema,omitempty"`
+}
+
+// snapshotName names the snapshot of envID/key. It starts with a hash of the
+// server and API key, which determines the project, so clients of different
+// servers or projects can share a directory.
+func (c *Client) snapshotName(envID int, key string) string {
+	sum := sha256.Sum256([]byte(c.BaseURL + "\n" + c.APIKey))
+	return fmt.Sprintf("%s_%d_%s.json", hex.EncodeToString(sum[:6]), envID, url.PathEscape(key))
+}
+
+// Checksum returns the hex sha256 of the JSON encoding of data. Map keys are
+// sorted by encoding/json, so equal data always yields the same checksum.
+func Checksum(data map[string]interface{}) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeSnapshot atomically persists cfg into dir under name.
+func writeSnapshot(dir, name string, cfg *Config) error {
+	sum, err := Checksum(cfg.Data)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(snapshot{
+		EnvID:     cfg.EnvID,
+		Key:       cfg.Key,
+		Version:   cfg.Version,
+		Checksum:  sum,
+		FetchedAt: cfg.FetchedAt,
+		Data:      cfg.Data,
+		Schema:    cfg.Schema,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// readSnapshot loads the snapshot name from dir and verifies that it holds
+// envID/key and matches its checksum.
+func readSnapshot(dir, name string, envID int, key string) (*Config, error) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("corrupt snapshot: %w", err)
+	}
+	if snap.EnvID != envID || snap.Key != key {
+		return nil, fmt.Errorf("snapshot holds %s of environment %d, not %s of environment %d", snap.Key, snap.EnvID, key, envID)
+	}
+	sum, err := Checksum(snap.Data)
+	if err != nil {
+		return nil, err
+	}
+	if sum != snap.Checksum {
+		return nil, fmt.Errorf("snapshot checksum mismatch for version %d", snap.Version)
+	}
+
+	return &Config{
+		EnvID:     snap.EnvID,
+		Key:       snap.Key,
+		Version:   snap.Version,
+		Data:      snap.Data,
+		Schema:    snap.Schema,
+		Stale:     true,
+		FetchedAt: snap.FetchedAt,
+	}, nil
+}